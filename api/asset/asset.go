@@ -0,0 +1,24 @@
+package asset
+
+import "embed"
+
+// Migrations are the versioned up/down sql migrations, see infra/migrate.
+// The Postgres migrations are at the root, other dialects have their own directory, e.g. migrations/sqlite
+//
+//go:embed migrations
+var Migrations embed.FS
+
+// Seeds are the yaml/json data seeders, see infra/seed
+//
+//go:embed seeds
+var Seeds embed.FS
+
+// Queries are the named sql queries, see infra/load
+//
+//go:embed queries
+var Queries embed.FS
+
+// Mails are the email templates, each one defines a "subject" and a "body" rendered in layout.html
+//
+//go:embed mails
+var Mails embed.FS
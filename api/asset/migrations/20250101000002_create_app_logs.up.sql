@@ -0,0 +1,6 @@
+CREATE TABLE app_logs (
+    id BIGSERIAL PRIMARY KEY,
+    level VARCHAR(20) NOT NULL,
+    message TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
@@ -0,0 +1,13 @@
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP NULL;
+
+CREATE TABLE user_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    purpose VARCHAR(30) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX user_tokens_user_id_idx ON user_tokens (user_id, purpose);
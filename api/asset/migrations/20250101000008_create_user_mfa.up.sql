@@ -0,0 +1,17 @@
+CREATE TABLE user_totp (
+    user_id INTEGER PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    secret VARCHAR(64) NOT NULL,
+    last_counter BIGINT NOT NULL DEFAULT 0,
+    confirmed_at TIMESTAMP NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE TABLE user_recovery_codes (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMP NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX user_recovery_codes_user_id_idx ON user_recovery_codes (user_id);
@@ -0,0 +1,19 @@
+CREATE TABLE user_lockouts (
+    user_id INTEGER PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    failed_attempts INTEGER NOT NULL DEFAULT 0,
+    last_failed_at TIMESTAMP NOT NULL DEFAULT now(),
+    locked_until TIMESTAMP NULL
+);
+
+CREATE TABLE audit_logs (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NULL REFERENCES users (id) ON DELETE SET NULL,
+    actor_id INTEGER NULL REFERENCES users (id) ON DELETE SET NULL,
+    event VARCHAR(50) NOT NULL,
+    ip VARCHAR(45) NULL,
+    detail TEXT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX audit_logs_user_id_idx ON audit_logs (user_id);
+CREATE INDEX audit_logs_event_idx ON audit_logs (event);
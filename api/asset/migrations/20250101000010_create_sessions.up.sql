@@ -0,0 +1,18 @@
+CREATE TABLE sessions (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    family VARCHAR(64) NOT NULL UNIQUE,
+    device VARCHAR(100) NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip VARCHAR(45) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    last_seen_at TIMESTAMP NOT NULL DEFAULT now(),
+    revoked_at TIMESTAMP NULL
+);
+
+CREATE INDEX sessions_user_id_idx ON sessions (user_id);
+
+-- token families issued before sessions stay logged in
+INSERT INTO sessions (user_id, family, created_at, last_seen_at)
+SELECT user_id, family, min(created_at), max(created_at) FROM refresh_tokens
+GROUP BY user_id, family HAVING count(revoked_at) = 0;
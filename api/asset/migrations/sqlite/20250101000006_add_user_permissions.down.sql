@@ -0,0 +1 @@
+DELETE FROM permissions WHERE name IN ('users.read', 'users.update', 'users.delete');
@@ -0,0 +1,5 @@
+INSERT INTO permissions (name) VALUES ('users.read'), ('users.update'), ('users.delete');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r CROSS JOIN permissions p
+WHERE r.name = 'admin' AND p.name IN ('users.read', 'users.update', 'users.delete');
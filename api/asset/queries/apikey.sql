@@ -0,0 +1,30 @@
+-- name: API_KEY_INSERT :one
+-- :user_id int
+-- :name string
+-- :prefix string
+-- :key_hash string
+-- :scopes string
+-- :expires_at any
+INSERT INTO api_keys (user_id,name,prefix,key_hash,scopes,expires_at) VALUES ($1,$2,$3,$4,$5,$6) RETURNING id, created_at;
+
+-- name: API_KEY_LIST
+-- :user_id int
+SELECT id, user_id, name, prefix, scopes, last_used_at, expires_at, revoked_at, created_at
+FROM api_keys WHERE user_id=$1 ORDER BY id DESC;
+
+-- name: API_KEY_GET_WITH_HASH :one
+-- :key_hash string
+-- :now string
+SELECT id, user_id, name, prefix, scopes, last_used_at, expires_at, revoked_at, created_at
+FROM api_keys WHERE key_hash=$1 AND revoked_at isnull AND (expires_at isnull OR expires_at > $2);
+
+-- name: API_KEY_LAST_USED
+-- :last_used_at string
+-- :id int
+UPDATE api_keys SET last_used_at=$1 WHERE id=$2;
+
+-- name: API_KEY_REVOKE
+-- :revoked_at string
+-- :id int
+-- :user_id int
+UPDATE api_keys SET revoked_at=$1 WHERE id=$2 AND user_id=$3 AND revoked_at isnull;
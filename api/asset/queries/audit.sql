@@ -0,0 +1,7 @@
+-- name: AUDIT_INSERT
+-- :user_id any
+-- :actor_id any
+-- :event string
+-- :ip string
+-- :detail string
+INSERT INTO audit_logs (user_id,actor_id,event,ip,detail) VALUES ($1,$2,$3,$4,$5);
@@ -0,0 +1,18 @@
+-- name: IDENTITY_GET :one
+-- :provider string
+-- :subject string
+SELECT id, user_id, provider, subject, email, last_login_at, created_at FROM user_identities WHERE provider=$1 AND subject=$2;
+
+-- name: IDENTITY_INSERT
+-- :user_id int
+-- :provider string
+-- :subject string
+-- :email string
+-- :last_login_at string
+INSERT INTO user_identities (user_id,provider,subject,email,last_login_at) VALUES ($1,$2,$3,$4,$5);
+
+-- name: IDENTITY_LOGIN
+-- :email string
+-- :last_login_at string
+-- :id int
+UPDATE user_identities SET email=$1, last_login_at=$2 WHERE id=$3;
@@ -0,0 +1,23 @@
+-- name: LOCKOUT_GET :one
+-- :user_id int
+-- :now string
+SELECT failed_attempts, locked_until FROM user_lockouts WHERE user_id=$1 AND locked_until > $2;
+
+-- name: LOCKOUT_FAIL :one
+-- :user_id int
+-- :last_failed_at string
+-- :window_start string
+INSERT INTO user_lockouts (user_id,failed_attempts,last_failed_at) VALUES ($1,1,$2)
+ON CONFLICT (user_id) DO UPDATE SET
+    failed_attempts = CASE WHEN user_lockouts.last_failed_at < $3 THEN 1 ELSE user_lockouts.failed_attempts + 1 END,
+    last_failed_at = $2
+RETURNING failed_attempts;
+
+-- name: LOCKOUT_LOCK
+-- :locked_until string
+-- :user_id int
+UPDATE user_lockouts SET locked_until=$1 WHERE user_id=$2;
+
+-- name: LOCKOUT_DELETE
+-- :user_id int
+DELETE FROM user_lockouts WHERE user_id=$1;
@@ -0,0 +1,4 @@
+-- name: APP_LOG_INSERT
+-- :level string
+-- :message string
+INSERT INTO app_logs (level,message) VALUES ($1,$2);
@@ -0,0 +1,38 @@
+-- name: TOTP_GET :one
+-- :user_id int
+SELECT user_id, secret, last_counter, confirmed_at, created_at FROM user_totp WHERE user_id=$1;
+
+-- name: TOTP_INSERT
+-- :user_id int
+-- :secret string
+INSERT INTO user_totp (user_id,secret) VALUES ($1,$2);
+
+-- name: TOTP_DELETE
+-- :user_id int
+DELETE FROM user_totp WHERE user_id=$1;
+
+-- name: TOTP_CONFIRM
+-- :confirmed_at string
+-- :last_counter int
+-- :user_id int
+UPDATE user_totp SET confirmed_at=$1, last_counter=$2 WHERE user_id=$3 AND confirmed_at isnull;
+
+-- name: TOTP_USE
+-- :last_counter int
+-- :user_id int
+UPDATE user_totp SET last_counter=$1 WHERE user_id=$2 AND last_counter < $1;
+
+-- name: RECOVERY_CODE_INSERT
+-- :user_id int
+-- :code_hash string
+INSERT INTO user_recovery_codes (user_id,code_hash) VALUES ($1,$2);
+
+-- name: RECOVERY_CODE_USE
+-- :used_at string
+-- :user_id int
+-- :code_hash string
+UPDATE user_recovery_codes SET used_at=$1 WHERE user_id=$2 AND code_hash=$3 AND used_at isnull;
+
+-- name: RECOVERY_CODE_DELETE
+-- :user_id int
+DELETE FROM user_recovery_codes WHERE user_id=$1;
@@ -0,0 +1,30 @@
+-- name: USER_ROLES
+-- :user_id int
+SELECT r.name FROM roles r INNER JOIN user_roles ur ON ur.role_id=r.id WHERE ur.user_id=$1;
+
+-- name: USER_PERMISSIONS
+-- :user_id int
+SELECT DISTINCT p.name FROM permissions p
+INNER JOIN role_permissions rp ON rp.permission_id=p.id
+INNER JOIN user_roles ur ON ur.role_id=rp.role_id
+WHERE ur.user_id=$1;
+
+-- name: ROLE_EXISTS
+-- :role string
+SELECT EXISTS (SELECT 1 FROM roles WHERE name=$1);
+
+-- name: USER_ROLE_ASSIGN
+-- :user_id int
+-- :role string
+INSERT INTO user_roles (user_id,role_id) SELECT $1, id FROM roles WHERE name=$2 ON CONFLICT DO NOTHING;
+
+-- name: USER_ROLE_REVOKE
+-- :user_id int
+-- :role string
+DELETE FROM user_roles WHERE user_id=$1 AND role_id=(SELECT id FROM roles WHERE name=$2);
+
+-- name: ROLE_GRANT_ALL_PERMISSIONS
+-- :role string
+INSERT INTO role_permissions (role_id,permission_id)
+SELECT r.id, p.id FROM roles r CROSS JOIN permissions p WHERE r.name=$1
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,41 @@
+-- name: SESSION_INSERT
+-- :user_id int
+-- :family string
+-- :device string
+-- :user_agent string
+-- :ip string
+-- :now string
+INSERT INTO sessions (user_id,family,device,user_agent,ip,created_at,last_seen_at) VALUES ($1,$2,$3,$4,$5,$6,$6);
+
+-- name: SESSION_GET :one
+-- :id int
+-- :user_id int
+SELECT id, user_id, family, device, user_agent, ip, created_at, last_seen_at FROM sessions
+WHERE id=$1 AND user_id=$2 AND revoked_at isnull;
+
+-- name: SESSION_GET_WITH_FAMILY :one
+-- :family string
+SELECT id, user_id, family, device, user_agent, ip, created_at, last_seen_at FROM sessions
+WHERE family=$1 AND revoked_at isnull;
+
+-- name: SESSION_LIST
+-- :user_id int
+-- :seen_after string
+SELECT id, user_id, family, device, user_agent, ip, created_at, last_seen_at FROM sessions
+WHERE user_id=$1 AND revoked_at isnull AND last_seen_at > $2
+ORDER BY last_seen_at DESC;
+
+-- name: SESSION_FAMILIES
+-- :user_id int
+SELECT family FROM sessions WHERE user_id=$1 AND revoked_at isnull;
+
+-- name: SESSION_TOUCH
+-- :last_seen_at string
+-- :ip string
+-- :family string
+UPDATE sessions SET last_seen_at=$1, ip=COALESCE(NULLIF($2, ''), ip) WHERE family=$3 AND revoked_at isnull;
+
+-- name: SESSION_REVOKE
+-- :revoked_at string
+-- :family string
+UPDATE sessions SET revoked_at=$1 WHERE family=$2 AND revoked_at isnull;
@@ -0,0 +1,27 @@
+-- name: REFRESH_TOKEN_INSERT
+-- :user_id int
+-- :family string
+-- :token_hash string
+-- :expires_at string
+INSERT INTO refresh_tokens (user_id,family,token_hash,expires_at) VALUES ($1,$2,$3,$4);
+
+-- name: REFRESH_TOKEN_USE :one
+-- :used_at string
+-- :token_hash string
+UPDATE refresh_tokens SET used_at=$1
+WHERE token_hash=$2 AND used_at isnull AND revoked_at isnull AND expires_at > $1
+RETURNING user_id, family;
+
+-- name: REFRESH_TOKEN_GET_WITH_HASH :one
+-- :token_hash string
+SELECT id, user_id, family, expires_at, used_at, revoked_at, created_at FROM refresh_tokens WHERE token_hash=$1;
+
+-- name: REFRESH_TOKEN_REVOKE_FAMILY
+-- :revoked_at string
+-- :family string
+UPDATE refresh_tokens SET revoked_at=$1 WHERE family=$2 AND revoked_at isnull;
+
+-- name: REFRESH_TOKEN_REVOKE_USER
+-- :revoked_at string
+-- :user_id int
+UPDATE refresh_tokens SET revoked_at=$1 WHERE user_id=$2 AND revoked_at isnull;
@@ -0,0 +1,53 @@
+-- name: USER_EXISTS_WITH_EMAIL :one
+-- :email string
+SELECT count(*) FROM users WHERE email=$1;
+
+-- name: USER_GET_WITH_ID :one
+-- :id int
+SELECT id, fullname, email, is_admin, active, password FROM users WHERE id=$1 AND deleted_at isnull;
+
+-- name: USER_GET_WITH_EMAIL :one
+-- :email string
+SELECT id, fullname, email, is_admin, active, password FROM users WHERE email=$1 AND deleted_at isnull;
+
+-- name: USER_INSERT :one
+-- :fullname string
+-- :email string
+-- :password string
+-- :phone string
+INSERT INTO users (fullname,email,password,phone) VALUES ($1,$2,$3,$4) RETURNING id,fullname,email,phone;
+
+-- name: USER_UPDATE_PASS
+-- :password string
+-- :updated_at string
+-- :id int
+UPDATE users SET password=$1, updated_at=$2 WHERE id=$3;
+
+-- name: USER_ADMIN_UPDATE
+-- :is_admin bool
+-- :updated_at string
+-- :id int
+UPDATE users SET is_admin=$1, updated_at=$2 WHERE id=$3;
+
+-- name: USER_LAST_LOGIN
+-- :last_login string
+-- :id int
+UPDATE users SET last_login=$1 WHERE id=$2;
+
+-- name: USER_DELETE
+-- :active bool
+-- :deleted_at string
+-- :updated_at string
+-- :id int
+UPDATE users SET active=$1, deleted_at=$2, updated_at=$3 WHERE id=$4;
+
+-- name: USER_RESTORE
+-- :active bool
+-- :updated_at string
+-- :id int
+UPDATE users SET active=$1, deleted_at=NULL, updated_at=$2 WHERE id=$3 AND deleted_at IS NOT NULL;
+
+-- name: USER_VERIFY_EMAIL
+-- :email_verified_at string
+-- :id int
+UPDATE users SET email_verified_at=$1, updated_at=$1 WHERE id=$2 AND email_verified_at isnull;
@@ -0,0 +1,19 @@
+-- name: USER_TOKEN_INSERT
+-- :user_id int
+-- :purpose string
+-- :token_hash string
+-- :expires_at string
+INSERT INTO user_tokens (user_id,purpose,token_hash,expires_at) VALUES ($1,$2,$3,$4);
+
+-- name: USER_TOKEN_USE :one
+-- :used_at string
+-- :token_hash string
+-- :purpose string
+UPDATE user_tokens SET used_at=$1
+WHERE token_hash=$2 AND purpose=$3 AND used_at isnull AND expires_at > $1
+RETURNING user_id;
+
+-- name: USER_TOKEN_DELETE_UNUSED
+-- :user_id int
+-- :purpose string
+DELETE FROM user_tokens WHERE user_id=$1 AND purpose=$2 AND used_at isnull;
@@ -1,9 +1,25 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"io/fs"
 	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mstgnz/starter-kit/api/asset"
+	"github.com/mstgnz/starter-kit/api/infra/config"
+	"github.com/mstgnz/starter-kit/api/infra/conn"
+	"github.com/mstgnz/starter-kit/api/infra/generate"
+	"github.com/mstgnz/starter-kit/api/infra/load"
+	"github.com/mstgnz/starter-kit/api/infra/migrate"
+	"github.com/mstgnz/starter-kit/api/infra/seed"
+	"github.com/mstgnz/starter-kit/api/pkg/mstgnz/gobuilder"
 )
 
 func HandleCommand(args []string) {
@@ -18,6 +34,12 @@ func HandleCommand(args []string) {
 	switch cmd {
 	case "hello":
 		helloCommand(params)
+	case "migrate":
+		migrateCommand(params)
+	case "seed":
+		seedCommand(params)
+	case "generate":
+		generateCommand(params)
 	case "help", "--help", "-h":
 		showHelp()
 	default:
@@ -37,6 +59,14 @@ func showHelp() {
 	fmt.Println()
 	fmt.Println("Mevcut Komutlar:")
 	fmt.Println("  hello [arguments]       - Hello command run")
+	fmt.Println("  migrate up [n]          - Apply all or the next n pending migrations")
+	fmt.Println("  migrate down [n]        - Revert the last or the last n migrations")
+	fmt.Println("  migrate status          - Show applied and pending migrations")
+	fmt.Println("  migrate create <name>   - Create empty up/down files in asset/migrations and each dialect directory")
+	fmt.Println("  seed [--force] [name..] - Run the seeders that did not run yet, or the named ones")
+	fmt.Println("  seed list               - List the seeders")
+	fmt.Println("  generate queries [file] [query files..]")
+	fmt.Println("                          - Generate typed Go functions for asset/queries, default repository/queries/queries.go")
 	fmt.Println("  help                    - Show this help message")
 	fmt.Println()
 	fmt.Println("Alternatif:")
@@ -52,3 +82,219 @@ func helloCommand(params []string) {
 	}
 
 }
+
+// migrationsDir returns the migrations of the DB_DRIVER dialect, migrations/<dialect> when there is one
+func migrationsDir() string {
+	dir := path.Join("migrations", strings.ToLower(os.Getenv("DB_DRIVER")))
+	if entries, err := fs.ReadDir(asset.Migrations, dir); err == nil && len(entries) > 0 {
+		return dir
+	}
+	return "migrations"
+}
+
+// migrationTrees returns the asset directories of the migrations, asset/migrations and one per dialect
+func migrationTrees() []string {
+	trees := []string{filepath.Join("asset", "migrations")}
+	entries, _ := fs.ReadDir(asset.Migrations, "migrations")
+	for _, entry := range entries {
+		if entry.IsDir() {
+			trees = append(trees, filepath.Join("asset", "migrations", entry.Name()))
+		}
+	}
+	return trees
+}
+
+func migrateCommand(params []string) {
+	if len(params) == 0 {
+		showHelp()
+		os.Exit(1)
+	}
+
+	if params[0] == "create" {
+		if len(params) < 2 {
+			fmt.Println("Usage: migrate create <name>")
+			os.Exit(1)
+		}
+		// the migration is created in every dialect tree, so the trees keep the same versions
+		files, err := migrate.Create(strings.Join(params[1:], "_"), migrationTrees()...)
+		if err != nil {
+			fmt.Println("Migration create error:", err)
+			os.Exit(1)
+		}
+		for _, file := range files {
+			fmt.Println("Created", file)
+		}
+		return
+	}
+
+	sub, err := fs.Sub(asset.Migrations, migrationsDir())
+	if err != nil {
+		fmt.Println("Migration load error:", err)
+		os.Exit(1)
+	}
+	migrator := migrate.New(config.App().DB.DB, sub).WithDialect(config.App().DB.Dialect())
+	ctx := context.Background()
+
+	steps := 0
+	if len(params) > 1 {
+		if steps, err = strconv.Atoi(params[1]); err != nil || steps < 1 {
+			fmt.Println("Steps must be a positive number")
+			os.Exit(1)
+		}
+	}
+
+	switch params[0] {
+	case "up":
+		done, err := migrator.Up(ctx, steps)
+		for _, migration := range done {
+			fmt.Printf("Applied %d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			fmt.Println("Migration error:", err)
+			os.Exit(1)
+		}
+		if len(done) == 0 {
+			fmt.Println("Nothing to migrate")
+		}
+	case "down":
+		if steps == 0 {
+			steps = 1
+		}
+		done, err := migrator.Down(ctx, steps)
+		for _, migration := range done {
+			fmt.Printf("Reverted %d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			fmt.Println("Migration error:", err)
+			os.Exit(1)
+		}
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			fmt.Println("Migration error:", err)
+			os.Exit(1)
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.AppliedAt != nil {
+				state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if status.Modified {
+				state += " (modified)"
+			}
+			fmt.Printf("%d_%-40s %s\n", status.Version, status.Name, state)
+		}
+	default:
+		fmt.Printf("Unknown migrate command: %s\n", params[0])
+		os.Exit(1)
+	}
+}
+
+// seedCommand runs the Go seeders and the asset/seeds files, outside the local environment
+// the data files only run when they are named
+func seedCommand(params []string) {
+	sub, err := fs.Sub(asset.Seeds, "seeds")
+	if err != nil {
+		fmt.Println("Seed load error:", err)
+		os.Exit(1)
+	}
+	seeders, err := seed.Seeders(sub)
+	if err != nil {
+		fmt.Println("Seed load error:", err)
+		os.Exit(1)
+	}
+
+	if len(params) > 0 && params[0] == "list" {
+		for _, seeder := range seeders {
+			fmt.Println(seeder.Name)
+		}
+		return
+	}
+
+	force := false
+	var names []string
+	for _, param := range params {
+		if param == "--force" {
+			force = true
+		} else {
+			names = append(names, param)
+		}
+	}
+	if len(names) == 0 && os.Getenv("APP_ENV") != "local" {
+		seeders, _ = seed.Seeders(nil)
+	}
+
+	ran, err := seed.Run(context.Background(), config.App().DB, seeders, force, names...)
+	for _, name := range ran {
+		fmt.Println("Seeded", name)
+	}
+	if err != nil {
+		fmt.Println("Seed error:", err)
+		os.Exit(1)
+	}
+	if len(ran) == 0 {
+		fmt.Println("Nothing to seed")
+	}
+}
+
+// generateCommand describes the queries of asset/queries on the database and writes typed Go functions for them,
+// only the queries of the given *.sql files when there are any. The database must be postgres.
+func generateCommand(params []string) {
+	if len(params) == 0 || params[0] != "queries" {
+		fmt.Println("Usage: generate queries [file] [query files..]")
+		os.Exit(1)
+	}
+	out := "repository/queries/queries.go"
+	if len(params) > 1 {
+		out = params[1]
+	}
+	files := params[min(len(params), 2):]
+
+	loaded, err := load.NewQueries(os.DirFS("asset/queries"))
+	if err != nil {
+		fmt.Println("Load Sql Error:", err)
+		os.Exit(1)
+	}
+	var queries []load.Query
+	for _, query := range loaded.All() {
+		if len(files) == 0 || slices.Contains(files, query.File) {
+			queries = append(queries, query)
+		}
+	}
+
+	opts := conn.LoadOptions("DB")
+	if opts.Driver != gobuilder.Postgres {
+		fmt.Println("generate queries needs a postgres database, DB_DRIVER is", opts.Driver)
+		os.Exit(1)
+	}
+	ctx := context.Background()
+	pg, err := pgconn.Connect(ctx, opts.DSN())
+	if err != nil {
+		fmt.Println("DB connect error:", err)
+		os.Exit(1)
+	}
+	defer func() {
+		_ = pg.Close(ctx)
+	}()
+
+	statements, err := generate.Describe(ctx, pg, queries)
+	if err != nil {
+		fmt.Println("Describe error:", err)
+		os.Exit(1)
+	}
+	src, err := generate.Render(filepath.Base(filepath.Dir(out)), statements)
+	if err != nil {
+		fmt.Println("Render error:", err)
+		os.Exit(1)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(out), 0o755); err != nil {
+		fmt.Println("Write error:", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(out, src, 0o644); err != nil {
+		fmt.Println("Write error:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Generated %d queries to %s\n", len(statements), out)
+}
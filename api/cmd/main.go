@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io/fs"
 	"log"
 	"net/http"
 	"os"
@@ -15,10 +17,13 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/joho/godotenv"
+	"github.com/mstgnz/starter-kit/api/asset"
 	"github.com/mstgnz/starter-kit/api/handler"
+	"github.com/mstgnz/starter-kit/api/infra/auth"
 	"github.com/mstgnz/starter-kit/api/infra/config"
 	"github.com/mstgnz/starter-kit/api/infra/load"
 	"github.com/mstgnz/starter-kit/api/infra/logger"
+	"github.com/mstgnz/starter-kit/api/infra/oauth"
 	"github.com/mstgnz/starter-kit/api/infra/response"
 	"github.com/mstgnz/starter-kit/api/infra/validate"
 	"github.com/mstgnz/starter-kit/api/middle"
@@ -44,13 +49,25 @@ func init() {
 	_ = config.App()
 	validate.CustomValidate()
 
-	// Load Sql
-	config.App().QUERY = make(map[string]string)
-	if query, err := load.LoadSQLQueries(); err != nil {
-		logger.Warn(fmt.Sprintf("Load Sql Error: %v", err))
+	// Load Signing Keys
+	if err := auth.InitKeys(); err != nil {
+		log.Fatalf("Load Keys Error: %v", err)
+	}
+
+	// Load OAuth Login Providers
+	if err := oauth.LoadProviders(); err != nil {
+		log.Fatalf("Load OAuth Providers Error: %v", err)
+	}
+
+	// Load Sql, local mode reads the files from disk so they can be reloaded
+	queryFS, _ := fs.Sub(asset.Queries, "queries")
+	if os.Getenv("APP_ENV") == "local" {
+		queryFS = os.DirFS("asset/queries")
+	}
+	if queries, err := load.NewQueries(queryFS); err != nil {
 		log.Fatalf("Load Sql Error: %v", err)
 	} else {
-		config.App().QUERY = query
+		config.App().QUERY = queries
 	}
 
 	PORT = os.Getenv("APP_PORT")
@@ -71,6 +88,11 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM, syscall.SIGKILL)
 	defer stop()
 
+	// Reload the changed sql files
+	if os.Getenv("APP_ENV") == "local" {
+		go config.App().QUERY.Watch(ctx, 2*time.Second)
+	}
+
 	// Scheduler Call in goroutine
 	go func() {
 		schedule.CallSchedule(ctx, config.App().Cron)
@@ -91,6 +113,9 @@ func startWebServer(ctx context.Context) {
 		config.App().Redis.CloseRedis()
 		config.App().Kafka.CloseKafka()
 		config.App().DB.CloseDatabase()
+		for _, db := range config.App().DBs {
+			db.CloseDatabase()
+		}
 	}()
 
 	// init conf
@@ -121,12 +146,27 @@ func startWebServer(ctx context.Context) {
 	// IP Middleware - Extract client IP and set in context
 	r.Use(middle.IPMiddleware)
 
+	// DB Session Middleware - Read your own writes when reads go to replicas
+	r.Use(middle.DBSessionMiddleware)
+
 	// Hash Middleware
 	//r.Use(middle.HashMiddleware)
 
 	workDir, _ := os.Getwd()
 	fileServer(r, "/asset", http.Dir(filepath.Join(workDir, "asset")))
 
+	// public keys for verifying issued tokens
+	r.Get("/.well-known/jwks.json", func(w http.ResponseWriter, r *http.Request) {
+		keys, err := auth.Keys()
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		_ = json.NewEncoder(w).Encode(keys.JWKS())
+	})
+
 	// swagger
 	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, "./view/scalar.html")
@@ -134,7 +174,6 @@ func startWebServer(ctx context.Context) {
 
 	r.Route("/api/v1", func(r chi.Router) {
 		r.Use(middle.HeaderMiddleware)
-		r.Use(middle.AuthMiddleware)
 		web.WebRoutes(r)
 	})
 
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/mstgnz/starter-kit/api/infra/config"
+	"github.com/mstgnz/starter-kit/api/infra/response"
+	"github.com/mstgnz/starter-kit/api/model"
+	"github.com/mstgnz/starter-kit/api/service"
+)
+
+var (
+	accountService = service.NewAccountService()
+)
+
+type accountHandler struct {
+}
+
+func NewAccountHandler() *accountHandler {
+	return &accountHandler{}
+}
+
+func (h *accountHandler) ForgotPassword(ctx context.Context, req *model.PasswordForgot) response.Response {
+	if err := accountService.ForgotPassword(ctx, req.Email); err != nil {
+		return response.Response{Code: http.StatusInternalServerError, Success: false, Message: "Failed to send password reset link"}
+	}
+
+	return response.Response{
+		Code:    http.StatusOK,
+		Success: true,
+		Message: "If the email is registered, a password reset link has been sent",
+	}
+}
+
+func (h *accountHandler) ResetPassword(ctx context.Context, req *model.PasswordReset) response.Response {
+	if err := accountService.ResetPassword(ctx, req); err != nil {
+		if errors.Is(err, service.ErrInvalidToken) {
+			return response.Response{Code: http.StatusBadRequest, Success: false, Message: err.Error()}
+		}
+		return response.Response{Code: http.StatusInternalServerError, Success: false, Message: "Failed to reset password"}
+	}
+
+	return response.Response{
+		Code:    http.StatusOK,
+		Success: true,
+		Message: "Password reset",
+	}
+}
+
+func (h *accountHandler) VerifyEmail(ctx context.Context, req *model.EmailVerify) response.Response {
+	if err := accountService.VerifyEmail(ctx, req.Token); err != nil {
+		if errors.Is(err, service.ErrInvalidToken) {
+			return response.Response{Code: http.StatusBadRequest, Success: false, Message: err.Error()}
+		}
+		return response.Response{Code: http.StatusInternalServerError, Success: false, Message: "Failed to verify email"}
+	}
+
+	return response.Response{
+		Code:    http.StatusOK,
+		Success: true,
+		Message: "Email verified",
+	}
+}
+
+func (h *accountHandler) ResendVerification(ctx context.Context, _ *any) response.Response {
+	user := ctx.Value(config.CKey("user")).(*model.User)
+
+	if err := accountService.SendVerification(ctx, user.ID); err != nil {
+		if errors.Is(err, service.ErrEmailVerified) {
+			return response.Response{Code: http.StatusConflict, Success: false, Message: err.Error()}
+		}
+		return userError(err, "Failed to send verification link")
+	}
+
+	return response.Response{
+		Code:    http.StatusOK,
+		Success: true,
+		Message: "Verification link sent",
+	}
+}
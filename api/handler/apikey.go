@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/mstgnz/starter-kit/api/infra/config"
+	"github.com/mstgnz/starter-kit/api/infra/response"
+	"github.com/mstgnz/starter-kit/api/model"
+	"github.com/mstgnz/starter-kit/api/service"
+)
+
+var (
+	apiKeyService = service.NewAPIKeyService()
+)
+
+type apiKeyHandler struct {
+}
+
+func NewAPIKeyHandler() *apiKeyHandler {
+	return &apiKeyHandler{}
+}
+
+func (h *apiKeyHandler) List(ctx context.Context, _ *any) response.Response {
+	user := ctx.Value(config.CKey("user")).(*model.User)
+
+	apiKeys, err := apiKeyService.List(ctx, user.ID)
+	if err != nil {
+		return response.Response{Code: http.StatusInternalServerError, Success: false, Message: "Failed to get api keys"}
+	}
+
+	return response.Response{
+		Code:    http.StatusOK,
+		Success: true,
+		Message: "Api keys",
+		Data:    map[string]any{"api_keys": apiKeys},
+	}
+}
+
+func (h *apiKeyHandler) Create(ctx context.Context, req *model.APIKeyCreate) response.Response {
+	user := ctx.Value(config.CKey("user")).(*model.User)
+
+	apiKey, key, err := apiKeyService.Create(ctx, user.ID, req)
+	if err != nil {
+		return response.Response{Code: http.StatusBadRequest, Success: false, Message: err.Error()}
+	}
+
+	return response.Response{
+		Code:    http.StatusCreated,
+		Success: true,
+		Message: "Api key created, it will not be shown again",
+		Data:    map[string]any{"api_key": apiKey, "key": key},
+	}
+}
+
+func (h *apiKeyHandler) Revoke(ctx context.Context, req *model.APIKeyRequest) response.Response {
+	user := ctx.Value(config.CKey("user")).(*model.User)
+
+	if err := apiKeyService.Revoke(ctx, req.ID, user.ID); err != nil {
+		return response.Response{Code: http.StatusNotFound, Success: false, Message: err.Error()}
+	}
+
+	return response.Response{
+		Code:    http.StatusOK,
+		Success: true,
+		Message: "Api key revoked",
+	}
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/mstgnz/starter-kit/api/infra/config"
+	"github.com/mstgnz/starter-kit/api/infra/response"
+	"github.com/mstgnz/starter-kit/api/model"
+	"github.com/mstgnz/starter-kit/api/service"
+)
+
+var (
+	mfaService = service.NewMFAService()
+)
+
+type mfaHandler struct {
+}
+
+func NewMFAHandler() *mfaHandler {
+	return &mfaHandler{}
+}
+
+func (h *mfaHandler) Enroll(ctx context.Context, _ *any) response.Response {
+	user := ctx.Value(config.CKey("user")).(*model.User)
+
+	enrollment, err := mfaService.Enroll(ctx, user)
+	if err != nil {
+		return mfaError(err, "Failed to enroll two factor authentication")
+	}
+
+	return response.Response{
+		Code:    http.StatusOK,
+		Success: true,
+		Message: "Add the secret to your authenticator app and confirm it with a code",
+		Data:    map[string]any{"mfa": enrollment},
+	}
+}
+
+func (h *mfaHandler) Confirm(ctx context.Context, req *model.MFACode) response.Response {
+	user := ctx.Value(config.CKey("user")).(*model.User)
+
+	codes, err := mfaService.Confirm(ctx, user.ID, req.Code)
+	if err != nil {
+		return mfaError(err, "Failed to enable two factor authentication")
+	}
+
+	return response.Response{
+		Code:    http.StatusOK,
+		Success: true,
+		Message: "Two factor authentication enabled, the recovery codes will not be shown again",
+		Data:    map[string]any{"recovery_codes": codes},
+	}
+}
+
+func (h *mfaHandler) Disable(ctx context.Context, req *model.MFADisable) response.Response {
+	user := ctx.Value(config.CKey("user")).(*model.User)
+
+	if err := mfaService.Disable(ctx, user.ID, req); err != nil {
+		if errors.Is(err, service.ErrWrongPassword) {
+			return response.Response{Code: http.StatusBadRequest, Success: false, Message: err.Error()}
+		}
+		return mfaError(err, "Failed to disable two factor authentication")
+	}
+
+	return response.Response{
+		Code:    http.StatusOK,
+		Success: true,
+		Message: "Two factor authentication disabled",
+	}
+}
+
+func (h *mfaHandler) Login(ctx context.Context, req *model.MFALogin) response.Response {
+	userId, err := mfaService.Login(ctx, req)
+	if err != nil {
+		if res, ok := loginBlocked(err); ok {
+			return res
+		}
+		if errors.Is(err, service.ErrInvalidMFAToken) || errors.Is(err, service.ErrInvalidMFACode) || errors.Is(err, service.ErrMFANotEnabled) {
+			return response.Response{Code: http.StatusUnauthorized, Success: false, Message: err.Error()}
+		}
+		return response.Response{Code: http.StatusInternalServerError, Success: false, Message: "Login failed"}
+	}
+
+	user, err := userService.Profile(ctx, userId)
+	if err != nil {
+		return response.Response{Code: http.StatusUnauthorized, Success: false, Message: "Login failed"}
+	}
+
+	token, err := tokenService.Issue(ctx, user.ID)
+	if err != nil {
+		return response.Response{Code: http.StatusInternalServerError, Success: false, Message: "Failed to generate token"}
+	}
+
+	return response.Response{
+		Code:    http.StatusOK,
+		Success: true,
+		Message: "Login successful",
+		Data:    map[string]any{"token": token, "user": user},
+	}
+}
+
+// mfaError returns 400 for the errors of the two factor state and 500 with the message otherwise
+func mfaError(err error, message string) response.Response {
+	switch {
+	case errors.Is(err, service.ErrMFAEnabled), errors.Is(err, service.ErrMFANotEnabled), errors.Is(err, service.ErrMFANotEnrolled), errors.Is(err, service.ErrInvalidMFACode):
+		return response.Response{Code: http.StatusBadRequest, Success: false, Message: err.Error()}
+	}
+	return userError(err, message)
+}
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/mstgnz/starter-kit/api/infra/oauth"
+	"github.com/mstgnz/starter-kit/api/infra/response"
+	"github.com/mstgnz/starter-kit/api/model"
+	"github.com/mstgnz/starter-kit/api/service"
+)
+
+// oauthCookie keeps the login token from the start of a provider login to its callback
+const oauthCookie = "oauth_login"
+
+var (
+	oauthService = service.NewOAuthService()
+)
+
+type oauthHandler struct {
+}
+
+func NewOAuthHandler() *oauthHandler {
+	return &oauthHandler{}
+}
+
+func (h *oauthHandler) Providers(ctx context.Context, _ *any) response.Response {
+	return response.Response{
+		Code:    http.StatusOK,
+		Success: true,
+		Message: "Login providers",
+		Data:    map[string]any{"providers": oauth.Names()},
+	}
+}
+
+func (h *oauthHandler) Start(ctx context.Context, req *model.OAuthStart) response.Response {
+	authURL, token, err := oauthService.Start(ctx, req.Provider)
+	if err != nil {
+		return oauthError(err, "Failed to start login")
+	}
+
+	var res response.Response
+	if strings.Contains(req.Accept, "application/json") {
+		res = response.Response{
+			Code:    http.StatusOK,
+			Success: true,
+			Message: "Redirect the user to the url",
+			Data:    map[string]any{"url": authURL},
+		}
+	} else {
+		res = response.Response{Code: http.StatusFound, Success: true, Message: "Redirecting to the provider", Data: map[string]any{"url": authURL}}
+		res.SetHeader("Location", authURL)
+	}
+	res.SetHeader("Set-Cookie", loginCookie(token, int(service.OAuthStateTTL.Seconds())).String())
+	return res
+}
+
+func (h *oauthHandler) Callback(ctx context.Context, req *model.OAuthCallback) response.Response {
+	var token string
+	if cookies, err := http.ParseCookie(req.Cookie); err == nil {
+		for _, cookie := range cookies {
+			if cookie.Name == oauthCookie {
+				token = cookie.Value
+			}
+		}
+	}
+
+	var res response.Response
+	user, err := oauthService.Callback(ctx, req, token)
+	if blocked, ok := loginBlocked(err); ok {
+		res = blocked
+	} else if err != nil {
+		res = oauthError(err, "Login failed")
+	} else {
+		res = loginResponse(ctx, user)
+	}
+	// the login cookie is single use
+	res.SetHeader("Set-Cookie", loginCookie("", -1).String())
+	return res
+}
+
+// loginCookie returns the cookie of the login token, the provider redirects back with a top level GET
+// so SameSite=Lax sends it to the callback. A negative maxAge deletes the cookie.
+func loginCookie(token string, maxAge int) *http.Cookie {
+	return &http.Cookie{Name: oauthCookie, Value: token, Path: "/", MaxAge: maxAge, HttpOnly: true, Secure: true, SameSite: http.SameSiteLaxMode}
+}
+
+// oauthError returns the status of the login errors and 500 with the message otherwise
+func oauthError(err error, message string) response.Response {
+	switch {
+	case errors.Is(err, service.ErrOAuthProvider):
+		return response.Response{Code: http.StatusNotFound, Success: false, Message: err.Error()}
+	case errors.Is(err, service.ErrOAuthState), errors.Is(err, service.ErrOAuthDenied), errors.Is(err, service.ErrOAuthEmailUnverified):
+		return response.Response{Code: http.StatusBadRequest, Success: false, Message: err.Error()}
+	case errors.Is(err, service.ErrOAuthFailed):
+		return response.Response{Code: http.StatusBadGateway, Success: false, Message: err.Error()}
+	case errors.Is(err, service.ErrOAuthAccountUnverified):
+		return response.Response{Code: http.StatusConflict, Success: false, Message: err.Error()}
+	case errors.Is(err, service.ErrOAuthAccountInactive):
+		return response.Response{Code: http.StatusForbidden, Success: false, Message: err.Error()}
+	}
+	return response.Response{Code: http.StatusInternalServerError, Success: false, Message: message}
+}
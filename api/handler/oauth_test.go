@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mstgnz/starter-kit/api/infra/auth"
+	"github.com/mstgnz/starter-kit/api/infra/oauth"
+	"github.com/mstgnz/starter-kit/api/infra/oauth/oidctest"
+	"github.com/mstgnz/starter-kit/api/infra/response"
+	"github.com/mstgnz/starter-kit/api/infra/testdb"
+	"github.com/mstgnz/starter-kit/api/model"
+	"github.com/mstgnz/starter-kit/api/service"
+)
+
+// TestOAuthCallbackMFA logs in with the stub provider through the handlers, the login cookie of the start
+// is sent to the callback and a user with two factor authentication gets an mfa token instead of the tokens
+func TestOAuthCallbackMFA(t *testing.T) {
+	testdb.Open(t)
+	ctx := context.Background()
+	server := oidctest.NewServer(t, "client")
+	oauth.Register(server.Provider("stub"))
+	claims := map[string]any{"sub": "mfa", "email": "mfa@handler.test", "email_verified": true}
+
+	login := func(withCookie bool) response.Response {
+		t.Helper()
+		res := NewOAuthHandler().Start(ctx, &model.OAuthStart{Provider: "stub", Accept: "application/json"})
+		if res.Code != http.StatusOK {
+			t.Fatalf("start %d %s", res.Code, res.Message)
+		}
+		cookie, err := http.ParseSetCookie(res.Headers.Get("Set-Cookie"))
+		if err != nil || cookie.Name != oauthCookie || !cookie.HttpOnly || !cookie.Secure || cookie.SameSite != http.SameSiteLaxMode {
+			t.Fatalf("login cookie %+v, %v", cookie, err)
+		}
+
+		code, state := server.Authorize(t, res.Data["url"].(string), claims)
+		req := &model.OAuthCallback{Provider: "stub", Code: code, State: state}
+		if withCookie {
+			req.Cookie = "theme=dark; " + cookie.Name + "=" + cookie.Value
+		}
+		res = NewOAuthHandler().Callback(ctx, req)
+		if deleted, err := http.ParseSetCookie(res.Headers.Get("Set-Cookie")); err != nil || deleted.Name != oauthCookie || deleted.MaxAge >= 0 {
+			t.Fatalf("the callback must delete the login cookie: %+v, %v", deleted, err)
+		}
+		return res
+	}
+
+	if res := login(false); res.Code != http.StatusBadRequest {
+		t.Fatalf("callback without the login cookie %d %s, want 400", res.Code, res.Message)
+	}
+
+	res := login(true)
+	if res.Code != http.StatusOK || res.Data["token"] == nil {
+		t.Fatalf("login %d %s %v, want the tokens", res.Code, res.Message, res.Data)
+	}
+	user := res.Data["user"].(*model.User)
+
+	enrollment, err := service.NewMFAService().Enroll(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := auth.TOTPCode(enrollment.Secret, time.Now().Unix()/int64(auth.TOTPPeriod.Seconds()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.NewMFAService().Confirm(ctx, user.ID, code); err != nil {
+		t.Fatal(err)
+	}
+
+	res = login(true)
+	if res.Code != http.StatusOK || res.Data["mfa_required"] != true || res.Data["mfa_token"] == "" || res.Data["token"] != nil {
+		t.Fatalf("login with mfa %d %s %v, want an mfa token only", res.Code, res.Message, res.Data)
+	}
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/mstgnz/starter-kit/api/infra/config"
+	"github.com/mstgnz/starter-kit/api/infra/response"
+	"github.com/mstgnz/starter-kit/api/model"
+	"github.com/mstgnz/starter-kit/api/service"
+)
+
+var (
+	roleService = service.NewRoleService()
+)
+
+type roleHandler struct {
+}
+
+func NewRoleHandler() *roleHandler {
+	return &roleHandler{}
+}
+
+func (h *roleHandler) Access(ctx context.Context, req *model.UserRequest) response.Response {
+	access, err := roleService.Access(ctx, req.ID)
+	if err != nil {
+		return response.Response{Code: http.StatusInternalServerError, Success: false, Message: "Failed to get user access"}
+	}
+
+	return response.Response{
+		Code:    http.StatusOK,
+		Success: true,
+		Message: "User access",
+		Data:    map[string]any{"access": access},
+	}
+}
+
+func (h *roleHandler) Assign(ctx context.Context, req *model.RoleAssign) response.Response {
+	actor := ctx.Value(config.CKey("user")).(*model.User)
+
+	if err := roleService.Assign(ctx, req.UserID, actor.ID, req.Role); err != nil {
+		if errors.Is(err, service.ErrRoleNotFound) {
+			return response.Response{Code: http.StatusNotFound, Success: false, Message: err.Error()}
+		}
+		return response.Response{Code: http.StatusInternalServerError, Success: false, Message: "Failed to assign role"}
+	}
+
+	return response.Response{
+		Code:    http.StatusOK,
+		Success: true,
+		Message: "Role assigned",
+	}
+}
+
+func (h *roleHandler) Revoke(ctx context.Context, req *model.RoleAssign) response.Response {
+	actor := ctx.Value(config.CKey("user")).(*model.User)
+
+	if err := roleService.Revoke(ctx, req.UserID, actor.ID, req.Role); err != nil {
+		if errors.Is(err, service.ErrRoleNotFound) || errors.Is(err, service.ErrUserRoleNotFound) {
+			return response.Response{Code: http.StatusNotFound, Success: false, Message: err.Error()}
+		}
+		return response.Response{Code: http.StatusInternalServerError, Success: false, Message: "Failed to revoke role"}
+	}
+
+	return response.Response{
+		Code:    http.StatusOK,
+		Success: true,
+		Message: "Role revoked",
+	}
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/mstgnz/starter-kit/api/infra/config"
+	"github.com/mstgnz/starter-kit/api/infra/response"
+	"github.com/mstgnz/starter-kit/api/model"
+	"github.com/mstgnz/starter-kit/api/service"
+)
+
+var (
+	sessionService = service.NewSessionService()
+)
+
+type sessionHandler struct {
+}
+
+func NewSessionHandler() *sessionHandler {
+	return &sessionHandler{}
+}
+
+func (h *sessionHandler) List(ctx context.Context, _ *any) response.Response {
+	user := ctx.Value(config.CKey("user")).(*model.User)
+	family, _ := ctx.Value(config.CKey("family")).(string)
+
+	sessions, err := sessionService.List(ctx, user.ID, family)
+	if err != nil {
+		return response.Response{Code: http.StatusInternalServerError, Success: false, Message: "Failed to get sessions"}
+	}
+
+	return response.Response{
+		Code:    http.StatusOK,
+		Success: true,
+		Message: "Sessions",
+		Data:    map[string]any{"sessions": sessions},
+	}
+}
+
+func (h *sessionHandler) Revoke(ctx context.Context, req *model.SessionRequest) response.Response {
+	user := ctx.Value(config.CKey("user")).(*model.User)
+
+	if err := sessionService.Revoke(ctx, user.ID, req.ID); err != nil {
+		if errors.Is(err, service.ErrSessionNotFound) {
+			return response.Response{Code: http.StatusNotFound, Success: false, Message: err.Error()}
+		}
+		return response.Response{Code: http.StatusInternalServerError, Success: false, Message: "Failed to revoke session"}
+	}
+
+	return response.Response{
+		Code:    http.StatusOK,
+		Success: true,
+		Message: "Session revoked",
+	}
+}
+
+func (h *sessionHandler) RevokeOthers(ctx context.Context, _ *any) response.Response {
+	user := ctx.Value(config.CKey("user")).(*model.User)
+	family, _ := ctx.Value(config.CKey("family")).(string)
+
+	revoked, err := sessionService.RevokeOthers(ctx, user.ID, family)
+	if err != nil {
+		return response.Response{Code: http.StatusInternalServerError, Success: false, Message: "Failed to revoke sessions"}
+	}
+
+	return response.Response{
+		Code:    http.StatusOK,
+		Success: true,
+		Message: "Other sessions revoked",
+		Data:    map[string]any{"revoked": revoked},
+	}
+}
@@ -2,10 +2,21 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"strconv"
 
+	"github.com/mstgnz/starter-kit/api/infra/config"
+	"github.com/mstgnz/starter-kit/api/infra/paginate"
 	"github.com/mstgnz/starter-kit/api/infra/response"
 	"github.com/mstgnz/starter-kit/api/model"
+	"github.com/mstgnz/starter-kit/api/service"
+)
+
+var (
+	userService    = service.NewUserService()
+	tokenService   = service.NewTokenService()
+	lockoutService = service.NewLockoutService()
 )
 
 type userHandler struct {
@@ -16,25 +27,276 @@ func NewUserHandler() *userHandler {
 }
 
 func (h *userHandler) Login(ctx context.Context, req *model.Login) response.Response {
+	user, err := userService.Login(ctx, req)
+	if err != nil {
+		if res, ok := loginBlocked(err); ok {
+			return res
+		}
+		if errors.Is(err, service.ErrInvalidCredentials) {
+			return response.Response{Code: http.StatusUnauthorized, Success: false, Message: err.Error()}
+		}
+		return response.Response{Code: http.StatusInternalServerError, Success: false, Message: "Login failed"}
+	}
+
+	return loginResponse(ctx, user)
+}
+
+// loginResponse returns the tokens of an authenticated user, or the mfa token for POST /login/mfa
+// when the user has two factor authentication
+func loginResponse(ctx context.Context, user *model.User) response.Response {
+	challenge, err := mfaService.Challenge(ctx, user.ID)
+	if err != nil {
+		return response.Response{Code: http.StatusInternalServerError, Success: false, Message: "Login failed"}
+	}
+	if challenge != nil {
+		return response.Response{
+			Code:    http.StatusOK,
+			Success: true,
+			Message: "Two factor code required",
+			Data:    map[string]any{"mfa_required": true, "mfa_token": challenge.MFAToken, "expires_at": challenge.ExpiresAt},
+		}
+	}
+
+	token, err := tokenService.Issue(ctx, user.ID)
+	if err != nil {
+		return response.Response{Code: http.StatusInternalServerError, Success: false, Message: "Failed to generate token"}
+	}
+
 	return response.Response{
 		Code:    http.StatusOK,
 		Success: true,
 		Message: "Login successful",
+		Data:    map[string]any{"token": token, "user": user},
 	}
 }
 
 func (h *userHandler) Register(ctx context.Context, req *model.Register) response.Response {
+	user, err := userService.Register(ctx, req)
+	if err != nil {
+		if errors.Is(err, service.ErrUserExists) {
+			return response.Response{Code: http.StatusConflict, Success: false, Message: err.Error()}
+		}
+		return response.Response{Code: http.StatusInternalServerError, Success: false, Message: "Register failed"}
+	}
+
+	token, err := tokenService.Issue(ctx, user.ID)
+	if err != nil {
+		return response.Response{Code: http.StatusInternalServerError, Success: false, Message: "Failed to generate token"}
+	}
+
 	return response.Response{
-		Code:    http.StatusOK,
+		Code:    http.StatusCreated,
 		Success: true,
 		Message: "Register successful",
+		Data:    map[string]any{"token": token, "user": user},
 	}
 }
 
 func (h *userHandler) Verify(ctx context.Context, _ *any) response.Response {
+	user, ok := ctx.Value(config.CKey("user")).(*model.User)
+	if !ok || user == nil {
+		return response.Response{Code: http.StatusUnauthorized, Success: false, Message: "Invalid Token"}
+	}
+
 	return response.Response{
 		Code:    http.StatusOK,
 		Success: true,
 		Message: "Verify successful",
+		Data:    map[string]any{"user": user},
+	}
+}
+
+func (h *userHandler) Refresh(ctx context.Context, req *model.Refresh) response.Response {
+	token, err := tokenService.Refresh(ctx, req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidRefreshToken) || errors.Is(err, service.ErrRefreshTokenReused) {
+			return response.Response{Code: http.StatusUnauthorized, Success: false, Message: err.Error()}
+		}
+		return response.Response{Code: http.StatusInternalServerError, Success: false, Message: "Failed to refresh token"}
+	}
+
+	return response.Response{
+		Code:    http.StatusOK,
+		Success: true,
+		Message: "Token refreshed",
+		Data:    map[string]any{"token": token},
+	}
+}
+
+func (h *userHandler) Logout(ctx context.Context, _ *any) response.Response {
+	family, _ := ctx.Value(config.CKey("family")).(string)
+	if family == "" {
+		return response.Response{Code: http.StatusUnauthorized, Success: false, Message: "Invalid Token"}
+	}
+
+	if err := tokenService.Revoke(ctx, family); err != nil {
+		return response.Response{Code: http.StatusInternalServerError, Success: false, Message: "Logout failed"}
+	}
+
+	return response.Response{
+		Code:    http.StatusOK,
+		Success: true,
+		Message: "Logout successful",
+	}
+}
+
+func (h *userHandler) Me(ctx context.Context, _ *any) response.Response {
+	user := ctx.Value(config.CKey("user")).(*model.User)
+
+	profile, err := userService.Profile(ctx, user.ID)
+	if err != nil {
+		return userError(err, "Failed to get profile")
+	}
+
+	return response.Response{
+		Code:    http.StatusOK,
+		Success: true,
+		Message: "Profile",
+		Data:    map[string]any{"user": profile},
+	}
+}
+
+func (h *userHandler) UpdateMe(ctx context.Context, req *model.ProfileUpdate) response.Response {
+	user := ctx.Value(config.CKey("user")).(*model.User)
+
+	profile, err := userService.UpdateProfile(ctx, user.ID, req)
+	if err != nil {
+		return userError(err, "Failed to update profile")
+	}
+
+	return response.Response{
+		Code:    http.StatusOK,
+		Success: true,
+		Message: "Profile updated",
+		Data:    map[string]any{"user": profile},
+	}
+}
+
+func (h *userHandler) ChangePassword(ctx context.Context, req *model.PasswordUpdate) response.Response {
+	user := ctx.Value(config.CKey("user")).(*model.User)
+
+	if err := userService.ChangePassword(ctx, user.ID, req); err != nil {
+		if errors.Is(err, service.ErrWrongPassword) {
+			return response.Response{Code: http.StatusBadRequest, Success: false, Message: err.Error()}
+		}
+		return userError(err, "Failed to change password")
+	}
+
+	return response.Response{
+		Code:    http.StatusOK,
+		Success: true,
+		Message: "Password changed",
+	}
+}
+
+func (h *userHandler) List(ctx context.Context, req *model.UserList) response.Response {
+	page, err := userService.List(ctx, req)
+	if err != nil {
+		var paramErr *paginate.Error
+		if errors.As(err, &paramErr) {
+			return paramErr.Response()
+		}
+		return response.Response{Code: http.StatusInternalServerError, Success: false, Message: "Failed to get users"}
+	}
+
+	return page.Response(ctx, "Users")
+}
+
+func (h *userHandler) Get(ctx context.Context, req *model.UserRequest) response.Response {
+	user, err := userService.Get(ctx, req.ID)
+	if err != nil {
+		return userError(err, "Failed to get user")
+	}
+
+	// lockout is null unless the user cannot log in now
+	lockout, err := lockoutService.Lockout(ctx, user.ID)
+	if err != nil {
+		return response.Response{Code: http.StatusInternalServerError, Success: false, Message: "Failed to get user"}
+	}
+
+	return response.Response{
+		Code:    http.StatusOK,
+		Success: true,
+		Message: "User",
+		Data:    map[string]any{"user": user, "lockout": lockout},
+	}
+}
+
+func (h *userHandler) Update(ctx context.Context, req *model.UserUpdate) response.Response {
+	user, err := userService.Update(ctx, req)
+	if err != nil {
+		if errors.Is(err, service.ErrUserExists) {
+			return response.Response{Code: http.StatusConflict, Success: false, Message: err.Error()}
+		}
+		return userError(err, "Failed to update user")
+	}
+
+	return response.Response{
+		Code:    http.StatusOK,
+		Success: true,
+		Message: "User updated",
+		Data:    map[string]any{"user": user},
+	}
+}
+
+func (h *userHandler) Deactivate(ctx context.Context, req *model.UserRequest) response.Response {
+	actor := ctx.Value(config.CKey("user")).(*model.User)
+
+	if err := userService.Deactivate(ctx, req.ID, actor.ID); err != nil {
+		if errors.Is(err, service.ErrDeactivateSelf) {
+			return response.Response{Code: http.StatusBadRequest, Success: false, Message: err.Error()}
+		}
+		return userError(err, "Failed to deactivate user")
+	}
+
+	return response.Response{
+		Code:    http.StatusOK,
+		Success: true,
+		Message: "User deactivated",
+	}
+}
+
+func (h *userHandler) Restore(ctx context.Context, req *model.UserRequest) response.Response {
+	if err := userService.Restore(ctx, req.ID); err != nil {
+		return userError(err, "Failed to restore user")
+	}
+
+	return response.Response{
+		Code:    http.StatusOK,
+		Success: true,
+		Message: "User restored",
+	}
+}
+
+func (h *userHandler) Unlock(ctx context.Context, req *model.UserRequest) response.Response {
+	actor := ctx.Value(config.CKey("user")).(*model.User)
+
+	if err := lockoutService.Unlock(ctx, req.ID, actor.ID); err != nil {
+		return userError(err, "Failed to unlock user")
+	}
+
+	return response.Response{
+		Code:    http.StatusOK,
+		Success: true,
+		Message: "User unlocked",
+	}
+}
+
+// loginBlocked returns 429 with Retry-After for a login attempt of a locked or throttled account
+func loginBlocked(err error) (response.Response, bool) {
+	var blocked *service.LoginBlockedError
+	if !errors.As(err, &blocked) {
+		return response.Response{}, false
+	}
+	res := response.Response{Code: http.StatusTooManyRequests, Success: false, Message: blocked.Error()}
+	res.SetHeader("Retry-After", strconv.Itoa(int(blocked.RetryAfter().Seconds())))
+	return res, true
+}
+
+// userError returns 404 for a missing user and 500 with the message otherwise
+func userError(err error, message string) response.Response {
+	if errors.Is(err, service.ErrUserNotFound) {
+		return response.Response{Code: http.StatusNotFound, Success: false, Message: err.Error()}
 	}
+	return response.Response{Code: http.StatusInternalServerError, Success: false, Message: message}
 }
@@ -2,50 +2,146 @@ package auth
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/mstgnz/starter-kit/api/infra/config"
 )
 
 var letterRunes = []rune("0987654321abcçdefgğhıijklmnoöpqrsştuüvwxyzABCÇDEFGĞHIİJKLMNOÖPQRSTUÜVWXYZ-_!?+&%=*")
 
+var (
+	// AccessTokenTTL is the lifetime of the JWT access token
+	AccessTokenTTL = 15 * time.Minute
+	// RefreshTokenTTL is the lifetime of the opaque refresh token
+	RefreshTokenTTL = 30 * 24 * time.Hour
+	// MFATokenTTL is the lifetime of the token that a second factor code is exchanged with after the password
+	MFATokenTTL = 5 * time.Minute
+)
+
+const (
+	// PurposeMFA marks the tokens issued after the password of a user with two factor authentication
+	PurposeMFA = "mfa"
+	// PurposeOAuth marks the tokens that carry a started provider login until its callback
+	PurposeOAuth = "oauth"
+)
+
+// Claims are the access token claims, Family links the token to its refresh token family.
+// Purpose is empty for access tokens, tokens with a purpose are only accepted where it is expected.
+// Data is the payload of the tokens that are not about a user.
+type Claims struct {
+	Family  string `json:"fam,omitempty"`
+	Purpose string `json:"pur,omitempty"`
+	Data    string `json:"dat,omitempty"`
+	jwt.RegisteredClaims
+}
+
 // GenerateToken token generate
-func GenerateToken(userId int) (string, error) {
-	claims := jwt.RegisteredClaims{
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().AddDate(0, 0, 1)),
-		Issuer:    strconv.Itoa(userId),
+func GenerateToken(userId int, family string) (string, time.Time, error) {
+	return sign(strconv.Itoa(userId), Claims{Family: family}, AccessTokenTTL)
+}
+
+// GenerateMFAToken returns a short lived token that is only accepted by ParseMFAToken
+func GenerateMFAToken(userId int) (string, time.Time, error) {
+	return sign(strconv.Itoa(userId), Claims{Purpose: PurposeMFA}, MFATokenTTL)
+}
+
+// GenerateOAuthToken returns a token with the data of a started provider login that is only accepted by ParseOAuthToken
+func GenerateOAuthToken(data string, ttl time.Duration) (string, time.Time, error) {
+	return sign("", Claims{Purpose: PurposeOAuth, Data: data}, ttl)
+}
+
+func sign(subject string, claims Claims, ttl time.Duration) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+		Subject:   subject,
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	t, err := token.SignedString([]byte(config.App().SecretKey))
+	keys, err := Keys()
 	if err != nil {
-		return "", err
+		return "", expiresAt, err
+	}
+	key, err := keys.Active()
+	if err != nil {
+		return "", expiresAt, err
 	}
-	return t, nil
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	t, err := token.SignedString(key.Private)
+	if err != nil {
+		return "", expiresAt, err
+	}
+	return t, expiresAt, nil
 }
 
-// ValidateToken token validate
+// ValidateToken token validate, the token must be signed by a non-retired key of the key set
 func ValidateToken(token string) (*jwt.Token, error) {
-	return jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+	return jwt.ParseWithClaims(token, &Claims{}, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		keys, err := Keys()
+		if err != nil {
+			return nil, err
+		}
+		key, err := keys.Lookup(kid)
+		if err != nil {
+			return nil, err
+		}
+		if t.Method.Alg() != key.Method.Alg() {
 			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
 		}
-		return []byte(config.App().SecretKey), nil
-	})
+		return key.Public, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg(), jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}))
 }
 
-func GetUserIDByToken(token string) (string, error) {
+// ParseToken validates the access token and returns its claims
+func ParseToken(token string) (*Claims, error) {
+	return parse(token, "")
+}
+
+// ParseMFAToken validates a token created by GenerateMFAToken and returns its claims
+func ParseMFAToken(token string) (*Claims, error) {
+	return parse(token, PurposeMFA)
+}
+
+// ParseOAuthToken validates a token created by GenerateOAuthToken and returns its data
+func ParseOAuthToken(token string) (string, error) {
+	claims, err := parse(token, PurposeOAuth)
+	if err != nil {
+		return "", err
+	}
+	return claims.Data, nil
+}
+
+func parse(token, purpose string) (*Claims, error) {
 	valid, err := ValidateToken(token)
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := valid.Claims.(*Claims)
+	if !ok || claims.Purpose != purpose {
+		return nil, errors.New("invalid token claims")
+	}
+	return claims, nil
+}
+
+func GetUserIDByToken(token string) (string, error) {
+	claims, err := ParseToken(token)
 	if err != nil {
 		return "", err
 	}
-	claims := valid.Claims.(jwt.MapClaims)
-	id := fmt.Sprintf("%v", claims["iss"])
-	return id, nil
+	return claims.Subject, nil
+}
+
+// HashToken returns the sha256 hex digest of an opaque token, only the digest is stored
+func HashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
 }
 
 func RandomString(length int) string {
@@ -0,0 +1,268 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/mstgnz/starter-kit/api/infra/config"
+)
+
+// Key is a signing key identified by its kid.
+// Private is nil for verification only keys, for HMAC keys Private and Public are the same secret.
+type Key struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private any
+	Public  any
+	Retired bool
+}
+
+// KeySet holds the keys used to sign and validate tokens, the active key signs new tokens
+// and every non-retired key is accepted for validation.
+type KeySet struct {
+	mu     sync.RWMutex
+	active string
+	keys   map[string]*Key
+}
+
+var (
+	keySet     *KeySet
+	keySetOnce sync.Once
+	keySetErr  error
+)
+
+func NewKeySet() *KeySet {
+	return &KeySet{
+		keys: make(map[string]*Key),
+	}
+}
+
+// Keys returns the application key set, it is loaded from env on the first call.
+// The load error is returned on every call, tokens are neither signed nor accepted without the keys.
+func Keys() (*KeySet, error) {
+	if err := InitKeys(); err != nil {
+		return nil, err
+	}
+	return keySet, nil
+}
+
+// InitKeys loads the application key set from env, it is safe to call more than once.
+// It is called at startup so a key set that does not load stops the application.
+func InitKeys() error {
+	keySetOnce.Do(func() {
+		keySet, keySetErr = LoadKeySet()
+	})
+	return keySetErr
+}
+
+// LoadKeySet builds a key set from env:
+//
+//	JWT_SECRET        HMAC secret, added as an HS256 key with the JWT_SECRET_KID kid (default "default")
+//	JWT_KEYS          comma separated kid=path list of PEM files, RSA keys are RS256 and Ed25519 keys are EdDSA
+//	JWT_ACTIVE_KID    kid of the signing key, defaults to the only key when there is just one
+//	JWT_RETIRED_KIDS  comma separated kids that are no longer accepted
+func LoadKeySet() (*KeySet, error) {
+	ks := NewKeySet()
+
+	if secret := config.App().SecretKey; secret != "" {
+		kid := os.Getenv("JWT_SECRET_KID")
+		if kid == "" {
+			kid = "default"
+		}
+		ks.Add(&Key{ID: kid, Method: jwt.SigningMethodHS256, Private: []byte(secret), Public: []byte(secret)})
+	}
+
+	for _, entry := range splitList(os.Getenv("JWT_KEYS")) {
+		kid, path, ok := strings.Cut(entry, "=")
+		if !ok || kid == "" || path == "" {
+			return nil, fmt.Errorf("invalid JWT_KEYS entry %q, expected kid=path", entry)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		key, err := ParsePEMKey(kid, data)
+		if err != nil {
+			return nil, err
+		}
+		ks.Add(key)
+	}
+
+	if len(ks.keys) == 0 {
+		// the secret key will change every time the application is restarted.
+		log.Println("JWT_SECRET and JWT_KEYS are empty, using a random HS256 key")
+		secret := []byte(RandomHex(32))
+		ks.Add(&Key{ID: "default", Method: jwt.SigningMethodHS256, Private: secret, Public: secret})
+	}
+
+	for _, kid := range splitList(os.Getenv("JWT_RETIRED_KIDS")) {
+		ks.Retire(kid)
+	}
+
+	active := os.Getenv("JWT_ACTIVE_KID")
+	if active == "" && len(ks.keys) == 1 {
+		for kid := range ks.keys {
+			active = kid
+		}
+	}
+	if err := ks.SetActive(active); err != nil {
+		return nil, err
+	}
+
+	return ks, nil
+}
+
+// ParsePEMKey parses an RSA or Ed25519 private or public key in PEM format
+func ParsePEMKey(kid string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("key %s: no PEM data found", kid)
+	}
+
+	var parsed any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("key %s: unsupported PEM type %s", kid, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("key %s: %w", kid, err)
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		return &Key{ID: kid, Method: jwt.SigningMethodRS256, Private: k, Public: &k.PublicKey}, nil
+	case *rsa.PublicKey:
+		return &Key{ID: kid, Method: jwt.SigningMethodRS256, Public: k}, nil
+	case ed25519.PrivateKey:
+		return &Key{ID: kid, Method: jwt.SigningMethodEdDSA, Private: k, Public: k.Public()}, nil
+	case ed25519.PublicKey:
+		return &Key{ID: kid, Method: jwt.SigningMethodEdDSA, Public: k}, nil
+	default:
+		return nil, fmt.Errorf("key %s: unsupported key type %T", kid, parsed)
+	}
+}
+
+// Add adds or replaces a key
+func (ks *KeySet) Add(key *Key) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.keys[key.ID] = key
+}
+
+// SetActive sets the key used to sign new tokens
+func (ks *KeySet) SetActive(kid string) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	key, ok := ks.keys[kid]
+	if !ok {
+		return fmt.Errorf("active key %q not found", kid)
+	}
+	if key.Retired || key.Private == nil {
+		return fmt.Errorf("active key %q cannot sign", kid)
+	}
+	ks.active = kid
+	return nil
+}
+
+// Retire stops accepting tokens signed with the key, the active key cannot be retired
+func (ks *KeySet) Retire(kid string) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if key, ok := ks.keys[kid]; ok && kid != ks.active {
+		key.Retired = true
+	}
+}
+
+// Active returns the signing key
+func (ks *KeySet) Active() (*Key, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	key, ok := ks.keys[ks.active]
+	if !ok {
+		return nil, errors.New("no active signing key")
+	}
+	return key, nil
+}
+
+// Lookup returns the non-retired key with the given kid
+func (ks *KeySet) Lookup(kid string) (*Key, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	key, ok := ks.keys[kid]
+	if !ok || key.Retired {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	return key, nil
+}
+
+// JWKS returns the public keys in JSON Web Key Set format, HMAC and retired keys are not published
+func (ks *KeySet) JWKS() map[string]any {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	kids := make([]string, 0, len(ks.keys))
+	for kid := range ks.keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	keys := []map[string]any{}
+	for _, kid := range kids {
+		key := ks.keys[kid]
+		if key.Retired {
+			continue
+		}
+		switch pub := key.Public.(type) {
+		case *rsa.PublicKey:
+			keys = append(keys, map[string]any{
+				"kty": "RSA",
+				"use": "sig",
+				"alg": key.Method.Alg(),
+				"kid": key.ID,
+				"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			keys = append(keys, map[string]any{
+				"kty": "OKP",
+				"crv": "Ed25519",
+				"use": "sig",
+				"alg": key.Method.Alg(),
+				"kid": key.ID,
+				"x":   base64.RawURLEncoding.EncodeToString(pub),
+			})
+		}
+	}
+	return map[string]any{"keys": keys}
+}
+
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,235 @@
+package auth_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/mstgnz/starter-kit/api/infra/auth"
+	"github.com/mstgnz/starter-kit/api/infra/testdb"
+)
+
+// writePEM writes the key to a PEM file in dir and returns its path
+func writePEM(t *testing.T, dir, name, pemType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name+".pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: pemType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// keyFiles writes an RSA (PKCS1) and an Ed25519 (PKCS8) private key and the public halves (PKIX),
+// the map holds the path of each file by kid
+func keyFiles(t *testing.T) map[string]string {
+	t.Helper()
+	dir := t.TempDir()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edDER, err := x509.MarshalPKCS8PrivateKey(edPrivate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPublicDER, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPublicDER, err := x509.MarshalPKIXPublicKey(edPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]string{
+		"rsa":        writePEM(t, dir, "rsa", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey)),
+		"rsa-public": writePEM(t, dir, "rsa-public", "PUBLIC KEY", rsaPublicDER),
+		"ed":         writePEM(t, dir, "ed", "PRIVATE KEY", edDER),
+		"ed-public":  writePEM(t, dir, "ed-public", "PUBLIC KEY", edPublicDER),
+	}
+}
+
+// setKeys sets the JWT_KEYS, JWT_ACTIVE_KID and JWT_RETIRED_KIDS env for LoadKeySet, JWT_SECRET is the
+// "default" HS256 key of testdb
+func setKeys(t *testing.T, files map[string]string, kids []string, active, retired string) {
+	t.Helper()
+	entries := make([]string, 0, len(kids))
+	for _, kid := range kids {
+		entries = append(entries, kid+"="+files[kid])
+	}
+	t.Setenv("JWT_KEYS", strings.Join(entries, ","))
+	t.Setenv("JWT_ACTIVE_KID", active)
+	t.Setenv("JWT_RETIRED_KIDS", retired)
+}
+
+// signWith signs a token with the active key of the set as sign does
+func signWith(t *testing.T, ks *auth.KeySet) string {
+	t.Helper()
+	key, err := ks.Active()
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := jwt.NewWithClaims(key.Method, jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))})
+	token.Header["kid"] = key.ID
+	signed, err := token.SignedString(key.Private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// validateWith validates the token against the set as ValidateToken does
+func validateWith(ks *auth.KeySet, token string) error {
+	_, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, err := ks.Lookup(kid)
+		if err != nil {
+			return nil, err
+		}
+		return key.Public, nil
+	})
+	return err
+}
+
+func TestLoadKeySet(t *testing.T) {
+	testdb.Open(t)
+	files := keyFiles(t)
+
+	tests := []struct {
+		name   string
+		active string
+		method jwt.SigningMethod
+	}{
+		{"rs256", "rsa", jwt.SigningMethodRS256},
+		{"eddsa", "ed", jwt.SigningMethodEdDSA},
+		{"hs256", "default", jwt.SigningMethodHS256},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setKeys(t, files, []string{"rsa", "ed", "rsa-public"}, tt.active, "")
+			ks, err := auth.LoadKeySet()
+			if err != nil {
+				t.Fatal(err)
+			}
+			key, err := ks.Active()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if key.ID != tt.active || key.Method != tt.method {
+				t.Fatalf("active key %s %s, want %s %s", key.ID, key.Method.Alg(), tt.active, tt.method.Alg())
+			}
+			if err := validateWith(ks, signWith(t, ks)); err != nil {
+				t.Fatalf("token of the active key %v", err)
+			}
+		})
+	}
+
+	t.Run("errors", func(t *testing.T) {
+		errorTests := []struct {
+			name   string
+			keys   string
+			active string
+		}{
+			{"entry without a path", "rsa", "rsa"},
+			{"missing file", "rsa=" + filepath.Join(t.TempDir(), "missing.pem"), "rsa"},
+			{"not a PEM file", "rsa=" + writePEM(t, t.TempDir(), "bad", "CERTIFICATE", []byte("bad")), "rsa"},
+			{"unknown active kid", "rsa=" + files["rsa"], "unknown"},
+			{"public key as the active key", "rsa-public=" + files["rsa-public"], "rsa-public"},
+			{"retired active key", "rsa=" + files["rsa"] + ",ed=" + files["ed"], "ed"},
+		}
+		for _, tt := range errorTests {
+			t.Setenv("JWT_KEYS", tt.keys)
+			t.Setenv("JWT_ACTIVE_KID", tt.active)
+			t.Setenv("JWT_RETIRED_KIDS", "ed")
+			if _, err := auth.LoadKeySet(); err == nil {
+				t.Errorf("%s: loaded, want an error", tt.name)
+			}
+		}
+	})
+}
+
+func TestJWKS(t *testing.T) {
+	testdb.Open(t)
+	files := keyFiles(t)
+	setKeys(t, files, []string{"rsa", "ed", "ed-public", "rsa-public"}, "rsa", "rsa-public")
+	ks, err := auth.LoadKeySet()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the HMAC key and the retired key are not published
+	keys := ks.JWKS()["keys"].([]map[string]any)
+	want := []struct{ kid, kty, alg string }{
+		{"ed", "OKP", "EdDSA"},
+		{"ed-public", "OKP", "EdDSA"},
+		{"rsa", "RSA", "RS256"},
+	}
+	if len(keys) != len(want) {
+		t.Fatalf("%d keys %v, want %d", len(keys), keys, len(want))
+	}
+	for i, w := range want {
+		key := keys[i]
+		if key["kid"] != w.kid || key["kty"] != w.kty || key["alg"] != w.alg || key["use"] != "sig" {
+			t.Errorf("key %d %v, want %s %s %s", i, key, w.kid, w.kty, w.alg)
+		}
+	}
+	if keys[2]["n"] == "" || keys[2]["e"] != "AQAB" {
+		t.Errorf("rsa key n %q e %q, want the modulus and AQAB", keys[2]["n"], keys[2]["e"])
+	}
+	if keys[0]["crv"] != "Ed25519" || keys[0]["x"] == "" {
+		t.Errorf("ed key crv %q x %q, want Ed25519 and the public key", keys[0]["crv"], keys[0]["x"])
+	}
+}
+
+// TestRotation moves the active key from rsa to ed, tokens of rsa are accepted until rsa is retired
+func TestRotation(t *testing.T) {
+	testdb.Open(t)
+	files := keyFiles(t)
+	setKeys(t, files, []string{"rsa", "ed"}, "rsa", "")
+	ks, err := auth.LoadKeySet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := signWith(t, ks)
+
+	if err := ks.SetActive("ed"); err != nil {
+		t.Fatal(err)
+	}
+	current := signWith(t, ks)
+	for _, token := range []string{old, current} {
+		if err := validateWith(ks, token); err != nil {
+			t.Fatalf("token after the rotation %v", err)
+		}
+	}
+
+	ks.Retire("ed")
+	if key, _ := ks.Active(); key.ID != "ed" || key.Retired {
+		t.Fatal("the active key was retired")
+	}
+	ks.Retire("rsa")
+	if err := validateWith(ks, old); err == nil {
+		t.Fatal("token of the retired key accepted")
+	}
+	if err := validateWith(ks, current); err != nil {
+		t.Fatalf("token of the active key %v", err)
+	}
+	if err := ks.SetActive("rsa"); err == nil {
+		t.Fatal("retired key set active")
+	}
+	for _, key := range ks.JWKS()["keys"].([]map[string]any) {
+		if key["kid"] == "rsa" {
+			t.Fatal("retired key published")
+		}
+	}
+}
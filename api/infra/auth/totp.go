@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	// TOTPPeriod is the time step of the codes
+	TOTPPeriod = 30 * time.Second
+	// TOTPDigits is the length of the codes
+	TOTPDigits = 6
+	// TOTPSkew is the number of steps before and after the current one that are accepted for clock drift
+	TOTPSkew = 1
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewTOTPSecret returns a random 160 bit base32 secret, the size RFC 4226 recommends
+func NewTOTPSecret() string {
+	secret := make([]byte, 20)
+	_, _ = rand.Read(secret)
+	return totpEncoding.EncodeToString(secret)
+}
+
+// TOTPCode returns the RFC 6238 code of the secret for the time step counter
+func TOTPCode(secret string, counter int64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return "", err
+	}
+
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(counter))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	// dynamic truncation of RFC 4226
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < TOTPDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", TOTPDigits, value%mod), nil
+}
+
+// ValidateTOTP checks the code against the steps around now and returns the matched step counter,
+// callers store the counter to reject a code that is used twice
+func ValidateTOTP(secret, code string, now time.Time) (int64, bool) {
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) != TOTPDigits {
+		return 0, false
+	}
+	counter := now.Unix() / int64(TOTPPeriod.Seconds())
+	for i := -TOTPSkew; i <= TOTPSkew; i++ {
+		expected, err := TOTPCode(secret, counter+int64(i))
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return counter + int64(i), true
+		}
+	}
+	return 0, false
+}
+
+// TOTPURI returns the otpauth:// uri of the secret that authenticator apps read from a QR code
+func TOTPURI(issuer, account, secret string) string {
+	query := url.Values{
+		"secret":    {secret},
+		"issuer":    {issuer},
+		"algorithm": {"SHA1"},
+		"digits":    {fmt.Sprint(TOTPDigits)},
+		"period":    {fmt.Sprint(int(TOTPPeriod.Seconds()))},
+	}
+	label := url.PathEscape(issuer + ":" + account)
+	// apps expect spaces in the issuer as %20
+	return "otpauth://totp/" + label + "?" + strings.ReplaceAll(query.Encode(), "+", "%20")
+}
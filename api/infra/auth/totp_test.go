@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 seed of RFC 6238 Appendix B
+var rfcSecret = totpEncoding.EncodeToString([]byte("12345678901234567890"))
+
+// TestTOTPCode checks the SHA1 test vectors of RFC 6238 Appendix B, they have 8 digits
+func TestTOTPCode(t *testing.T) {
+	digits := TOTPDigits
+	TOTPDigits = 8
+	t.Cleanup(func() {
+		TOTPDigits = digits
+	})
+
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, tt := range tests {
+		code, err := TOTPCode(rfcSecret, tt.unix/int64(TOTPPeriod.Seconds()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != tt.code {
+			t.Errorf("code at %d is %s, want %s", tt.unix, code, tt.code)
+		}
+		if counter, ok := ValidateTOTP(rfcSecret, tt.code, time.Unix(tt.unix, 0)); !ok || counter != tt.unix/30 {
+			t.Errorf("code at %d validated %v with counter %d", tt.unix, ok, counter)
+		}
+	}
+}
+
+// TestValidateTOTPSkew checks that only the steps within TOTPSkew of now are accepted
+func TestValidateTOTPSkew(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	counter := now.Unix() / int64(TOTPPeriod.Seconds())
+
+	for step := int64(-3); step <= 3; step++ {
+		code, err := TOTPCode(rfcSecret, counter+step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		matched, ok := ValidateTOTP(rfcSecret, code[:3]+" "+code[3:], now)
+		want := step >= -int64(TOTPSkew) && step <= int64(TOTPSkew)
+		if ok != want {
+			t.Errorf("code of step %+d accepted %v, want %v", step, ok, want)
+		}
+		if ok && matched != counter+step {
+			t.Errorf("code of step %+d matched counter %d, want %d", step, matched, counter+step)
+		}
+	}
+
+	for _, code := range []string{"", "12345", "1234567", "abcdef"} {
+		if _, ok := ValidateTOTP(rfcSecret, code, now); ok {
+			t.Errorf("code %q accepted", code)
+		}
+	}
+	if _, ok := ValidateTOTP("not base32!", "123456", now); ok {
+		t.Error("a code of an invalid secret accepted")
+	}
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"io"
 	"log"
 	"math"
@@ -14,6 +15,7 @@ import (
 
 	"github.com/go-playground/validator/v10"
 	"github.com/mstgnz/starter-kit/api/infra/conn"
+	"github.com/mstgnz/starter-kit/api/infra/load"
 	"github.com/mstgnz/starter-kit/api/pkg/mstgnz/cache"
 	"github.com/mstgnz/starter-kit/api/pkg/mstgnz/gobuilder"
 	"github.com/mstgnz/starter-kit/api/pkg/mstgnz/mail"
@@ -24,21 +26,26 @@ type CKey string
 
 type Config struct {
 	DB        *conn.DB
+	DBs       map[string]*conn.DB
 	Mail      *mail.Mail
 	Cache     *cache.Cache
 	Cron      *cron.Cron
-	Builder   *gobuilder.GoBuilder
 	Kafka     *conn.Kafka
 	Redis     *conn.Redis
 	Validator *validator.Validate
 	SecretKey string
 	Token     string
-	QUERY     map[string]string
+	QUERY     *load.Queries
 	Lang      string
 	Langs     []string
 	Routes    map[string]map[string]string
 	Running   int
 	Shutting  bool
+
+	// Builder is an empty builder of the DB dialect, builders are immutable so it is safe to share.
+	//
+	// Deprecated: use DB.Builder() or gobuilder.NewGoBuilder for each query.
+	Builder *gobuilder.GoBuilder
 }
 
 var (
@@ -50,14 +57,13 @@ func App() *Config {
 	if instance == nil {
 		instance = &Config{
 			DB:        &conn.DB{},
+			DBs:       make(map[string]*conn.DB),
 			Cache:     cache.NewCache(),
 			Cron:      cron.New(),
-			Builder:   gobuilder.NewGoBuilder(gobuilder.Postgres),
 			Kafka:     &conn.Kafka{},
 			Redis:     &conn.Redis{},
 			Validator: validator.New(),
-			// the secret key will change every time the application is restarted.
-			SecretKey: "asdf1234", //RandomString(8),
+			SecretKey: os.Getenv("JWT_SECRET"),
 			Lang:      "tr",
 			Langs:     []string{"tr", "en"},
 			Routes:    make(map[string]map[string]string),
@@ -70,14 +76,37 @@ func App() *Config {
 				Pass: os.Getenv("MAIL_PASS"),
 			},
 		}
-		// Connect to Postgres DB
+		// Connect to the DB, the builder follows its dialect
 		instance.DB.ConnectDatabase()
+		instance.Builder = instance.DB.Builder()
+		// Connect to the named databases of DB_CONNECTIONS, "analytics" reads DB_ANALYTICS_HOST and so on
+		for _, name := range strings.Split(os.Getenv("DB_CONNECTIONS"), ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				db := &conn.DB{}
+				if err := db.Connect(conn.LoadOptions("DB_" + strings.ToUpper(name))); err != nil {
+					log.Fatalf("%s: %v", name, err)
+				}
+				instance.DBs[name] = db
+			}
+		}
 		//instance.Kafka.ConnectKafka()
 		//instance.Redis.ConnectRedis()
 	}
 	return instance
 }
 
+// Database returns the named database of DB_CONNECTIONS, "" and "default" return the main database
+func (c *Config) Database(name string) (*conn.DB, error) {
+	if name == "" || name == "default" {
+		return c.DB, nil
+	}
+	db, ok := c.DBs[name]
+	if !ok {
+		return nil, fmt.Errorf("database %s not configured", name)
+	}
+	return db, nil
+}
+
 func ShuttingWrapper(fn func()) {
 	if !App().Shutting {
 		fn()
@@ -6,68 +6,115 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"os"
 	"reflect"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	_ "github.com/lib/pq"
 	"github.com/mstgnz/starter-kit/api/pkg/mstgnz/gobuilder"
 )
 
+// ErrZeroAffected is returned by QueryExec when the statement changed no row
+var ErrZeroAffected = errors.New("zero affected")
+
 type DB struct {
 	*sql.DB
+	dialect   gobuilder.SQLDialect
+	replicas  []*sql.DB
+	next      atomic.Uint64
+	stmts     *stmtCache
+	stmtsOnce sync.Once
 }
 
-// ConnectDatabase is creating a new connection to our database
+// ConnectDatabase is creating a new connection to our database with the DB_* env options.
+// The named queries and the migrations are written for Postgres and SQLite, so the main database refuses the
+// other drivers here instead of failing on its first query, the DB_CONNECTIONS databases can use any of them.
 func (db *DB) ConnectDatabase() {
-	dbHost := os.Getenv("DB_HOST")
-	dbPort := os.Getenv("DB_PORT")
-	dbUser := os.Getenv("DB_USER")
-	dbPass := os.Getenv("DB_PASS")
-	dbName := os.Getenv("DB_NAME")
-	dbZone := os.Getenv("DB_ZONE")
-
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable TimeZone=%s", dbHost, dbPort, dbUser, dbPass, dbName, dbZone)
+	opts := LoadOptions("DB")
+	if !appDialects[opts.Driver] {
+		log.Fatalf("unsupported DB driver %q for the main database, use postgres or sqlite", opts.Driver)
+	}
+	if err := db.Connect(opts); err != nil {
+		log.Fatal(err)
+	}
+}
 
-	var err error
-	var database *sql.DB
+// Connect opens the primary and the read replicas. The primary is retried 5 times,
+// a replica that cannot be reached is left out and its reads go to the other replicas or the primary.
+func (db *DB) Connect(opts Options) error {
+	if _, ok := driverNames[opts.Driver]; !ok {
+		return fmt.Errorf("unsupported DB driver %q", opts.Driver)
+	}
+	db.dialect = opts.Driver
 
 	for attempts := 1; attempts <= 5; attempts++ {
-		database, err = sql.Open("postgres", connStr)
+		database, err := open(opts, opts.DSN())
+		if err == nil {
+			log.Println("DB Connected successfully")
+			db.DB = database
+			break
+		}
+		log.Printf("Attempt %d: Failed to connect to DB: %v", attempts, err)
+		if attempts == 5 {
+			return errors.New("failed to connect to DB after 5 attempts")
+		}
+		time.Sleep(2 * time.Second)
+	}
+
+	for i, dsn := range opts.ReplicaDSNs() {
+		replica, err := open(opts, dsn)
 		if err != nil {
-			log.Printf("Attempt %d: Failed to open DB connection: %v", attempts, err)
-			time.Sleep(2 * time.Second)
+			log.Printf("Failed to connect to DB replica %s: %v", opts.Replicas[i], err)
 			continue
 		}
+		db.replicas = append(db.replicas, replica)
+	}
+	if len(db.replicas) > 0 {
+		log.Printf("DB Replicas connected: %d", len(db.replicas))
+	}
+	return nil
+}
 
-		// Veritabanı ayarlarını yapılandır
-		database.SetMaxOpenConns(25)
-		database.SetMaxIdleConns(5)
-		database.SetConnMaxLifetime(5 * time.Minute)
-		database.SetConnMaxIdleTime(2 * time.Minute)
+// open opens and pings a pool with the pool settings of the options
+func open(opts Options, dsn string) (*sql.DB, error) {
+	database, err := sql.Open(driverNames[opts.Driver], dsn)
+	if err != nil {
+		return nil, err
+	}
 
-		// Bağlantıyı test et
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		err = database.PingContext(ctx)
-		cancel()
+	if opts.Driver == gobuilder.SQLite && strings.Contains(opts.Name, ":memory:") {
+		// every connection of an in memory database is a new database, keep the only one open
+		opts.MaxOpenConns, opts.ConnMaxLifetime, opts.ConnMaxIdleTime = 1, 0, 0
+	}
 
-		if err == nil {
-			log.Println("DB Connected successfully")
-			db.DB = database
-			return
-		}
+	database.SetMaxOpenConns(opts.MaxOpenConns)
+	database.SetMaxIdleConns(opts.MaxIdleConns)
+	database.SetConnMaxLifetime(opts.ConnMaxLifetime)
+	database.SetConnMaxIdleTime(opts.ConnMaxIdleTime)
 
-		log.Printf("Attempt %d: Failed to ping DB: %v", attempts, err)
-		database.Close()
-		time.Sleep(2 * time.Second)
+	timeout := opts.ConnectTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
 	}
-
-	log.Fatal("Failed to connect to DB after 5 attempts")
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := database.PingContext(ctx); err != nil {
+		_ = database.Close()
+		return nil, err
+	}
+	return database, nil
 }
 
 // CloseDatabase method is closing a connection between your app and your db
 func (db *DB) CloseDatabase() {
+	if db.stmts != nil {
+		log.Printf("Statement cache: %s", db.stmts.snapshot())
+		db.stmts.clear()
+	}
+	for _, replica := range db.replicas {
+		_ = replica.Close()
+	}
 	if err := db.DB.Close(); err != nil {
 		log.Println("Failed to close connection from the database:", err.Error())
 	} else {
@@ -75,30 +122,33 @@ func (db *DB) CloseDatabase() {
 	}
 }
 
-// QueryExec: returns nil if the query is executed successfully
-func (db *DB) QueryExec(ctx context.Context, builder *gobuilder.GoBuilder) error {
+// prepare returns the query of the builder, or its error when a name or an operator was not valid
+func prepare(builder *gobuilder.GoBuilder) (string, []any, error) {
+	if err := builder.Err(); err != nil {
+		return "", nil, err
+	}
 	query, params := builder.Prepare()
+	return query, params, nil
+}
 
-	stmt, err := db.PrepareContext(ctx, query)
+// QueryExec: returns nil if the query is executed successfully
+func (db *DB) QueryExec(ctx context.Context, builder *gobuilder.GoBuilder) error {
+	query, params, err := prepare(builder)
 	if err != nil {
 		return err
 	}
 
-	result, err := stmt.ExecContext(ctx, params...)
+	result, err := db.ExecContext(ctx, query, params...)
 	if err != nil {
 		return err
 	}
-	defer func() {
-		_ = stmt.Close()
-	}()
-
 	affected, err := result.RowsAffected()
 	if err != nil {
 		return err
 	}
 
 	if affected == 0 {
-		return errors.New("zero affected")
+		return ErrZeroAffected
 	}
 
 	return nil
@@ -108,19 +158,16 @@ func (db *DB) QueryExec(ctx context.Context, builder *gobuilder.GoBuilder) error
 func (db *DB) DynamicCount(ctx context.Context, builder *gobuilder.GoBuilder) (int, error) {
 	rowCount := 0
 
-	query, params := builder.Prepare()
-
-	stmt, err := db.PrepareContext(ctx, query)
+	query, params, err := prepare(builder)
 	if err != nil {
-		return rowCount, err
+		return 0, err
 	}
 
-	rows, err := stmt.QueryContext(ctx, params...)
+	rows, err := db.QueryContext(ctx, query, params...)
 	if err != nil {
 		return rowCount, err
 	}
 	defer func() {
-		_ = stmt.Close()
 		_ = rows.Close()
 	}()
 
@@ -135,20 +182,17 @@ func (db *DB) DynamicCount(ctx context.Context, builder *gobuilder.GoBuilder) (i
 
 // DynamicFind: only renders the first matching record to the p.Model object based on the conditions
 func (db *DB) DynamicFind(ctx context.Context, builder *gobuilder.GoBuilder, model any) error {
-	query, params := builder.Prepare()
-
-	stmt, err := db.PrepareContext(ctx, query)
+	query, params, err := prepare(builder)
 	if err != nil {
 		return err
 	}
 
-	rows, err := stmt.QueryContext(ctx, params...)
+	rows, err := db.QueryContext(ctx, query, params...)
 	if err != nil {
 		return err
 	}
 
 	defer func() {
-		_ = stmt.Close()
 		_ = rows.Close()
 	}()
 
@@ -193,21 +237,20 @@ func (db *DB) DynamicFind(ctx context.Context, builder *gobuilder.GoBuilder, mod
 }
 
 // DynamicGet: returns all records it finds
+//
+// Deprecated: use repository.Repository[T] which returns typed results.
 func (db *DB) DynamicGet(ctx context.Context, builder *gobuilder.GoBuilder, model any) ([]any, error) {
-	query, params := builder.Prepare()
-
-	stmt, err := db.PrepareContext(ctx, query)
+	query, params, err := prepare(builder)
 	if err != nil {
 		return nil, err
 	}
 
-	rows, err := stmt.QueryContext(ctx, params...)
+	rows, err := db.QueryContext(ctx, query, params...)
 	if err != nil {
 		return nil, err
 	}
 
 	defer func() {
-		_ = stmt.Close()
 		_ = rows.Close()
 	}()
 
@@ -219,8 +262,8 @@ func (db *DB) DynamicGet(ctx context.Context, builder *gobuilder.GoBuilder, mode
 	var objects []any
 
 	for rows.Next() {
-		// Create new model instance
-		modelInstance := reflect.ValueOf(model).Elem()
+		// Create new model instance, every row needs its own value
+		modelInstance := reflect.New(reflect.TypeOf(model).Elem()).Elem()
 
 		// Slice to map field addresses for columns returned in the query
 		fieldPointers := make([]any, len(columns))
@@ -253,15 +296,15 @@ func (db *DB) DynamicGet(ctx context.Context, builder *gobuilder.GoBuilder, mode
 }
 
 // DynamicPaginate: returns all records according to the conditions
+//
+// Deprecated: use repository.Repository[T] which returns typed results.
 func (db *DB) DynamicPaginate(ctx context.Context, builder *gobuilder.GoBuilder, model any) ([]any, error) {
-	query, params := builder.Prepare()
-
-	stmt, err := db.PrepareContext(ctx, query)
+	query, params, err := prepare(builder)
 	if err != nil {
 		return nil, err
 	}
 
-	rows, err := stmt.QueryContext(ctx, params...)
+	rows, err := db.QueryContext(ctx, query, params...)
 	if err != nil {
 		return nil, err
 	}
@@ -272,15 +315,14 @@ func (db *DB) DynamicPaginate(ctx context.Context, builder *gobuilder.GoBuilder,
 	}
 
 	defer func() {
-		_ = stmt.Close()
 		_ = rows.Close()
 	}()
 
 	var objects []any
 
 	for rows.Next() {
-		// Create new model instance
-		modelInstance := reflect.ValueOf(model).Elem()
+		// Create new model instance, every row needs its own value
+		modelInstance := reflect.New(reflect.TypeOf(model).Elem()).Elem()
 
 		// Slice to map field addresses for columns returned in the query
 		fieldPointers := make([]any, len(columns))
@@ -315,16 +357,7 @@ func (db *DB) DynamicPaginate(ctx context.Context, builder *gobuilder.GoBuilder,
 // DynamicCreate: the specified values are recorded in the specified table.
 func (db *DB) DynamicCreate(ctx context.Context, builder *gobuilder.GoBuilder) (int, error) {
 	var id int
-	query, params := builder.Prepare()
-	query += " RETURNING id;"
-	stmt, err := db.PrepareContext(ctx, query)
-	if err != nil {
-		return id, err
-	}
-	defer stmt.Close()
-
-	err = stmt.QueryRowContext(ctx, params...).Scan(&id)
-	if err != nil {
+	if err := db.Insert(ctx, builder, "id", &id); err != nil {
 		return id, err
 	}
 
@@ -333,15 +366,12 @@ func (db *DB) DynamicCreate(ctx context.Context, builder *gobuilder.GoBuilder) (
 
 // DynamicUpdate: the values specified in the table are updated.
 func (db *DB) DynamicUpdate(ctx context.Context, builder *gobuilder.GoBuilder) error {
-	query, params := builder.Prepare()
-
-	stmt, err := db.PrepareContext(ctx, query)
+	query, params, err := prepare(builder)
 	if err != nil {
 		return err
 	}
-	defer stmt.Close()
 
-	_, err = stmt.ExecContext(ctx, params...)
+	_, err = db.ExecContext(ctx, query, params...)
 	if err != nil {
 		return err
 	}
@@ -351,26 +381,21 @@ func (db *DB) DynamicUpdate(ctx context.Context, builder *gobuilder.GoBuilder) e
 
 // SoftDelete: soft delete the specified id in the specified table.
 func (db *DB) SoftDelete(ctx context.Context, builder *gobuilder.GoBuilder) error {
-	query, params := builder.Prepare()
+	dialect := builder.Dialect()
+	query, params, err := prepare(builder)
+	if err != nil {
+		return err
+	}
 
 	deleteAndUpdate := time.Now().Format("2006-01-02 15:04:05")
-	query += fmt.Sprintf("updated_at=$%d, deleted_at=$%d;", len(params)+1, len(params)+2)
+	query += fmt.Sprintf("updated_at=%s, deleted_at=%s;", gobuilder.Placeholder(dialect, len(params)+1), gobuilder.Placeholder(dialect, len(params)+2))
 	params = append(params, deleteAndUpdate)
 	params = append(params, deleteAndUpdate)
 
-	stmt, err := db.PrepareContext(ctx, query)
-	if err != nil {
-		return err
-	}
-
-	result, err := stmt.ExecContext(ctx, params...)
+	result, err := db.ExecContext(ctx, query, params...)
 	if err != nil {
 		return err
 	}
-	defer func() {
-		_ = stmt.Close()
-	}()
-
 	affected, err := result.RowsAffected()
 	if err != nil {
 		return err
@@ -386,21 +411,15 @@ func (db *DB) SoftDelete(ctx context.Context, builder *gobuilder.GoBuilder) erro
 // HardDelete: hard delete the specified id in the specified table.
 func (db *DB) HardDelete(ctx context.Context, builder *gobuilder.GoBuilder) error {
 
-	query, params := builder.Prepare()
-
-	stmt, err := db.PrepareContext(ctx, query)
+	query, params, err := prepare(builder)
 	if err != nil {
 		return err
 	}
 
-	result, err := stmt.ExecContext(ctx, params...)
+	result, err := db.ExecContext(ctx, query, params...)
 	if err != nil {
 		return err
 	}
-	defer func() {
-		_ = stmt.Close()
-	}()
-
 	affected, err := result.RowsAffected()
 	if err != nil {
 		return err
@@ -440,19 +459,16 @@ func (db *DB) NotExistsInTable(ctx context.Context, builder *gobuilder.GoBuilder
 func (db *DB) count(ctx context.Context, builder *gobuilder.GoBuilder) (int, error) {
 	rowCount := 0
 
-	query, params := builder.Prepare()
-
-	stmt, err := db.PrepareContext(ctx, query)
+	query, params, err := prepare(builder)
 	if err != nil {
-		return rowCount, err
+		return 0, err
 	}
 
-	rows, err := stmt.QueryContext(ctx, params...)
+	rows, err := db.QueryContext(ctx, query, params...)
 	if err != nil {
 		return rowCount, err
 	}
 	defer func() {
-		_ = stmt.Close()
 		_ = rows.Close()
 	}()
 
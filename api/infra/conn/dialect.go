@@ -0,0 +1,178 @@
+package conn
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	"github.com/mstgnz/starter-kit/api/pkg/mstgnz/gobuilder"
+	_ "modernc.org/sqlite"
+)
+
+// driverNames are the database/sql driver names of the dialects. Postgres, MySQL and SQLite drivers are built in,
+// SQL Server and Oracle need a blank import of a driver registered as "sqlserver" or "oracle".
+var driverNames = map[gobuilder.SQLDialect]string{
+	gobuilder.Postgres:  "postgres",
+	gobuilder.MySQL:     "mysql",
+	gobuilder.SQLite:    "sqlite",
+	gobuilder.SQLServer: "sqlserver",
+	gobuilder.Oracle:    "oracle",
+}
+
+// appDialects are the dialects of the named queries and the migrations in asset, the main database must use one
+var appDialects = map[gobuilder.SQLDialect]bool{
+	gobuilder.Postgres: true,
+	gobuilder.SQLite:   true,
+}
+
+var defaultPorts = map[gobuilder.SQLDialect]string{
+	gobuilder.Postgres:  "5432",
+	gobuilder.MySQL:     "3306",
+	gobuilder.SQLServer: "1433",
+	gobuilder.Oracle:    "1521",
+}
+
+// Dialect returns the SQL dialect of the database, Postgres when it is not connected yet
+func (db *DB) Dialect() gobuilder.SQLDialect {
+	if db.dialect == "" {
+		return gobuilder.Postgres
+	}
+	return db.dialect
+}
+
+// Builder returns a new query builder for the dialect of the database
+func (db *DB) Builder() *gobuilder.GoBuilder {
+	return gobuilder.NewGoBuilder(db.Dialect())
+}
+
+// Insert runs the insert of the builder and scans the generated primary key into dest.
+// Postgres and SQLite return the key with RETURNING, SQL Server with OUTPUT and MySQL with LastInsertId.
+func (db *DB) Insert(ctx context.Context, builder *gobuilder.GoBuilder, pk string, dest any) error {
+	query, params, err := prepare(builder)
+	if err != nil {
+		return err
+	}
+
+	switch dialect := db.Dialect(); dialect {
+	case gobuilder.Postgres, gobuilder.SQLite:
+		return db.QueryRowContext(ctx, query+" RETURNING "+gobuilder.Quote(dialect, pk), params...).Scan(dest)
+	case gobuilder.SQLServer:
+		query = strings.Replace(query, " VALUES (", " OUTPUT INSERTED."+gobuilder.Quote(dialect, pk)+" VALUES (", 1)
+		return db.QueryRowContext(ctx, query, params...).Scan(dest)
+	}
+
+	result, err := db.ExecContext(ctx, query, params...)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	value := reflect.ValueOf(dest).Elem()
+	if !reflect.TypeOf(id).ConvertibleTo(value.Type()) {
+		return fmt.Errorf("cannot set insert id to %s", value.Type())
+	}
+	value.Set(reflect.ValueOf(id).Convert(value.Type()))
+	return nil
+}
+
+// dsn returns the connection string of the dialect for the host and port
+func (o Options) dsn(host, port string) string {
+	if port == "" {
+		port = defaultPorts[o.Driver]
+	}
+
+	switch o.Driver {
+	case gobuilder.SQLite:
+		// Name is the database file, :memory: for an in memory database
+		return "file:" + o.Name + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_time_format=sqlite"
+	case gobuilder.MySQL:
+		return o.mysqlDSN(host, port)
+	case gobuilder.SQLServer:
+		query := url.Values{"database": {o.Name}}
+		if o.SSLMode != "" && o.SSLMode != "disable" {
+			query.Set("encrypt", "true")
+		}
+		if o.ConnectTimeout > 0 {
+			query.Set("connection timeout", fmt.Sprint(int(o.ConnectTimeout.Seconds())))
+		}
+		u := url.URL{Scheme: "sqlserver", User: url.UserPassword(o.User, o.Password), Host: net.JoinHostPort(host, port), RawQuery: query.Encode()}
+		return u.String()
+	case gobuilder.Oracle:
+		u := url.URL{Scheme: "oracle", User: url.UserPassword(o.User, o.Password), Host: net.JoinHostPort(host, port), Path: "/" + o.Name}
+		return u.String()
+	}
+	return o.postgresDSN(host, port)
+}
+
+func (o Options) postgresDSN(host, port string) string {
+	params := []string{
+		"host=" + quoteDSN(host),
+		"port=" + quoteDSN(port),
+		"user=" + quoteDSN(o.User),
+		"password=" + quoteDSN(o.Password),
+		"dbname=" + quoteDSN(o.Name),
+		"sslmode=" + quoteDSN(o.SSLMode),
+	}
+	if o.Zone != "" {
+		params = append(params, "TimeZone="+quoteDSN(o.Zone))
+	}
+	if o.SSLCert != "" {
+		params = append(params, "sslcert="+quoteDSN(o.SSLCert))
+	}
+	if o.SSLKey != "" {
+		params = append(params, "sslkey="+quoteDSN(o.SSLKey))
+	}
+	if o.SSLRootCert != "" {
+		params = append(params, "sslrootcert="+quoteDSN(o.SSLRootCert))
+	}
+	if o.ConnectTimeout > 0 {
+		params = append(params, fmt.Sprintf("connect_timeout=%d", int(o.ConnectTimeout.Seconds())))
+	}
+	if o.StatementTimeout > 0 {
+		params = append(params, fmt.Sprintf("statement_timeout=%d", o.StatementTimeout.Milliseconds()))
+	}
+	return strings.Join(params, " ")
+}
+
+func (o Options) mysqlDSN(host, port string) string {
+	cfg := mysql.NewConfig()
+	cfg.User = o.User
+	cfg.Passwd = o.Password
+	cfg.Net = "tcp"
+	cfg.Addr = net.JoinHostPort(host, port)
+	cfg.DBName = o.Name
+	cfg.ParseTime = true
+	cfg.Timeout = o.ConnectTimeout
+	if o.Zone != "" {
+		if loc, err := time.LoadLocation(o.Zone); err == nil {
+			cfg.Loc = loc
+		}
+	}
+	switch o.SSLMode {
+	case "", "disable":
+	case "require":
+		cfg.TLSConfig = "skip-verify"
+	default:
+		cfg.TLSConfig = "true"
+	}
+	if o.StatementTimeout > 0 {
+		cfg.Params = map[string]string{"max_execution_time": fmt.Sprint(o.StatementTimeout.Milliseconds())}
+	}
+	return cfg.FormatDSN()
+}
+
+// quoteDSN quotes a key/value connection string value when it is empty or has spaces, quotes or backslashes
+func quoteDSN(value string) string {
+	if value != "" && !strings.ContainsAny(value, ` '\`) {
+		return value
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
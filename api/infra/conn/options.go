@@ -0,0 +1,120 @@
+package conn
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mstgnz/starter-kit/api/pkg/mstgnz/gobuilder"
+)
+
+// Options are the connection settings of a database, LoadOptions reads them from the environment
+type Options struct {
+	// Driver is the SQL dialect of the database, it selects the database/sql driver and the connection string format
+	Driver gobuilder.SQLDialect
+
+	Host     string
+	Port     string
+	User     string
+	Password string
+	Name     string
+	Zone     string
+
+	// Replicas are the host[:port] of the read replicas, they share the other settings
+	Replicas []string
+
+	SSLMode     string
+	SSLCert     string
+	SSLKey      string
+	SSLRootCert string
+
+	MaxOpenConns     int
+	MaxIdleConns     int
+	ConnMaxLifetime  time.Duration
+	ConnMaxIdleTime  time.Duration
+	ConnectTimeout   time.Duration
+	StatementTimeout time.Duration
+}
+
+// LoadOptions reads the options with the given env prefix, "DB" reads DB_HOST, DB_PORT and so on:
+//
+//	DB_DRIVER                postgres (default), mysql, sqlite, sqlserver or oracle, the main database only postgres or sqlite
+//	DB_HOST, DB_PORT, DB_USER, DB_PASS, DB_NAME, DB_ZONE
+//	DB_REPLICAS              comma separated host[:port] list of read replicas
+//	DB_SSL_MODE              disable (default), require, verify-ca or verify-full
+//	DB_SSL_CERT, DB_SSL_KEY, DB_SSL_ROOT_CERT
+//	DB_MAX_OPEN_CONNS        25
+//	DB_MAX_IDLE_CONNS        5
+//	DB_CONN_MAX_LIFETIME     5m
+//	DB_CONN_MAX_IDLE_TIME    2m
+//	DB_CONNECT_TIMEOUT       5s
+//	DB_STATEMENT_TIMEOUT     0, no timeout
+func LoadOptions(prefix string) Options {
+	env := func(key, fallback string) string {
+		if value := os.Getenv(prefix + "_" + key); value != "" {
+			return value
+		}
+		return fallback
+	}
+	number := func(key string, fallback int) int {
+		if value, err := strconv.Atoi(env(key, "")); err == nil {
+			return value
+		}
+		return fallback
+	}
+	duration := func(key string, fallback time.Duration) time.Duration {
+		if value, err := time.ParseDuration(env(key, "")); err == nil {
+			return value
+		}
+		return fallback
+	}
+
+	opts := Options{
+		Driver:           gobuilder.SQLDialect(strings.ToLower(env("DRIVER", string(gobuilder.Postgres)))),
+		Host:             env("HOST", "localhost"),
+		Port:             env("PORT", ""),
+		User:             env("USER", ""),
+		Password:         env("PASS", ""),
+		Name:             env("NAME", ""),
+		Zone:             env("ZONE", ""),
+		SSLMode:          env("SSL_MODE", "disable"),
+		SSLCert:          env("SSL_CERT", ""),
+		SSLKey:           env("SSL_KEY", ""),
+		SSLRootCert:      env("SSL_ROOT_CERT", ""),
+		MaxOpenConns:     number("MAX_OPEN_CONNS", 25),
+		MaxIdleConns:     number("MAX_IDLE_CONNS", 5),
+		ConnMaxLifetime:  duration("CONN_MAX_LIFETIME", 5*time.Minute),
+		ConnMaxIdleTime:  duration("CONN_MAX_IDLE_TIME", 2*time.Minute),
+		ConnectTimeout:   duration("CONNECT_TIMEOUT", 5*time.Second),
+		StatementTimeout: duration("STATEMENT_TIMEOUT", 0),
+	}
+	for _, replica := range strings.Split(env("REPLICAS", ""), ",") {
+		if replica = strings.TrimSpace(replica); replica != "" {
+			opts.Replicas = append(opts.Replicas, replica)
+		}
+	}
+	return opts
+}
+
+// DSN returns the connection string of the primary, DB_NAME is the database file for sqlite
+func (o Options) DSN() string {
+	return o.dsn(o.Host, o.Port)
+}
+
+// ReplicaDSNs returns the connection strings of the replicas, a replica without a port uses the primary port.
+// SQLite has no replicas.
+func (o Options) ReplicaDSNs() []string {
+	if o.Driver == gobuilder.SQLite {
+		return nil
+	}
+	dsns := make([]string, 0, len(o.Replicas))
+	for _, replica := range o.Replicas {
+		host, port, ok := strings.Cut(replica, ":")
+		if !ok {
+			port = o.Port
+		}
+		dsns = append(dsns, o.dsn(host, port))
+	}
+	return dsns
+}
@@ -0,0 +1,69 @@
+package conn
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"sync/atomic"
+)
+
+type sessionKey struct{}
+
+type primaryKey struct{}
+
+// session remembers whether a write was made with the context
+type session struct {
+	wrote atomic.Bool
+}
+
+// WithSession starts a session, usually one per request. Once a write is made with the context
+// the reads of the session go to the primary, so the session reads its own writes.
+func WithSession(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sessionKey{}, &session{})
+}
+
+// Primary sends the reads made with the context to the primary
+func Primary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// Replicas returns the read replica pools
+func (db *DB) Replicas() []*sql.DB {
+	return db.replicas
+}
+
+// primary returns the primary pool for a write and records the write in the session
+func (db *DB) primary(ctx context.Context) *sql.DB {
+	if s, ok := ctx.Value(sessionKey{}).(*session); ok {
+		s.wrote.Store(true)
+	}
+	return db.DB
+}
+
+// pool returns the pool that runs the query: the primary for writes, transactions, Primary contexts
+// and sessions that wrote, otherwise the next replica
+func (db *DB) pool(ctx context.Context, query string) *sql.DB {
+	if !isRead(query) {
+		return db.primary(ctx)
+	}
+	if len(db.replicas) == 0 || ctx.Value(primaryKey{}) != nil {
+		return db.DB
+	}
+	if _, ok := Tx(ctx); ok {
+		return db.DB
+	}
+	if s, ok := ctx.Value(sessionKey{}).(*session); ok && s.wrote.Load() {
+		return db.DB
+	}
+	return db.replicas[db.next.Add(1)%uint64(len(db.replicas))]
+}
+
+// isRead reports whether the query is a plain select, locking selects and CTEs that may write are not
+func isRead(query string) bool {
+	query = strings.ToUpper(strings.TrimSpace(query))
+	if !strings.HasPrefix(query, "SELECT") {
+		return false
+	}
+	return !strings.Contains(query, " FOR UPDATE") && !strings.Contains(query, " FOR SHARE") &&
+		!strings.Contains(query, " FOR NO KEY UPDATE") && !strings.Contains(query, " FOR KEY SHARE")
+}
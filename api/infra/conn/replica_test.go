@@ -0,0 +1,104 @@
+package conn
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+)
+
+func TestIsRead(t *testing.T) {
+	tests := []struct {
+		query string
+		read  bool
+	}{
+		{"SELECT * FROM users", true},
+		{"  select id from users where id = $1", true},
+		{"\n\tSELECT count(*) FROM users", true},
+		{"SELECT * FROM users WHERE name = 'for'", true},
+		{"SELECT * FROM users WHERE id = $1 FOR UPDATE", false},
+		{"select * from users where id = $1 for update skip locked", false},
+		{"SELECT * FROM users FOR SHARE", false},
+		{"SELECT * FROM users FOR NO KEY UPDATE", false},
+		{"SELECT * FROM users FOR KEY SHARE", false},
+		{"INSERT INTO users (name) VALUES ($1)", false},
+		{"UPDATE users SET name = $1", false},
+		{"DELETE FROM users", false},
+		{"WITH moved AS (DELETE FROM users RETURNING *) SELECT * FROM moved", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if read := isRead(tt.query); read != tt.read {
+			t.Errorf("isRead(%q) = %v, want %v", tt.query, read, tt.read)
+		}
+	}
+}
+
+func TestPool(t *testing.T) {
+	open := func() *sql.DB {
+		pool := sql.OpenDB(&fakeConnector{prepares: map[string]int{}})
+		t.Cleanup(func() {
+			_ = pool.Close()
+		})
+		return pool
+	}
+	primary, first, second := open(), open(), open()
+	db := &DB{DB: primary, replicas: []*sql.DB{first, second}}
+	const read, write, lock = "SELECT 1", "UPDATE users SET name = $1", "SELECT 1 FOR UPDATE"
+	ctx := context.Background()
+
+	t.Run("round robin", func(t *testing.T) {
+		seen := map[*sql.DB]int{}
+		for i := 0; i < 4; i++ {
+			seen[db.pool(ctx, read)]++
+		}
+		if seen[first] != 2 || seen[second] != 2 {
+			t.Fatalf("reads went to %d, %d and %d of the primary, want 2 to each replica", seen[first], seen[second], seen[primary])
+		}
+		if db.pool(ctx, write) != primary || db.pool(ctx, lock) != primary {
+			t.Fatal("write or locking read not on the primary")
+		}
+		// without a session a write does not change where the next read goes
+		if db.pool(ctx, read) == primary {
+			t.Fatal("read on the primary after a write without a session")
+		}
+	})
+
+	t.Run("primary and transaction", func(t *testing.T) {
+		if db.pool(Primary(ctx), read) != primary {
+			t.Fatal("read of a Primary context not on the primary")
+		}
+		if db.pool(context.WithValue(ctx, txKey{}, &txState{}), read) != primary {
+			t.Fatal("read in a transaction not on the primary")
+		}
+	})
+
+	t.Run("session", func(t *testing.T) {
+		session, other := WithSession(ctx), WithSession(ctx)
+		if db.pool(session, read) == primary {
+			t.Fatal("read of a session without a write on the primary")
+		}
+		if db.pool(session, write) != primary {
+			t.Fatal("write not on the primary")
+		}
+		for i := 0; i < 3; i++ {
+			if db.pool(session, read) != primary {
+				t.Fatal("read after a write of the session not on the primary")
+			}
+		}
+		if db.pool(other, read) == primary {
+			t.Fatal("read of another session on the primary")
+		}
+
+		// a locking read goes to the primary, so the session sticks to it too
+		if db.pool(other, lock) != primary || db.pool(other, read) != primary {
+			t.Fatal("read after a locking read of the session not on the primary")
+		}
+	})
+
+	t.Run("no replicas", func(t *testing.T) {
+		single := &DB{DB: primary}
+		if single.pool(ctx, read) != primary {
+			t.Fatal("read without replicas not on the primary")
+		}
+	})
+}
@@ -0,0 +1,224 @@
+package conn
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/lib/pq"
+)
+
+// DefaultStmtCacheSize is the number of prepared statements kept when DB_STMT_CACHE_SIZE is not set
+var DefaultStmtCacheSize = 256
+
+type oneShotKey struct{}
+
+// OneShot marks the queries made with the context as one shot, they are sent without a cached prepared statement
+func OneShot(ctx context.Context) context.Context {
+	return context.WithValue(ctx, oneShotKey{}, true)
+}
+
+// StmtStats are the counters of the prepared statement cache
+type StmtStats struct {
+	Size          int    `json:"size"`
+	Capacity      int    `json:"capacity"`
+	Hits          uint64 `json:"hits"`
+	Misses        uint64 `json:"misses"`
+	Evictions     uint64 `json:"evictions"`
+	Invalidations uint64 `json:"invalidations"`
+}
+
+// HitRate returns the share of lookups served from the cache
+func (s StmtStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// String summarizes the counters for the logs, a low hit rate with many evictions asks for a larger DB_STMT_CACHE_SIZE
+func (s StmtStats) String() string {
+	return fmt.Sprintf("%d/%d statements, %d hits, %d misses, %.1f%% hit rate, %d evictions, %d invalidations",
+		s.Size, s.Capacity, s.Hits, s.Misses, s.HitRate()*100, s.Evictions, s.Invalidations)
+}
+
+// stmtCache is an LRU of prepared statements keyed by pool and sql text
+type stmtCache struct {
+	mu       sync.Mutex
+	capacity int
+	items    map[stmtKey]*list.Element
+	order    *list.List
+	stats    StmtStats
+}
+
+type stmtKey struct {
+	pool  *sql.DB
+	query string
+}
+
+type stmtEntry struct {
+	key  stmtKey
+	stmt *sql.Stmt
+}
+
+func newStmtCache(capacity int) *stmtCache {
+	return &stmtCache{
+		capacity: capacity,
+		items:    map[stmtKey]*list.Element{},
+		order:    list.New(),
+		stats:    StmtStats{Capacity: capacity},
+	}
+}
+
+func (c *stmtCache) get(key stmtKey) (*sql.Stmt, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.order.MoveToFront(elem)
+		c.stats.Hits++
+		return elem.Value.(*stmtEntry).stmt, true
+	}
+	c.stats.Misses++
+	return nil, false
+}
+
+// put adds the statement and returns the statement to use, which is the cached one
+// when another goroutine prepared the same query first
+func (c *stmtCache) put(key stmtKey, stmt *sql.Stmt) *sql.Stmt {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		_ = stmt.Close()
+		c.order.MoveToFront(elem)
+		return elem.Value.(*stmtEntry).stmt
+	}
+	c.items[key] = c.order.PushFront(&stmtEntry{key: key, stmt: stmt})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.remove(oldest)
+		c.stats.Evictions++
+	}
+	return stmt
+}
+
+func (c *stmtCache) invalidate(key stmtKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.remove(elem)
+		c.stats.Invalidations++
+	}
+}
+
+// remove closes the statement, statements in use are closed by database/sql when their rows are closed
+func (c *stmtCache) remove(elem *list.Element) {
+	entry := c.order.Remove(elem).(*stmtEntry)
+	delete(c.items, entry.key)
+	_ = entry.stmt.Close()
+}
+
+func (c *stmtCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for elem := c.order.Front(); elem != nil; elem = c.order.Front() {
+		c.remove(elem)
+	}
+}
+
+func (c *stmtCache) snapshot() StmtStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Size = c.order.Len()
+	return stats
+}
+
+// StmtStats returns the counters of the prepared statement cache
+func (db *DB) StmtStats() StmtStats {
+	if cache := db.stmtCache(); cache != nil {
+		return cache.snapshot()
+	}
+	return StmtStats{}
+}
+
+// stmtCache returns the cache, it is created on first use with DB_STMT_CACHE_SIZE statements, 0 disables it
+func (db *DB) stmtCache() *stmtCache {
+	db.stmtsOnce.Do(func() {
+		capacity := DefaultStmtCacheSize
+		if size, err := strconv.Atoi(os.Getenv("DB_STMT_CACHE_SIZE")); err == nil {
+			capacity = size
+		}
+		if capacity > 0 {
+			db.stmts = newStmtCache(capacity)
+		}
+	})
+	return db.stmts
+}
+
+// cachedStmt returns the cached prepared statement of the query on the pool, bound to the transaction
+// of the context if any. It returns nil when the query should not be prepared.
+func (db *DB) cachedStmt(ctx context.Context, pool *sql.DB, query string) (*sql.Stmt, error) {
+	cache := db.stmtCache()
+	if cache == nil || ctx.Value(oneShotKey{}) != nil {
+		return nil, nil
+	}
+
+	key := stmtKey{pool: pool, query: query}
+	stmt, ok := cache.get(key)
+	if !ok {
+		if _, inTx := Tx(ctx); inTx {
+			// preparing on the pool needs a second connection, a single connection sqlite pool would wait forever
+			return nil, nil
+		}
+		prepared, err := pool.PrepareContext(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		stmt = cache.put(key, prepared)
+	}
+
+	if tx, ok := Tx(ctx); ok {
+		return tx.StmtContext(ctx, stmt), nil
+	}
+	return stmt, nil
+}
+
+// invalidateStmt drops the statement of the query after an error that makes it unusable,
+// it is prepared again on the next call
+func (db *DB) invalidateStmt(key stmtKey, err error) {
+	if err == nil || db.stmts == nil {
+		return
+	}
+	var pqErr *pq.Error
+	switch {
+	case errors.Is(err, driver.ErrBadConn), errors.Is(err, sql.ErrConnDone):
+	// 26000 the statement is gone, 0A000 the cached plan must not change result type after a schema change
+	case errors.As(err, &pqErr) && (pqErr.Code == "26000" || pqErr.Code == "0A000"):
+	default:
+		return
+	}
+	db.stmts.invalidate(key)
+}
+
+// withStmt runs fn with the cached statement of the query on the pool, or with nil when the query is not prepared.
+// A statement closed by an eviction of another goroutine in the meantime is prepared again once.
+func (db *DB) withStmt(ctx context.Context, pool *sql.DB, query string, fn func(stmt *sql.Stmt) error) error {
+	for attempt := 0; ; attempt++ {
+		stmt, err := db.cachedStmt(ctx, pool, query)
+		if err != nil {
+			return err
+		}
+		err = fn(stmt)
+		if stmt != nil && attempt == 0 && err != nil && err.Error() == "sql: statement is closed" {
+			continue
+		}
+		db.invalidateStmt(stmtKey{pool: pool, query: query}, err)
+		return err
+	}
+}
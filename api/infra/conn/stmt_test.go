@@ -0,0 +1,205 @@
+package conn
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+// fakeConnector is a database whose queries return one row with 1, or fail with err once it is set.
+// It counts the statements prepared by the driver.
+type fakeConnector struct {
+	mu       sync.Mutex
+	prepares map[string]int
+	err      error
+}
+
+func (c *fakeConnector) Connect(context.Context) (driver.Conn, error) { return &fakeConn{c}, nil }
+func (c *fakeConnector) Driver() driver.Driver                        { return nil }
+
+func (c *fakeConnector) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.err = err
+}
+
+func (c *fakeConnector) prepared(query string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.prepares[query]
+}
+
+type fakeConn struct{ c *fakeConnector }
+
+func (f *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	f.c.mu.Lock()
+	defer f.c.mu.Unlock()
+	f.c.prepares[query]++
+	return &fakeStmt{f.c}, nil
+}
+func (f *fakeConn) Close() error              { return nil }
+func (f *fakeConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+type fakeStmt struct{ c *fakeConnector }
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+func (s *fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	s.c.mu.Lock()
+	defer s.c.mu.Unlock()
+	return driver.RowsAffected(1), s.c.err
+}
+func (s *fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	s.c.mu.Lock()
+	defer s.c.mu.Unlock()
+	if s.c.err != nil {
+		return nil, s.c.err
+	}
+	return &fakeRows{}, nil
+}
+
+type fakeRows struct{ done bool }
+
+func (r *fakeRows) Columns() []string { return []string{"n"} }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(1)
+	return nil
+}
+
+// fakeDB returns a DB on a fakeConnector with a statement cache of the given size
+func fakeDB(t *testing.T, size string) (*DB, *fakeConnector) {
+	t.Helper()
+	t.Setenv("DB_STMT_CACHE_SIZE", size)
+	connector := &fakeConnector{prepares: map[string]int{}}
+	pool := sql.OpenDB(connector)
+	pool.SetMaxOpenConns(1)
+	t.Cleanup(func() {
+		_ = pool.Close()
+	})
+	return &DB{DB: pool}, connector
+}
+
+func scanOne(db *DB, ctx context.Context, query string) error {
+	var n int
+	return db.QueryRowContext(ctx, query).Scan(&n)
+}
+
+func TestStmtCacheLRU(t *testing.T) {
+	db, connector := fakeDB(t, "2")
+	ctx := context.Background()
+
+	for _, query := range []string{"SELECT a", "SELECT b", "SELECT a", "SELECT c"} {
+		if err := scanOne(db, ctx, query); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// b was the least recently used when c was added
+	want := StmtStats{Size: 2, Capacity: 2, Hits: 1, Misses: 3, Evictions: 1}
+	if stats := db.StmtStats(); stats != want {
+		t.Fatalf("stats %+v, want %+v", stats, want)
+	}
+	if err := scanOne(db, ctx, "SELECT a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := scanOne(db, ctx, "SELECT b"); err != nil {
+		t.Fatal(err)
+	}
+	if a, b := connector.prepared("SELECT a"), connector.prepared("SELECT b"); a != 1 || b != 2 {
+		t.Fatalf("a prepared %d times, b %d times, want 1 and 2", a, b)
+	}
+	want = StmtStats{Size: 2, Capacity: 2, Hits: 2, Misses: 4, Evictions: 2}
+	if stats := db.StmtStats(); stats != want {
+		t.Fatalf("stats %+v, want %+v", stats, want)
+	}
+	if rate := db.StmtStats().HitRate(); rate != 2.0/6.0 {
+		t.Fatalf("hit rate %v, want 1/3", rate)
+	}
+
+	// one shot queries and a disabled cache do not use the cache
+	if err := scanOne(db, OneShot(ctx), "SELECT d"); err != nil {
+		t.Fatal(err)
+	}
+	if stats := db.StmtStats(); stats != want {
+		t.Fatalf("stats after a one shot query %+v, want %+v", stats, want)
+	}
+	disabled, _ := fakeDB(t, "0")
+	if err := scanOne(disabled, ctx, "SELECT a"); err != nil {
+		t.Fatal(err)
+	}
+	if stats := disabled.StmtStats(); stats != (StmtStats{}) {
+		t.Fatalf("stats of a disabled cache %+v", stats)
+	}
+}
+
+func TestStmtCacheInvalidate(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name        string
+		err         error
+		invalidated bool
+	}{
+		{"statement gone", &pq.Error{Code: "26000"}, true},
+		{"plan changed", &pq.Error{Code: "0A000"}, true},
+		{"connection done", sql.ErrConnDone, true},
+		{"unique violation", &pq.Error{Code: "23505"}, false},
+		{"other error", errors.New("boom"), false},
+	}
+	methods := map[string]func(db *DB, query string) error{
+		"row": func(db *DB, query string) error {
+			return scanOne(db, ctx, query)
+		},
+		"rows": func(db *DB, query string) error {
+			rows, err := db.QueryContext(ctx, query)
+			if err == nil {
+				_ = rows.Close()
+			}
+			return err
+		},
+		"exec": func(db *DB, query string) error {
+			_, err := db.ExecContext(ctx, query)
+			return err
+		},
+	}
+	for _, tt := range tests {
+		for method, run := range methods {
+			t.Run(tt.name+" "+method, func(t *testing.T) {
+				db, connector := fakeDB(t, "2")
+				const query = "SELECT a"
+				if err := run(db, query); err != nil {
+					t.Fatal(err)
+				}
+
+				connector.fail(tt.err)
+				if err := run(db, query); !errors.Is(err, tt.err) {
+					t.Fatalf("query %v, want %v", err, tt.err)
+				}
+				connector.fail(nil)
+				if err := run(db, query); err != nil {
+					t.Fatal(err)
+				}
+
+				prepares, invalidations := 1, uint64(0)
+				if tt.invalidated {
+					prepares, invalidations = 2, 1
+				}
+				if stats := db.StmtStats(); stats.Invalidations != invalidations || stats.Size != 1 {
+					t.Fatalf("stats %+v, want %d invalidations and 1 statement", stats, invalidations)
+				}
+				if got := connector.prepared(query); got != prepares {
+					t.Fatalf("prepared %d times, want %d", got, prepares)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package conn
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+type txKey struct{}
+
+// txState is the transaction stored in the context, depth counts the nested savepoints
+type txState struct {
+	tx    *sql.Tx
+	depth int
+}
+
+// WithTx runs fn in a transaction carried by the context. Every query made with the context,
+// including the repository methods, runs in the transaction. The transaction is committed when fn
+// returns nil and rolled back when it returns an error or panics.
+// Nested calls create a savepoint, so only the work of the failing nested fn is rolled back.
+func (db *DB) WithTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		return db.withSavepoint(ctx, state, fn)
+	}
+
+	tx, err := db.primary(ctx).BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	return fn(context.WithValue(ctx, txKey{}, &txState{tx: tx}))
+}
+
+func (db *DB) withSavepoint(ctx context.Context, state *txState, fn func(ctx context.Context) error) (err error) {
+	state.depth++
+	savepoint := fmt.Sprintf("sp_%d", state.depth)
+	defer func() {
+		state.depth--
+	}()
+
+	if _, err := state.tx.ExecContext(ctx, "SAVEPOINT "+savepoint); err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_, _ = state.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+savepoint)
+			panic(p)
+		}
+		if err != nil {
+			_, _ = state.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+savepoint)
+			return
+		}
+		_, err = state.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+savepoint)
+	}()
+
+	return fn(ctx)
+}
+
+// Tx returns the transaction of the context, if any
+func Tx(ctx context.Context) (*sql.Tx, bool) {
+	state, ok := ctx.Value(txKey{}).(*txState)
+	if !ok {
+		return nil, false
+	}
+	return state.tx, true
+}
+
+// PrepareContext prepares the statement in the transaction of the context, or on the primary without one
+func (db *DB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	if tx, ok := Tx(ctx); ok {
+		return tx.PrepareContext(ctx, query)
+	}
+	return db.DB.PrepareContext(ctx, query)
+}
+
+// ExecContext executes the query on the primary, in the transaction of the context if any.
+// The query runs as a cached prepared statement unless the context is OneShot.
+func (db *DB) ExecContext(ctx context.Context, query string, args ...any) (result sql.Result, err error) {
+	pool := db.primary(ctx)
+	err = db.withStmt(ctx, pool, query, func(stmt *sql.Stmt) (err error) {
+		if stmt != nil {
+			result, err = stmt.ExecContext(ctx, args...)
+		} else if tx, ok := Tx(ctx); ok {
+			result, err = tx.ExecContext(ctx, query, args...)
+		} else {
+			result, err = pool.ExecContext(ctx, query, args...)
+		}
+		return err
+	})
+	return result, err
+}
+
+// QueryContext runs the query in the transaction of the context if any, otherwise a select runs
+// on a read replica and any other statement on the primary.
+// The query runs as a cached prepared statement unless the context is OneShot.
+func (db *DB) QueryContext(ctx context.Context, query string, args ...any) (rows *sql.Rows, err error) {
+	pool := db.pool(ctx, query)
+	err = db.withStmt(ctx, pool, query, func(stmt *sql.Stmt) (err error) {
+		if stmt != nil {
+			rows, err = stmt.QueryContext(ctx, args...)
+		} else if tx, ok := Tx(ctx); ok {
+			rows, err = tx.QueryContext(ctx, query, args...)
+		} else {
+			rows, err = pool.QueryContext(ctx, query, args...)
+		}
+		return err
+	})
+	return rows, err
+}
+
+// QueryRowContext runs the query like QueryContext, a statement that fails with the error of the row
+// is dropped from the cache like in the other methods.
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...any) (row *sql.Row) {
+	pool := db.pool(ctx, query)
+	// the error is reported by Scan of the row
+	_ = db.withStmt(ctx, pool, query, func(stmt *sql.Stmt) error {
+		if stmt != nil {
+			row = stmt.QueryRowContext(ctx, args...)
+		} else if tx, ok := Tx(ctx); ok {
+			row = tx.QueryRowContext(ctx, query, args...)
+		} else {
+			row = pool.QueryRowContext(ctx, query, args...)
+		}
+		return row.Err()
+	})
+	if row == nil {
+		// the statement could not be prepared, the query reports the error through Scan
+		if tx, ok := Tx(ctx); ok {
+			return tx.QueryRowContext(ctx, query, args...)
+		}
+		return pool.QueryRowContext(ctx, query, args...)
+	}
+	return row
+}
@@ -0,0 +1,194 @@
+package conn_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/mstgnz/starter-kit/api/infra/conn"
+	"github.com/mstgnz/starter-kit/api/infra/testdb"
+)
+
+var errTx = errors.New("tx test")
+
+// txDB returns the test database with a tx_test table, insert adds a name and names lists the names
+// that start with prefix
+func txDB(t *testing.T) (db *conn.DB, insert func(ctx context.Context, name string) error, names func(prefix string) []string) {
+	t.Helper()
+	db = testdb.Open(t)
+	if _, err := db.ExecContext(context.Background(), "CREATE TABLE IF NOT EXISTS tx_test (name TEXT NOT NULL)"); err != nil {
+		t.Fatal(err)
+	}
+	insert = func(ctx context.Context, name string) error {
+		_, err := db.ExecContext(ctx, "INSERT INTO tx_test (name) VALUES ($1)", name)
+		return err
+	}
+	names = func(prefix string) []string {
+		t.Helper()
+		rows, err := db.QueryContext(context.Background(), "SELECT name FROM tx_test WHERE name LIKE $1 ORDER BY name", prefix+"%")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		var list []string
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				t.Fatal(err)
+			}
+			list = append(list, name)
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		return list
+	}
+	return db, insert, names
+}
+
+func TestWithTx(t *testing.T) {
+	db, insert, names := txDB(t)
+	ctx := context.Background()
+
+	t.Run("commit", func(t *testing.T) {
+		err := db.WithTx(ctx, func(ctx context.Context) error {
+			if _, ok := conn.Tx(ctx); !ok {
+				t.Fatal("no transaction in the context")
+			}
+			return insert(ctx, "commit")
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := names("commit"); !slices.Equal(got, []string{"commit"}) {
+			t.Fatalf("names %v, want [commit]", got)
+		}
+	})
+
+	t.Run("rollback on error", func(t *testing.T) {
+		err := db.WithTx(ctx, func(ctx context.Context) error {
+			if err := insert(ctx, "error"); err != nil {
+				return err
+			}
+			return errTx
+		})
+		if !errors.Is(err, errTx) {
+			t.Fatalf("tx %v, want %v", err, errTx)
+		}
+		if got := names("error"); len(got) != 0 {
+			t.Fatalf("names %v, want none", got)
+		}
+	})
+
+	t.Run("rollback on panic", func(t *testing.T) {
+		func() {
+			defer func() {
+				if p := recover(); p != errTx {
+					t.Fatalf("recovered %v, want the panic of fn", p)
+				}
+			}()
+			_ = db.WithTx(ctx, func(ctx context.Context) error {
+				if err := insert(ctx, "panic"); err != nil {
+					return err
+				}
+				panic(errTx)
+			})
+		}()
+		if got := names("panic"); len(got) != 0 {
+			t.Fatalf("names %v, want none", got)
+		}
+		// the connection went back to the pool without a transaction
+		if err := insert(ctx, "panic-after"); err != nil {
+			t.Fatal(err)
+		}
+		if got := names("panic"); !slices.Equal(got, []string{"panic-after"}) {
+			t.Fatalf("names %v, want [panic-after]", got)
+		}
+	})
+}
+
+// TestSavepoint checks that a failing nested WithTx only rolls back its own work
+func TestSavepoint(t *testing.T) {
+	db, insert, names := txDB(t)
+	ctx := context.Background()
+
+	t.Run("nested", func(t *testing.T) {
+		err := db.WithTx(ctx, func(ctx context.Context) error {
+			if err := insert(ctx, "nested-a"); err != nil {
+				return err
+			}
+			if err := db.WithTx(ctx, func(ctx context.Context) error {
+				return insert(ctx, "nested-b")
+			}); err != nil {
+				return err
+			}
+
+			// an error of the nested fn is returned to the outer fn, which goes on
+			err := db.WithTx(ctx, func(ctx context.Context) error {
+				if err := insert(ctx, "nested-c"); err != nil {
+					return err
+				}
+				return errTx
+			})
+			if !errors.Is(err, errTx) {
+				t.Fatalf("nested tx %v, want %v", err, errTx)
+			}
+
+			// a panic of the nested fn rolls back to its savepoint before it reaches the outer fn
+			func() {
+				defer func() {
+					if p := recover(); p != errTx {
+						t.Fatalf("recovered %v, want the panic of the nested fn", p)
+					}
+				}()
+				_ = db.WithTx(ctx, func(ctx context.Context) error {
+					if err := insert(ctx, "nested-d"); err != nil {
+						return err
+					}
+					panic(errTx)
+				})
+			}()
+
+			// two levels, the inner one fails and the middle one keeps its work
+			return db.WithTx(ctx, func(ctx context.Context) error {
+				if err := insert(ctx, "nested-e"); err != nil {
+					return err
+				}
+				if err := db.WithTx(ctx, func(ctx context.Context) error {
+					if err := insert(ctx, "nested-f"); err != nil {
+						return err
+					}
+					return errTx
+				}); !errors.Is(err, errTx) {
+					t.Fatalf("inner tx %v, want %v", err, errTx)
+				}
+				return nil
+			})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := names("nested"), []string{"nested-a", "nested-b", "nested-e"}; !slices.Equal(got, want) {
+			t.Fatalf("names %v, want %v", got, want)
+		}
+	})
+
+	t.Run("outer rollback", func(t *testing.T) {
+		err := db.WithTx(ctx, func(ctx context.Context) error {
+			if err := db.WithTx(ctx, func(ctx context.Context) error {
+				return insert(ctx, "outer-a")
+			}); err != nil {
+				return err
+			}
+			return errTx
+		})
+		if !errors.Is(err, errTx) {
+			t.Fatalf("tx %v, want %v", err, errTx)
+		}
+		// the released savepoint is rolled back with the transaction
+		if got := names("outer"); len(got) != 0 {
+			t.Fatalf("names %v, want none", got)
+		}
+	})
+}
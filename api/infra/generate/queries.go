@@ -0,0 +1,265 @@
+package generate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/format"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/mstgnz/starter-kit/api/infra/load"
+)
+
+// Field is a parameter or a result column of a statement with its Go type
+type Field struct {
+	Name   string
+	GoType string
+}
+
+// Statement is a query described by the database
+type Statement struct {
+	Query   load.Query
+	Kind    string
+	Params  []Field
+	Columns []Field
+}
+
+var goTypes = map[uint32]string{
+	pgtype.Int2OID:        "int",
+	pgtype.Int4OID:        "int",
+	pgtype.Int8OID:        "int64",
+	pgtype.Float4OID:      "float64",
+	pgtype.Float8OID:      "float64",
+	pgtype.NumericOID:     "float64",
+	pgtype.BoolOID:        "bool",
+	pgtype.TextOID:        "string",
+	pgtype.VarcharOID:     "string",
+	pgtype.BPCharOID:      "string",
+	pgtype.NameOID:        "string",
+	pgtype.UUIDOID:        "string",
+	pgtype.InetOID:        "string",
+	pgtype.TimestampOID:   "time.Time",
+	pgtype.TimestamptzOID: "time.Time",
+	pgtype.DateOID:        "time.Time",
+	pgtype.ByteaOID:       "[]byte",
+	pgtype.JSONOID:        "json.RawMessage",
+	pgtype.JSONBOID:       "json.RawMessage",
+}
+
+// Describe prepares every query on the connection and reads the types of its parameters and result columns.
+// Columns of a table are pointers when the column is nullable, computed columns are pointers
+// unless they are a count or exists.
+func Describe(ctx context.Context, conn *pgconn.PgConn, queries []load.Query) ([]Statement, error) {
+	statements := make([]Statement, 0, len(queries))
+	for _, query := range queries {
+		desc, err := conn.Prepare(ctx, "", strings.TrimSuffix(query.SQL, ";"), nil)
+		if err != nil {
+			return nil, fmt.Errorf("%s (%s:%d): %w", query.Name, query.File, query.Line, err)
+		}
+
+		statement := Statement{Query: query, Kind: query.Kind}
+		for i, oid := range desc.ParamOIDs {
+			param := Field{Name: "arg" + strconv.Itoa(i+1), GoType: goType(oid)}
+			if i < len(query.Params) {
+				param.Name = query.Params[i].Name
+				if query.Params[i].Type != "" {
+					param.GoType = query.Params[i].Type
+				}
+			}
+			statement.Params = append(statement.Params, param)
+		}
+
+		seen := map[string]bool{}
+		for _, field := range desc.Fields {
+			if seen[field.Name] {
+				return nil, fmt.Errorf("%s: duplicate column %s, use an alias", query.Name, field.Name)
+			}
+			seen[field.Name] = true
+
+			nullable := field.Name != "count" && field.Name != "exists"
+			if field.TableOID != 0 {
+				if nullable, err = columnNullable(ctx, conn, field.TableOID, field.TableAttributeNumber); err != nil {
+					return nil, err
+				}
+			}
+			column := Field{Name: field.Name, GoType: goType(field.DataTypeOID)}
+			if nullable && column.GoType != "any" && column.GoType != "[]byte" && column.GoType != "json.RawMessage" {
+				column.GoType = "*" + column.GoType
+			}
+			statement.Columns = append(statement.Columns, column)
+		}
+
+		if statement.Kind == "" {
+			statement.Kind = "many"
+			if len(statement.Columns) == 0 {
+				statement.Kind = "exec"
+			}
+		}
+		if statement.Kind != "exec" && len(statement.Columns) == 0 {
+			return nil, fmt.Errorf("%s returns no columns, use :exec", query.Name)
+		}
+		statements = append(statements, statement)
+	}
+	return statements, nil
+}
+
+// Render writes the Go source of the statements: a function per statement that takes the annotated
+// parameters, and a row struct per statement with more than one column
+func Render(pkg string, statements []Statement) ([]byte, error) {
+	sort.Slice(statements, func(i, j int) bool { return statements[i].Query.Name < statements[j].Query.Name })
+
+	var body bytes.Buffer
+	imports := map[string]bool{"context": true, "database/sql": true}
+	for _, statement := range statements {
+		writeStatement(&body, statement, imports)
+	}
+
+	var src bytes.Buffer
+	src.WriteString("// Code generated by \"generate queries\". DO NOT EDIT.\n\n")
+	fmt.Fprintf(&src, "package %s\n\nimport (\n", pkg)
+	paths := make([]string, 0, len(imports))
+	for path := range imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		fmt.Fprintf(&src, "\t%q\n", path)
+	}
+	src.WriteString(")\n\n")
+	src.WriteString(`// DBTX is satisfied by *conn.DB, *sql.DB and *sql.Tx, *conn.DB runs the queries in the transaction of the context
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+`)
+	src.Write(body.Bytes())
+
+	return format.Source(src.Bytes())
+}
+
+func writeStatement(w *bytes.Buffer, statement Statement, imports map[string]bool) {
+	name := goName(statement.Query.Name, true)
+	constName := goName(statement.Query.Name, false) + "SQL"
+
+	fmt.Fprintf(w, "\nconst %s = %s\n", constName, strconv.Quote(statement.Query.SQL))
+
+	var params, args []string
+	for _, param := range statement.Params {
+		paramName := goName(param.Name, false)
+		switch paramName {
+		case "ctx", "db", "err", "item", "items", "rows", "result":
+			// do not shadow the names of the generated body
+			paramName += "Arg"
+		}
+		params = append(params, paramName+" "+param.GoType)
+		args = append(args, paramName)
+		addImport(imports, param.GoType)
+	}
+	signature := strings.Join(append([]string{"ctx context.Context", "db DBTX"}, params...), ", ")
+	callArgs := strings.Join(append([]string{"ctx", constName}, args...), ", ")
+
+	if statement.Kind == "exec" {
+		fmt.Fprintf(w, "\n// %s runs %s of %s and returns the number of affected rows\n", name, statement.Query.Name, statement.Query.File)
+		fmt.Fprintf(w, "func %s(%s) (int64, error) {\n", name, signature)
+		fmt.Fprintf(w, "\tresult, err := db.ExecContext(%s)\n", callArgs)
+		w.WriteString("\tif err != nil {\n\t\treturn 0, err\n\t}\n\treturn result.RowsAffected()\n}\n")
+		return
+	}
+
+	// a single column is returned as is, more columns as a row struct
+	rowType := statement.Columns[0].GoType
+	scan := "&item"
+	if len(statement.Columns) > 1 {
+		rowType = name + "Row"
+		fmt.Fprintf(w, "\n// %s is a row of %s\ntype %s struct {\n", rowType, statement.Query.Name, rowType)
+		var targets []string
+		for _, column := range statement.Columns {
+			field := goName(column.Name, true)
+			fmt.Fprintf(w, "\t%s %s `json:\"%s\"`\n", field, column.GoType, column.Name)
+			targets = append(targets, "&item."+field)
+		}
+		w.WriteString("}\n")
+		scan = strings.Join(targets, ", ")
+	}
+	for _, column := range statement.Columns {
+		addImport(imports, column.GoType)
+	}
+
+	if statement.Kind == "one" {
+		fmt.Fprintf(w, "\n// %s runs %s of %s, it returns sql.ErrNoRows when there is no row\n", name, statement.Query.Name, statement.Query.File)
+		fmt.Fprintf(w, "func %s(%s) (%s, error) {\n", name, signature, rowType)
+		fmt.Fprintf(w, "\tvar item %s\n", rowType)
+		fmt.Fprintf(w, "\terr := db.QueryRowContext(%s).Scan(%s)\n", callArgs, scan)
+		w.WriteString("\treturn item, err\n}\n")
+		return
+	}
+
+	fmt.Fprintf(w, "\n// %s runs %s of %s\n", name, statement.Query.Name, statement.Query.File)
+	fmt.Fprintf(w, "func %s(%s) ([]%s, error) {\n", name, signature, rowType)
+	fmt.Fprintf(w, "\trows, err := db.QueryContext(%s)\n", callArgs)
+	w.WriteString("\tif err != nil {\n\t\treturn nil, err\n\t}\n")
+	w.WriteString("\tdefer func() {\n\t\t_ = rows.Close()\n\t}()\n\n")
+	fmt.Fprintf(w, "\titems := []%s{}\n\tfor rows.Next() {\n\t\tvar item %s\n", rowType, rowType)
+	fmt.Fprintf(w, "\t\tif err := rows.Scan(%s); err != nil {\n\t\t\treturn nil, err\n\t\t}\n", scan)
+	w.WriteString("\t\titems = append(items, item)\n\t}\n\treturn items, rows.Err()\n}\n")
+}
+
+func columnNullable(ctx context.Context, conn *pgconn.PgConn, table uint32, column uint16) (bool, error) {
+	result := conn.ExecParams(ctx, "SELECT attnotnull FROM pg_attribute WHERE attrelid=$1 AND attnum=$2",
+		[][]byte{[]byte(strconv.FormatUint(uint64(table), 10)), []byte(strconv.Itoa(int(column)))},
+		[]uint32{pgtype.OIDOID, pgtype.Int2OID}, nil, nil).Read()
+	if result.Err != nil {
+		return false, result.Err
+	}
+	if len(result.Rows) == 0 {
+		return true, nil
+	}
+	return string(result.Rows[0][0]) != "t", nil
+}
+
+func goType(oid uint32) string {
+	if t, ok := goTypes[oid]; ok {
+		return t
+	}
+	return "any"
+}
+
+func addImport(imports map[string]bool, goType string) {
+	switch strings.TrimPrefix(goType, "*") {
+	case "time.Time":
+		imports["time"] = true
+	case "json.RawMessage":
+		imports["encoding/json"] = true
+	}
+}
+
+var initialisms = map[string]string{"id": "ID", "url": "URL", "api": "API", "ip": "IP", "sql": "SQL", "json": "JSON", "uuid": "UUID"}
+
+// goName converts USER_GET_WITH_ID or user_id to UserGetWithID and UserID, or to userGetWithID and userID
+func goName(name string, exported bool) string {
+	var b strings.Builder
+	for _, part := range strings.Split(strings.ToLower(name), "_") {
+		if part == "" {
+			continue
+		}
+		switch {
+		case b.Len() == 0 && !exported:
+			b.WriteString(part)
+		case initialisms[part] != "":
+			b.WriteString(initialisms[part])
+		default:
+			b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+		}
+	}
+	result := b.String()
+	if token.IsKeyword(result) {
+		result += "_"
+	}
+	return result
+}
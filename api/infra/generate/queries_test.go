@@ -0,0 +1,85 @@
+package generate
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mstgnz/starter-kit/api/infra/load"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+func TestGoName(t *testing.T) {
+	tests := []struct {
+		name     string
+		exported string
+		local    string
+	}{
+		{"USER_GET_WITH_ID", "UserGetWithID", "userGetWithID"},
+		{"user_id", "UserID", "userID"},
+		{"API_KEY_LIST", "APIKeyList", "apiKeyList"},
+		{"ip", "IP", "ip"},
+		{"last_login_ip", "LastLoginIP", "lastLoginIP"},
+		{"__name__", "Name", "name"},
+		{"type", "Type", "type_"},
+		{"func", "Func", "func_"},
+	}
+	for _, tt := range tests {
+		if name := goName(tt.name, true); name != tt.exported {
+			t.Errorf("exported name of %s is %s, want %s", tt.name, name, tt.exported)
+		}
+		if name := goName(tt.name, false); name != tt.local {
+			t.Errorf("local name of %s is %s, want %s", tt.name, name, tt.local)
+		}
+	}
+}
+
+// TestRender compares the source of statements of every kind with testdata/render.golden, go test -update rewrites it
+func TestRender(t *testing.T) {
+	statements := []Statement{
+		{
+			Query:  load.Query{Name: "USER_DELETE", File: "user.sql", SQL: "UPDATE users SET deleted_at=$1 WHERE id=$2;"},
+			Kind:   "exec",
+			Params: []Field{{"deleted_at", "string"}, {"id", "int"}},
+		},
+		{
+			Query:   load.Query{Name: "USER_COUNT", File: "user.sql", SQL: "SELECT count(*) FROM users WHERE email=$1;"},
+			Kind:    "one",
+			Params:  []Field{{"email", "string"}},
+			Columns: []Field{{"count", "int64"}},
+		},
+		{
+			Query:   load.Query{Name: "USER_GET_WITH_ID", File: "user.sql", SQL: "SELECT id, last_login, settings FROM users WHERE id=$1;"},
+			Kind:    "one",
+			Params:  []Field{{"id", "int"}},
+			Columns: []Field{{"id", "int"}, {"last_login", "*time.Time"}, {"settings", "json.RawMessage"}},
+		},
+		{
+			Query:   load.Query{Name: "SESSION_LIST", File: "session.sql", SQL: "SELECT family, ip FROM sessions WHERE user_id=$1 AND type=$2 LIMIT $3;"},
+			Kind:    "many",
+			Params:  []Field{{"user_id", "int"}, {"type", "string"}, {"rows", "int"}},
+			Columns: []Field{{"family", "string"}, {"ip", "*string"}},
+		},
+	}
+
+	src, err := Render("queries", statements)
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := filepath.Join("testdata", "render.golden")
+	if *update {
+		if err := os.WriteFile(golden, src, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(src, want) {
+		t.Fatalf("rendered source differs from %s, run go test -update if the change is wanted:\n%s", golden, src)
+	}
+}
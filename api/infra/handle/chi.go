@@ -7,6 +7,7 @@ import (
 	"net/url"
 	"reflect"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -18,6 +19,11 @@ import (
 type Request any
 type Response any
 
+// queryBinder is a request field that reads itself from the query parameters, such as paginate.Filters
+type queryBinder interface {
+	BindQuery(name string, query url.Values) error
+}
+
 func Handle[Req Request, Res Response](handler func(ctx context.Context, req *Req) Res) config.HttpHandler {
 	return func(w http.ResponseWriter, r *http.Request) error {
 		var req Req
@@ -34,8 +40,12 @@ func Handle[Req Request, Res Response](handler func(ctx context.Context, req *Re
 			}
 		}
 
-		// query parser
+		// query parser, errors that carry their own response such as paginate.Error are written as is
 		if err := parseQuery(r.URL.Query(), &req); err != nil {
+			if res, ok := err.(interface{ Response() response.Response }); ok {
+				result := res.Response()
+				return response.WriteJSON(w, result.Code, result)
+			}
 			return response.WriteJSON(w, http.StatusBadRequest, response.Response{Code: http.StatusBadRequest, Success: false, Message: err.Error()})
 		}
 
@@ -44,15 +54,18 @@ func Handle[Req Request, Res Response](handler func(ctx context.Context, req *Re
 			return response.WriteJSON(w, http.StatusBadRequest, response.Response{Code: http.StatusBadRequest, Success: false, Message: err.Error()})
 		}
 
-		// validation
-		err := validate.Validate(req)
-		if err != nil {
-			return response.WriteJSON(w, http.StatusUnprocessableEntity, response.Response{Code: http.StatusUnprocessableEntity, Success: false, Message: err.Error()})
+		// validation, handlers without a request body use *any
+		if reflect.ValueOf(&req).Elem().Kind() == reflect.Struct {
+			if err := validate.Validate(req); err != nil {
+				return response.WriteJSON(w, http.StatusUnprocessableEntity, response.Response{Code: http.StatusUnprocessableEntity, Success: false, Message: err.Error()})
+			}
 		}
 
-		// timeout context
+		// timeout context, the request url is kept for building links and the user agent for sessions
 		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
 		defer cancel()
+		ctx = context.WithValue(ctx, config.CKey("requestUrl"), r.URL)
+		ctx = context.WithValue(ctx, config.CKey("userAgent"), r.UserAgent())
 
 		res := handler(ctx, &req)
 
@@ -65,35 +78,42 @@ func Handle[Req Request, Res Response](handler func(ctx context.Context, req *Re
 			})
 		}
 
-		return response.WriteJSON(w, result.Code, result)
+		return response.WriteJSON(w, result.Code, result, result.Headers)
 	}
 }
 
 func parseParams(rctx *chi.Context, req interface{}) error {
-	v := reflect.ValueOf(req).Elem()
-	t := v.Type()
+	return bindTag(reflect.ValueOf(req).Elem(), "param", rctx.URLParam)
+}
 
-	for i := 0; i < t.NumField(); i++ {
-		field := t.Field(i)
-		if tag := field.Tag.Get("param"); tag != "" {
-			if value := rctx.URLParam(tag); value != "" {
-				if err := setFieldValue(v.Field(i), value); err != nil {
-					return err
-				}
-			}
-		}
+func parseQuery(query url.Values, req interface{}) error {
+	if err := bindTag(reflect.ValueOf(req).Elem(), "query", query.Get); err != nil {
+		return err
 	}
-	return nil
+	return bindQueryMaps(reflect.ValueOf(req).Elem(), query)
 }
 
-func parseQuery(query url.Values, req interface{}) error {
-	v := reflect.ValueOf(req).Elem()
+func parseHeader(header http.Header, req interface{}) error {
+	return bindTag(reflect.ValueOf(req).Elem(), "header", header.Get)
+}
+
+// bindTag sets the fields tagged with tag, embedded structs such as paginate.Params are bound too
+func bindTag(v reflect.Value, tag string, lookup func(string) string) error {
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
 	t := v.Type()
 
 	for i := 0; i < t.NumField(); i++ {
 		field := t.Field(i)
-		if tag := field.Tag.Get("query"); tag != "" {
-			if value := query.Get(tag); value != "" {
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if err := bindTag(v.Field(i), tag, lookup); err != nil {
+				return err
+			}
+			continue
+		}
+		if name := field.Tag.Get(tag); name != "" && field.Type.Kind() != reflect.Map && !isQueryBinder(field.Type) {
+			if value := lookup(name); value != "" {
 				if err := setFieldValue(v.Field(i), value); err != nil {
 					return err
 				}
@@ -103,18 +123,49 @@ func parseQuery(query url.Values, req interface{}) error {
 	return nil
 }
 
-func parseHeader(header http.Header, req interface{}) error {
-	v := reflect.ValueOf(req).Elem()
+// isQueryBinder reports whether a pointer to the type implements queryBinder
+func isQueryBinder(t reflect.Type) bool {
+	return reflect.PointerTo(t).Implements(reflect.TypeOf((*queryBinder)(nil)).Elem())
+}
+
+// bindQueryMaps sets the map[string]string fields tagged with query from the bracket keys,
+// `query:"filter"` is set from ?filter[email]=a@b.c&filter[active]=true. Fields implementing
+// queryBinder read the keys themselves.
+func bindQueryMaps(v reflect.Value, query url.Values) error {
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
 	t := v.Type()
 
 	for i := 0; i < t.NumField(); i++ {
 		field := t.Field(i)
-		if tag := field.Tag.Get("header"); tag != "" {
-			if value := header.Get(tag); value != "" {
-				if err := setFieldValue(v.Field(i), value); err != nil {
-					return err
-				}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if err := bindQueryMaps(v.Field(i), query); err != nil {
+				return err
+			}
+			continue
+		}
+		name := field.Tag.Get("query")
+		if name == "" {
+			continue
+		}
+		if isQueryBinder(field.Type) {
+			if err := v.Field(i).Addr().Interface().(queryBinder).BindQuery(name, query); err != nil {
+				return err
 			}
+			continue
+		}
+		if field.Type != reflect.TypeOf(map[string]string{}) {
+			continue
+		}
+		values := map[string]string{}
+		for key := range query {
+			if inner, ok := strings.CutPrefix(key, name+"["); ok && strings.HasSuffix(inner, "]") {
+				values[strings.TrimSuffix(inner, "]")] = query.Get(key)
+			}
+		}
+		if len(values) > 0 {
+			v.Field(i).Set(reflect.ValueOf(values))
 		}
 	}
 	return nil
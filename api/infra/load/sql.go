@@ -2,64 +2,262 @@ package load
 
 import (
 	"bufio"
+	"context"
 	"fmt"
-	"os"
+	"io/fs"
+	"log"
+	"path"
 	"reflect"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 )
 
-// LoadSQLQueries loads SQL queries from a file and populates the QUERY map.
-func LoadSQLQueries() (map[string]string, error) {
-	query := make(map[string]string)
-	file, err := os.Open("asset/query.sql")
+var (
+	requiredMu sync.Mutex
+	required   = map[string]bool{}
+
+	namePattern  = regexp.MustCompile(`^--\s*name:\s*(\w+)(?:\s+:(one|many|exec))?\s*$`)
+	legacyName   = regexp.MustCompile(`^--\s+([A-Z][A-Z0-9_]*)\s*$`)
+	paramPattern = regexp.MustCompile(`^--\s*:(\w+)(?:\s+(\S+))?\s*$`)
+)
+
+// Query is a named statement of a query file. Params are the "-- :name [type]" annotations,
+// the nth param is the $n placeholder. Kind is the optional :one, :many or :exec after the name,
+// it tells the query generator what the statement returns.
+type Query struct {
+	Name   string
+	Kind   string
+	SQL    string
+	Params []Param
+	File   string
+	Line   int
+}
+
+// Param is a query parameter annotation, Type is optional
+type Param struct {
+	Name string
+	Type string
+}
+
+// Queries are the named queries of the *.sql files of a file system:
+//
+//	-- name: USER_GET_WITH_EMAIL :one
+//	-- :email string
+//	SELECT id, fullname FROM users
+//	WHERE email=$1;
+//
+// A statement runs until the line ending with ";", any statement type is allowed.
+// The old "-- USER_GET_WITH_EMAIL" form is still accepted for upper case names.
+type Queries struct {
+	fsys    fs.FS
+	mu      sync.RWMutex
+	queries map[string]Query
+	stamp   string
+}
+
+// Require registers names of queries the code runs. Loading fails while one of them is missing, so a missing or
+// misspelled query stops the startup instead of failing the first request that runs it.
+func Require(names ...string) {
+	requiredMu.Lock()
+	defer requiredMu.Unlock()
+	for _, name := range names {
+		required[name] = true
+	}
+}
+
+// NewQueries loads the queries of every *.sql file under fsys, use os.DirFS for a directory or an embed.FS
+func NewQueries(fsys fs.FS) (*Queries, error) {
+	q := &Queries{fsys: fsys}
+	if err := q.Load(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// Load reads the files again, the loaded queries are kept when a file fails to parse or a required query is missing
+func (q *Queries) Load() error {
+	queries := map[string]Query{}
+	err := fs.WalkDir(q.fsys, ".", func(file string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || path.Ext(file) != ".sql" {
+			return err
+		}
+		return parseQueryFile(q.fsys, file, queries)
+	})
+	if err != nil {
+		return err
+	}
+	if err := checkRequired(queries); err != nil {
+		return err
+	}
+
+	stamp, _ := q.modStamp()
+	q.mu.Lock()
+	q.queries, q.stamp = queries, stamp
+	q.mu.Unlock()
+	return nil
+}
+
+// Get returns the sql of the named query
+func (q *Queries) Get(name string) (string, error) {
+	query, err := q.Query(name)
+	return query.SQL, err
+}
+
+// Query returns the named query with its metadata
+func (q *Queries) Query(name string) (Query, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	query, ok := q.queries[name]
+	if !ok {
+		return Query{}, fmt.Errorf("query %s not found", name)
+	}
+	return query, nil
+}
+
+// All returns the queries sorted by name
+func (q *Queries) All() []Query {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	queries := make([]Query, 0, len(q.queries))
+	for _, query := range q.queries {
+		queries = append(queries, query)
+	}
+	sort.Slice(queries, func(i, j int) bool { return queries[i].Name < queries[j].Name })
+	return queries
+}
+
+// Watch reloads the queries when a file changes until ctx is done, it is meant for local development
+// with an os.DirFS, embedded files never change. A reload that fails keeps the queries loaded before.
+func (q *Queries) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stamp, err := q.modStamp()
+			q.mu.RLock()
+			changed := err == nil && stamp != q.stamp
+			q.mu.RUnlock()
+			if !changed {
+				continue
+			}
+			if err := q.Load(); err != nil {
+				log.Printf("Reload Sql Error: %v", err)
+				// do not report the same broken files again
+				q.mu.Lock()
+				q.stamp = stamp
+				q.mu.Unlock()
+				continue
+			}
+			log.Println("Sql queries reloaded")
+		}
+	}
+}
+
+// checkRequired returns an error with the required queries that are not in queries
+func checkRequired(queries map[string]Query) error {
+	requiredMu.Lock()
+	defer requiredMu.Unlock()
+	var missing []string
+	for name := range required {
+		if _, ok := queries[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	sort.Strings(missing)
+	return fmt.Errorf("queries not found: %s", strings.Join(missing, ", "))
+}
+
+// modStamp summarizes the names, sizes and modification times of the sql files
+func (q *Queries) modStamp() (string, error) {
+	var stamp strings.Builder
+	err := fs.WalkDir(q.fsys, ".", func(file string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || path.Ext(file) != ".sql" {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(&stamp, "%s:%d:%d;", file, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	return stamp.String(), err
+}
+
+// parseQueryFile adds the queries of the file, a duplicate name, a statement without a name
+// or a name without a terminated statement is an error
+func parseQueryFile(fsys fs.FS, file string, queries map[string]Query) error {
+	f, err := fsys.Open(file)
 	if err != nil {
-		return query, err
+		return err
 	}
 	defer func() {
-		_ = file.Close()
+		_ = f.Close()
 	}()
-	query, err = parseSQLQueries(file, query)
-	return query, err
-}
 
-// parseSQLQueries reads the SQL queries from the provided file and populates the QUERY map.
-func parseSQLQueries(file *os.File, query map[string]string) (map[string]string, error) {
-	scanner := bufio.NewScanner(file)
-	var key string
-	var queryBuilder strings.Builder
+	var current *Query
+	var lines []string
+	lineNo := 0
+
+	scanner := bufio.NewScanner(f)
 	for scanner.Scan() {
+		lineNo++
 		line := strings.TrimSpace(scanner.Text())
-		if isSQLQuery(line) || len(key) > 0 {
-			if len(key) > 0 {
-				if strings.HasSuffix(line, ";") {
-					queryBuilder.WriteString(line)
-					query[key] = queryBuilder.String()
-					key, queryBuilder = "", strings.Builder{}
-				} else {
-					queryBuilder.WriteString(line + " ")
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "--") {
+			name := namePattern.FindStringSubmatch(line)
+			if name == nil && len(lines) == 0 {
+				name = legacyName.FindStringSubmatch(line)
+			}
+			switch {
+			case name != nil:
+				if current != nil {
+					return fmt.Errorf("%s:%d: query %s is not terminated with ;", file, lineNo, current.Name)
+				}
+				if prev, ok := queries[name[1]]; ok {
+					return fmt.Errorf("%s:%d: query %s is already defined at %s:%d", file, lineNo, name[1], prev.File, prev.Line)
+				}
+				current = &Query{Name: name[1], File: file, Line: lineNo}
+				if len(name) > 2 {
+					current.Kind = name[2]
+				}
+			case current != nil && len(lines) == 0:
+				if param := paramPattern.FindStringSubmatch(line); param != nil {
+					current.Params = append(current.Params, Param{Name: param[1], Type: param[2]})
 				}
-			} else {
-				key = extractKey(line)
 			}
+			continue
+		}
+
+		if current == nil {
+			return fmt.Errorf("%s:%d: statement without a name", file, lineNo)
+		}
+		lines = append(lines, line)
+		if strings.HasSuffix(line, ";") {
+			current.SQL = strings.Join(lines, "\n")
+			queries[current.Name] = *current
+			current, lines = nil, nil
 		}
 	}
 	if err := scanner.Err(); err != nil {
-		return query, fmt.Errorf("error reading file: %w", err)
+		return fmt.Errorf("error reading %s: %w", file, err)
 	}
-	return query, nil
-}
-
-// isSQLQuery checks if the given line is an SQL query or a comment.
-func isSQLQuery(line string) bool {
-	return HasPrefixInList(line, []string{"-- ", "SELECT", "INSERT", "UPDATE", "DELETE"})
-}
-
-// extractKey extracts the key from the comment line.
-func extractKey(line string) string {
-	if strings.HasPrefix(line, "-- ") {
-		return strings.Split(line, "-- ")[1]
+	if current != nil {
+		return fmt.Errorf("%s: query %s is not terminated with ;", file, current.Name)
 	}
-	return ""
+	return nil
 }
 
 // HasPrefixInList is a prefix checker
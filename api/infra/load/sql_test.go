@@ -0,0 +1,91 @@
+package load
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequire(t *testing.T) {
+	t.Cleanup(func() {
+		required = map[string]bool{}
+	})
+	dir := t.TempDir()
+	writeQueries(t, dir, "-- name: USER_GET :one\n-- :id int\nSELECT * FROM users WHERE id=$1;\n")
+
+	Require("USER_GET")
+	queries, err := NewQueries(os.DirFS(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	query, err := queries.Query("USER_GET")
+	if err != nil || query.Kind != "one" || len(query.Params) != 1 {
+		t.Fatalf("query %+v, %v", query, err)
+	}
+
+	Require("USER_LIST", "USER_COUNT")
+	if _, err := NewQueries(os.DirFS(dir)); err == nil || !strings.Contains(err.Error(), "USER_COUNT, USER_LIST") {
+		t.Fatalf("missing queries error %v", err)
+	}
+	if err := queries.Load(); err == nil {
+		t.Fatal("a load without the required queries must fail")
+	}
+	if _, err := queries.Get("USER_GET"); err != nil {
+		t.Fatalf("the loaded queries must be kept: %v", err)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	writeQueries(t, dir, "-- name: FIRST\nSELECT 1;\n")
+	queries, err := NewQueries(os.DirFS(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go queries.Watch(ctx, 5*time.Millisecond)
+
+	// a file that does not parse keeps the loaded queries
+	writeQueries(t, dir, "-- name: SECOND\nSELECT 2\n")
+	time.Sleep(50 * time.Millisecond)
+	if sql, err := queries.Get("FIRST"); err != nil || sql != "SELECT 1;" {
+		t.Fatalf("FIRST after a broken reload: %q, %v", sql, err)
+	}
+
+	writeQueries(t, dir, "-- name: SECOND\nSELECT 2;\n")
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := queries.Get("SECOND"); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("SECOND was not reloaded")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if _, err := queries.Get("FIRST"); err == nil {
+		t.Fatal("FIRST must be gone after the reload")
+	}
+}
+
+var writes int
+
+// writeQueries writes the queries file a second later than the last write, so Watch sees the change
+// on file systems with a coarse modification time
+func writeQueries(t *testing.T, dir, content string) {
+	t.Helper()
+	file := filepath.Join(dir, "queries.sql")
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	writes++
+	stamp := time.Now().Add(time.Duration(writes) * time.Second)
+	if err := os.Chtimes(file, stamp, stamp); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -7,16 +7,22 @@ import (
 	"strings"
 
 	"github.com/mstgnz/starter-kit/api/infra/config"
+	"github.com/mstgnz/starter-kit/api/infra/load"
 )
 
+func init() {
+	load.Require("APP_LOG_INSERT")
+}
+
 func logToDB(level string, message string) {
-	stmt, err := config.App().DB.Prepare(config.App().QUERY["APP_LOG_INSERT"])
-	if err == nil && level == "WARNING" {
-		_, _ = stmt.Exec(level, message)
+	if level != "WARNING" || config.App().QUERY == nil {
+		return
+	}
+	query, err := config.App().QUERY.Get("APP_LOG_INSERT")
+	if err != nil {
+		return
 	}
-	defer func() {
-		_ = stmt.Close()
-	}()
+	_, _ = config.App().DB.Exec(query, level, message)
 }
 
 func Info(messages ...string) {
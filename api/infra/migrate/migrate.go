@@ -0,0 +1,308 @@
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mstgnz/starter-kit/api/pkg/mstgnz/gobuilder"
+)
+
+// lockID is the postgres advisory lock key that keeps concurrent runs apart, mysql uses a named lock
+const lockID = 7245117
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is a versioned pair of up and down sql files named <version>_<name>.up.sql and <version>_<name>.down.sql
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// Status is a migration with its applied state, Modified means the up file changed after it was applied
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+	Modified  bool
+}
+
+type Migrator struct {
+	db      *sql.DB
+	fsys    fs.FS
+	dialect gobuilder.SQLDialect
+}
+
+// New creates a migrator for the sql files at the root of fsys
+func New(db *sql.DB, fsys fs.FS) *Migrator {
+	return &Migrator{db: db, fsys: fsys, dialect: gobuilder.Postgres}
+}
+
+// WithDialect sets the SQL dialect of the database, Postgres by default
+func (m *Migrator) WithDialect(dialect gobuilder.SQLDialect) *Migrator {
+	m.dialect = dialect
+	return m
+}
+
+// Load reads the migrations sorted by version
+func (m *Migrator) Load() ([]Migration, error) {
+	entries, err := fs.ReadDir(m.fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		data, err := fs.ReadFile(m.fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(data)
+			sum := sha256.Sum256(data)
+			migration.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			migration.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Up applies the pending migrations, steps limits how many are applied, 0 applies all of them
+func (m *Migrator) Up(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		statuses, err := m.status(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			if status.Modified {
+				return fmt.Errorf("migration %d_%s was modified after it was applied", status.Version, status.Name)
+			}
+		}
+		for _, status := range statuses {
+			if status.AppliedAt != nil {
+				continue
+			}
+			if steps > 0 && len(done) == steps {
+				break
+			}
+			err := m.run(ctx, conn, status.Up, m.bind("INSERT INTO schema_migrations (version, name, checksum) VALUES (?, ?, ?)"), status.Version, status.Name, status.Checksum)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", status.Version, status.Name, err)
+			}
+			done = append(done, status.Migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down reverts the last applied migrations, steps is how many are reverted
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		statuses, err := m.status(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(statuses) - 1; i >= 0 && len(done) < steps; i-- {
+			status := statuses[i]
+			if status.AppliedAt == nil {
+				continue
+			}
+			if status.Down == "" {
+				return fmt.Errorf("migration %d_%s has no down file", status.Version, status.Name)
+			}
+			err := m.run(ctx, conn, status.Down, m.bind("DELETE FROM schema_migrations WHERE version = ?"), status.Version)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", status.Version, status.Name, err)
+			}
+			done = append(done, status.Migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status returns every migration with its applied state
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		var err error
+		statuses, err = m.status(ctx, conn)
+		return err
+	})
+	return statuses, err
+}
+
+// Create writes empty up and down files for a new migration to each dir, the files of every dir share the version
+func Create(name string, dirs ...string) ([]string, error) {
+	name = strings.Trim(regexp.MustCompile(`\W+`).ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return nil, errors.New("migration name is required")
+	}
+
+	version := time.Now().UTC().Format("20060102150405")
+	var files []string
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return files, err
+		}
+		for _, direction := range []string{"up", "down"} {
+			file := filepath.Join(dir, fmt.Sprintf("%s_%s.%s.sql", version, name, direction))
+			if err := os.WriteFile(file, []byte("-- "+direction+" migration\n"), 0o644); err != nil {
+				return files, err
+			}
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// locked runs fn on a single connection holding the migration lock. Postgres and MySQL take a database lock,
+// SQLite serializes writers itself and the other dialects run without a lock.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	var lock, unlock string
+	switch m.dialect {
+	case gobuilder.Postgres:
+		lock, unlock = fmt.Sprintf("SELECT pg_advisory_lock(%d)", lockID), fmt.Sprintf("SELECT pg_advisory_unlock(%d)", lockID)
+	case gobuilder.MySQL:
+		lock, unlock = "SELECT GET_LOCK('schema_migrations', -1)", "SELECT RELEASE_LOCK('schema_migrations')"
+	}
+	if lock != "" {
+		if _, err := conn.ExecContext(ctx, lock); err != nil {
+			return err
+		}
+		defer func() {
+			_, _ = conn.ExecContext(context.Background(), unlock)
+		}()
+	}
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		checksum VARCHAR(64) NOT NULL,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+func (m *Migrator) status(ctx context.Context, conn *sql.Conn) ([]Status, error) {
+	migrations, err := m.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.QueryContext(ctx, "SELECT version, checksum, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	type applied struct {
+		checksum  string
+		appliedAt time.Time
+	}
+	appliedByVersion := map[int64]applied{}
+	for rows.Next() {
+		var version int64
+		var a applied
+		if err := rows.Scan(&version, &a.checksum, &a.appliedAt); err != nil {
+			return nil, err
+		}
+		appliedByVersion[version] = a
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(migrations))
+	for _, migration := range migrations {
+		status := Status{Migration: migration}
+		if a, ok := appliedByVersion[migration.Version]; ok {
+			status.AppliedAt = &a.appliedAt
+			status.Modified = a.checksum != migration.Checksum
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// bind numbers the ? placeholders of the query for the dialect
+func (m *Migrator) bind(query string) string {
+	n := 0
+	return regexp.MustCompile(`\?`).ReplaceAllStringFunc(query, func(string) string {
+		n++
+		return gobuilder.Placeholder(m.dialect, n)
+	})
+}
+
+// run executes the migration sql and records it in one transaction
+func (m *Migrator) run(ctx context.Context, conn *sql.Conn, query, record string, args ...any) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,232 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/mstgnz/starter-kit/api/asset"
+	"github.com/mstgnz/starter-kit/api/pkg/mstgnz/gobuilder"
+	_ "modernc.org/sqlite"
+)
+
+// TestTrees checks that every dialect directory of asset/migrations holds the same migrations as the root
+func TestTrees(t *testing.T) {
+	load := func(dir string) []Migration {
+		t.Helper()
+		sub, err := fs.Sub(asset.Migrations, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		migrations, err := New(nil, sub).Load()
+		if err != nil {
+			t.Fatalf("%s: %v", dir, err)
+		}
+		return migrations
+	}
+
+	root := load("migrations")
+	entries, err := fs.ReadDir(asset.Migrations, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dialect := load("migrations/" + entry.Name())
+		if len(dialect) != len(root) {
+			t.Errorf("migrations/%s has %d migrations, the root %d", entry.Name(), len(dialect), len(root))
+			continue
+		}
+		for i := range root {
+			if dialect[i].Version != root[i].Version || dialect[i].Name != root[i].Name {
+				t.Errorf("migrations/%s has %d_%s, the root %d_%s", entry.Name(), dialect[i].Version, dialect[i].Name, root[i].Version, root[i].Name)
+			}
+			if dialect[i].Down == "" && root[i].Down != "" {
+				t.Errorf("migrations/%s %d_%s has no down file", entry.Name(), dialect[i].Version, dialect[i].Name)
+			}
+		}
+	}
+}
+
+func openSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "migrate.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	return db
+}
+
+func testMigrations() fstest.MapFS {
+	return fstest.MapFS{
+		"1_create_a.up.sql":   {Data: []byte("CREATE TABLE a (id INTEGER)")},
+		"1_create_a.down.sql": {Data: []byte("DROP TABLE a")},
+		"2_create_b.up.sql":   {Data: []byte("CREATE TABLE b (id INTEGER)")},
+		"2_create_b.down.sql": {Data: []byte("DROP TABLE b")},
+		"3_create_c.up.sql":   {Data: []byte("CREATE TABLE c (id INTEGER)")},
+		"3_create_c.down.sql": {Data: []byte("DROP TABLE c")},
+		"README.md":           {Data: []byte("not a migration")},
+	}
+}
+
+// applied returns the versions of the status that are applied
+func applied(t *testing.T, m *Migrator) []int64 {
+	t.Helper()
+	statuses, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var versions []int64
+	for _, status := range statuses {
+		if status.AppliedAt != nil {
+			versions = append(versions, status.Version)
+		}
+	}
+	return versions
+}
+
+func versions(migrations []Migration) []int64 {
+	var list []int64
+	for _, migration := range migrations {
+		list = append(list, migration.Version)
+	}
+	return list
+}
+
+func TestUpDown(t *testing.T) {
+	db := openSQLite(t)
+	ctx := context.Background()
+	m := New(db, testMigrations()).WithDialect(gobuilder.SQLite)
+
+	if got := applied(t, m); len(got) != 0 {
+		t.Fatalf("applied %v before Up, want none", got)
+	}
+	done, err := m.Up(ctx, 2)
+	if err != nil || !slices.Equal(versions(done), []int64{1, 2}) {
+		t.Fatalf("up 2 applied %v, %v, want [1 2]", versions(done), err)
+	}
+	done, err = m.Up(ctx, 0)
+	if err != nil || !slices.Equal(versions(done), []int64{3}) {
+		t.Fatalf("up applied %v, %v, want [3]", versions(done), err)
+	}
+	if done, err := m.Up(ctx, 0); err != nil || len(done) != 0 {
+		t.Fatalf("up again applied %v, %v, want none", versions(done), err)
+	}
+	if got := applied(t, m); !slices.Equal(got, []int64{1, 2, 3}) {
+		t.Fatalf("applied %v, want [1 2 3]", got)
+	}
+
+	done, err = m.Down(ctx, 2)
+	if err != nil || !slices.Equal(versions(done), []int64{3, 2}) {
+		t.Fatalf("down 2 reverted %v, %v, want [3 2]", versions(done), err)
+	}
+	if got := applied(t, m); !slices.Equal(got, []int64{1}) {
+		t.Fatalf("applied %v, want [1]", got)
+	}
+	if _, err := db.ExecContext(ctx, "SELECT * FROM b"); err == nil {
+		t.Fatal("table b of a reverted migration exists")
+	}
+	if _, err := db.ExecContext(ctx, "SELECT * FROM a"); err != nil {
+		t.Fatalf("table a of an applied migration %v", err)
+	}
+}
+
+func TestFailedMigration(t *testing.T) {
+	db := openSQLite(t)
+	ctx := context.Background()
+	migrations := testMigrations()
+	migrations["2_create_b.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE b (id INTEGER); CREATE TABLE broken (")}
+	m := New(db, migrations).WithDialect(gobuilder.SQLite)
+
+	done, err := m.Up(ctx, 0)
+	if err == nil || !strings.Contains(err.Error(), "2_create_b") {
+		t.Fatalf("up %v, want the error of 2_create_b", err)
+	}
+	if !slices.Equal(versions(done), []int64{1}) {
+		t.Fatalf("up applied %v, want [1]", versions(done))
+	}
+	if got := applied(t, m); !slices.Equal(got, []int64{1}) {
+		t.Fatalf("applied %v, want [1]", got)
+	}
+}
+
+// TestModified checks that an up file changed after it was applied is reported and stops Up
+func TestModified(t *testing.T) {
+	db := openSQLite(t)
+	ctx := context.Background()
+	migrations := testMigrations()
+	if _, err := New(db, migrations).WithDialect(gobuilder.SQLite).Up(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	migrations["1_create_a.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE a (id INTEGER, name TEXT)")}
+	m := New(db, migrations).WithDialect(gobuilder.SQLite)
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range statuses {
+		if status.Modified != (status.Version == 1) {
+			t.Fatalf("migration %d modified %v", status.Version, status.Modified)
+		}
+	}
+	if done, err := m.Up(ctx, 0); err == nil || !strings.Contains(err.Error(), "modified") || len(done) != 0 {
+		t.Fatalf("up applied %v, %v, want the modified error", versions(done), err)
+	}
+	if got := applied(t, m); !slices.Equal(got, []int64{1}) {
+		t.Fatalf("applied %v, want [1]", got)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+	}{
+		{"no up file", fstest.MapFS{"1_a.down.sql": {Data: []byte("DROP TABLE a")}}},
+		{"two names", fstest.MapFS{"1_a.up.sql": {Data: []byte("SELECT 1")}, "1_b.down.sql": {Data: []byte("SELECT 1")}}},
+	}
+	for _, tt := range tests {
+		if _, err := New(nil, tt.files).Load(); err == nil {
+			t.Errorf("%s: loaded, want an error", tt.name)
+		}
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	dirs := []string{filepath.Join(dir, "migrations"), filepath.Join(dir, "migrations", "sqlite")}
+	files, err := Create("Add User Notes!", dirs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 4 {
+		t.Fatalf("created %v, want an up and a down file in each dir", files)
+	}
+
+	for _, d := range dirs {
+		migrations, err := New(nil, os.DirFS(d)).Load()
+		if err != nil || len(migrations) != 1 || migrations[0].Down == "" {
+			t.Fatalf("%s: %v, %v, want one migration with a down file", d, migrations, err)
+		}
+	}
+	up := filepath.Base(files[0])
+	if !strings.HasSuffix(up, "_add_user_notes.up.sql") || filepath.Base(files[2]) != up {
+		t.Fatalf("created %v, want the same add_user_notes version in each dir", files)
+	}
+
+	if _, err := Create(" !? ", dir); err == nil {
+		t.Fatal("created a migration without a name")
+	}
+}
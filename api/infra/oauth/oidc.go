@@ -0,0 +1,396 @@
+package oauth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	// HTTPTimeout is the timeout of the requests to the providers
+	HTTPTimeout = 10 * time.Second
+	// JWKSRefreshInterval is the minimum time between two fetches of the signing keys of a provider,
+	// the keys are fetched again when an id token is signed with an unknown key
+	JWKSRefreshInterval = time.Minute
+)
+
+// idTokenMethods are the accepted signing algorithms of id tokens, HMAC is not accepted
+var idTokenMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// Config is the client registration at an OpenID Connect provider
+type Config struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	RedirectURL  string
+}
+
+// metadata is the part of the discovery document that is used
+type metadata struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	UserinfoEndpoint      string   `json:"userinfo_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	TokenAuthMethods      []string `json:"token_endpoint_auth_methods_supported"`
+}
+
+// OIDC is an OpenID Connect provider, the endpoints are discovered from the issuer on first use
+// and the id token is verified with the published keys of the provider
+type OIDC struct {
+	cfg    Config
+	client *http.Client
+
+	mu       sync.Mutex
+	metadata *metadata
+	keys     map[string]any
+	keysAt   time.Time
+}
+
+func NewOIDC(cfg Config) *OIDC {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+	if !slices.Contains(cfg.Scopes, "openid") {
+		cfg.Scopes = append([]string{"openid"}, cfg.Scopes...)
+	}
+	cfg.Issuer = strings.TrimSuffix(cfg.Issuer, "/")
+	return &OIDC{cfg: cfg, client: &http.Client{Timeout: HTTPTimeout}}
+}
+
+func (p *OIDC) Name() string {
+	return p.cfg.Name
+}
+
+func (p *OIDC) AuthURL(ctx context.Context, state, nonce, challenge string) (string, error) {
+	md, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {p.cfg.RedirectURL},
+		"scope":                 {strings.Join(p.cfg.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {challenge},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(md.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return md.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+func (p *OIDC) Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error) {
+	md, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"client_id":     {p.cfg.ClientID},
+		"code_verifier": {verifier},
+	}
+	// client_secret_basic is the default of the spec, client_secret_post is used when it is the only one supported
+	basicAuth := p.cfg.ClientSecret != ""
+	if basicAuth && len(md.TokenAuthMethods) > 0 && !slices.Contains(md.TokenAuthMethods, "client_secret_basic") && slices.Contains(md.TokenAuthMethods, "client_secret_post") {
+		form.Set("client_secret", p.cfg.ClientSecret)
+		basicAuth = false
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, md.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if basicAuth {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	var token struct {
+		AccessToken      string `json:"access_token"`
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	status, err := p.do(req, &token)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK || token.Error != "" {
+		return nil, fmt.Errorf("%s token endpoint: %d %s %s", p.cfg.Name, status, token.Error, token.ErrorDescription)
+	}
+	if token.IDToken == "" {
+		return nil, fmt.Errorf("%s token endpoint: no id_token", p.cfg.Name)
+	}
+
+	claims, err := p.verify(ctx, md, token.IDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+	identity := identityFromClaims(p.cfg.Name, claims)
+
+	// some providers leave the email out of the id token, it is on the userinfo endpoint then
+	if identity.Email == "" && md.UserinfoEndpoint != "" && token.AccessToken != "" {
+		if err := p.userinfo(ctx, md, token.AccessToken, identity); err != nil {
+			return nil, err
+		}
+	}
+	return identity, nil
+}
+
+// verify checks the signature, issuer, audience, expiry and nonce of the id token and returns its claims
+func (p *OIDC) verify(ctx context.Context, md *metadata, raw, nonce string) (jwt.MapClaims, error) {
+	parser := jwt.NewParser(
+		jwt.WithValidMethods(idTokenMethods),
+		jwt.WithIssuer(md.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+	claims := jwt.MapClaims{}
+	_, err := parser.ParseWithClaims(raw, claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, md, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s id token: %w", p.cfg.Name, err)
+	}
+	if claimNonce, _ := claims["nonce"].(string); claimNonce != nonce {
+		return nil, fmt.Errorf("%s id token: nonce mismatch", p.cfg.Name)
+	}
+	if sub, _ := claims["sub"].(string); sub == "" {
+		return nil, fmt.Errorf("%s id token: no subject", p.cfg.Name)
+	}
+	return claims, nil
+}
+
+// userinfo fills the email and name of the identity from the userinfo endpoint
+func (p *OIDC) userinfo(ctx context.Context, md *metadata, accessToken string, identity *Identity) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, md.UserinfoEndpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	claims := map[string]any{}
+	status, err := p.do(req, &claims)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("%s userinfo endpoint: %d", p.cfg.Name, status)
+	}
+
+	info := identityFromClaims(p.cfg.Name, claims)
+	if info.Subject != identity.Subject {
+		return fmt.Errorf("%s userinfo endpoint: subject mismatch", p.cfg.Name)
+	}
+	identity.Email, identity.EmailVerified = info.Email, info.EmailVerified
+	if identity.Name == "" {
+		identity.Name = info.Name
+	}
+	return nil
+}
+
+// discover returns the discovery document of the issuer, it is fetched once
+func (p *OIDC) discover(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.cfg.Issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	md := &metadata{}
+	status, err := p.do(req, md)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("%s discovery: %d", p.cfg.Name, status)
+	}
+	if strings.TrimSuffix(md.Issuer, "/") != p.cfg.Issuer {
+		return nil, fmt.Errorf("%s discovery: issuer %q does not match %q", p.cfg.Name, md.Issuer, p.cfg.Issuer)
+	}
+	if md.AuthorizationEndpoint == "" || md.TokenEndpoint == "" || md.JWKSURI == "" {
+		return nil, fmt.Errorf("%s discovery: missing endpoints", p.cfg.Name)
+	}
+	p.metadata = md
+	return md, nil
+}
+
+// key returns the signing key with the kid, the keys are fetched again for an unknown kid.
+// A token without a kid is accepted when the provider has a single key.
+func (p *OIDC) key(ctx context.Context, md *metadata, kid string) (any, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	lookup := func() (any, bool) {
+		if kid == "" && len(p.keys) == 1 {
+			for _, key := range p.keys {
+				return key, true
+			}
+		}
+		key, ok := p.keys[kid]
+		return key, ok
+	}
+
+	if key, ok := lookup(); ok {
+		return key, nil
+	}
+	if time.Since(p.keysAt) < JWKSRefreshInterval {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, md.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []map[string]any `json:"keys"`
+	}
+	status, err := p.do(req, &set)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("%s jwks: %d", p.cfg.Name, status)
+	}
+
+	keys := map[string]any{}
+	for _, jwk := range set.Keys {
+		if use, _ := jwk["use"].(string); use != "" && use != "sig" {
+			continue
+		}
+		// keys of unsupported types are skipped
+		if key, err := parseJWK(jwk); err == nil {
+			id, _ := jwk["kid"].(string)
+			keys[id] = key
+		}
+	}
+	p.keys, p.keysAt = keys, time.Now()
+
+	if key, ok := lookup(); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key %q", kid)
+}
+
+// do sends the request and decodes the json body of the response into dest
+func (p *OIDC) do(req *http.Request, dest any) (int, error) {
+	res, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return res.StatusCode, err
+	}
+	if err := json.Unmarshal(body, dest); err != nil && res.StatusCode == http.StatusOK {
+		return res.StatusCode, fmt.Errorf("%s %s: %w", p.cfg.Name, req.URL.Path, err)
+	}
+	return res.StatusCode, nil
+}
+
+// parseJWK returns the public key of an RSA, EC or Ed25519 JSON Web Key
+func parseJWK(jwk map[string]any) (any, error) {
+	field := func(name string) ([]byte, error) {
+		value, _ := jwk[name].(string)
+		if value == "" {
+			return nil, fmt.Errorf("jwk without %s", name)
+		}
+		return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+	}
+
+	switch jwk["kty"] {
+	case "RSA":
+		n, err := field("n")
+		if err != nil {
+			return nil, err
+		}
+		e, err := field("e")
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		curves := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+		curve, ok := curves[fmt.Sprint(jwk["crv"])]
+		if !ok {
+			return nil, fmt.Errorf("unsupported curve %v", jwk["crv"])
+		}
+		x, err := field("x")
+		if err != nil {
+			return nil, err
+		}
+		y, err := field("y")
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		if jwk["crv"] != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %v", jwk["crv"])
+		}
+		x, err := field("x")
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %v", jwk["kty"])
+}
+
+// identityFromClaims reads the standard claims, email_verified is a string in the tokens of some providers
+func identityFromClaims(provider string, claims map[string]any) *Identity {
+	identity := &Identity{Provider: provider}
+	identity.Subject, _ = claims["sub"].(string)
+	identity.Email, _ = claims["email"].(string)
+	identity.Name, _ = claims["name"].(string)
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = verified
+	case string:
+		identity.EmailVerified = verified == "true"
+	}
+	return identity
+}
@@ -0,0 +1,137 @@
+package oauth_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mstgnz/starter-kit/api/infra/oauth"
+	"github.com/mstgnz/starter-kit/api/infra/oauth/oidctest"
+)
+
+// start begins a login at the provider like the service does and returns its auth url, nonce and verifier
+func start(t *testing.T, provider *oauth.OIDC) (string, string, string) {
+	t.Helper()
+	nonce, verifier := oauth.RandomString(16), oauth.RandomString(32)
+	authURL, err := provider.AuthURL(context.Background(), oauth.RandomString(32), nonce, oauth.Challenge(verifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return authURL, nonce, verifier
+}
+
+func TestExchange(t *testing.T) {
+	server := oidctest.NewServer(t, "client")
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		claims   map[string]any
+		verifier string
+		nonce    string
+		err      string
+		verified bool
+	}{
+		{name: "valid", claims: map[string]any{"email_verified": true}, verified: true},
+		{name: "email_verified string", claims: map[string]any{"email_verified": "true"}, verified: true},
+		{name: "email_verified false string", claims: map[string]any{"email_verified": "false"}},
+		{name: "wrong verifier", verifier: "wrong", err: "PKCE verification failed"},
+		{name: "nonce mismatch", nonce: "other", err: "nonce mismatch"},
+		{name: "wrong issuer", claims: map[string]any{"iss": "https://evil.test"}, err: "invalid issuer"},
+		{name: "wrong audience", claims: map[string]any{"aud": "other-client"}, err: "invalid audience"},
+		{name: "expired", claims: map[string]any{"exp": time.Now().Add(-time.Hour).Unix()}, err: "expired"},
+		{name: "no subject", claims: map[string]any{"sub": ""}, err: "no subject"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := server.Provider("stub")
+			authURL, nonce, verifier := start(t, provider)
+			claims := map[string]any{"sub": "user-1", "email": "user@oidc.test", "name": "User"}
+			for name, value := range tt.claims {
+				claims[name] = value
+			}
+			code, _ := server.Authorize(t, authURL, claims)
+
+			if tt.verifier != "" {
+				verifier = tt.verifier
+			}
+			if tt.nonce != "" {
+				nonce = tt.nonce
+			}
+			identity, err := provider.Exchange(ctx, code, verifier, nonce)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if identity.Provider != "stub" || identity.Subject != "user-1" || identity.Email != "user@oidc.test" || identity.Name != "User" {
+				t.Fatalf("identity %+v", identity)
+			}
+			if identity.EmailVerified != tt.verified {
+				t.Fatalf("email verified %v, want %v", identity.EmailVerified, tt.verified)
+			}
+		})
+	}
+}
+
+// TestExchangeCodeOnce checks that a code cannot be redeemed twice
+func TestExchangeCodeOnce(t *testing.T) {
+	server := oidctest.NewServer(t, "client")
+	provider := server.Provider("stub")
+	authURL, nonce, verifier := start(t, provider)
+	code, _ := server.Authorize(t, authURL, map[string]any{"sub": "user-1"})
+
+	if _, err := provider.Exchange(context.Background(), code, verifier, nonce); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.Exchange(context.Background(), code, verifier, nonce); err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Fatalf("second exchange %v, want invalid_grant", err)
+	}
+}
+
+// TestKeyRotation checks that an id token of an unknown key fetches the keys again, at most once per JWKSRefreshInterval
+func TestKeyRotation(t *testing.T) {
+	server := oidctest.NewServer(t, "client")
+	provider := server.Provider("stub")
+	ctx := context.Background()
+
+	exchange := func() error {
+		authURL, nonce, verifier := start(t, provider)
+		code, _ := server.Authorize(t, authURL, map[string]any{"sub": "user-1"})
+		_, err := provider.Exchange(ctx, code, verifier, nonce)
+		return err
+	}
+
+	if err := exchange(); err != nil {
+		t.Fatal(err)
+	}
+	if err := exchange(); err != nil {
+		t.Fatal(err)
+	}
+	if fetches := server.JWKSFetches(); fetches != 1 {
+		t.Fatalf("keys fetched %d times, want once for the same key", fetches)
+	}
+
+	// the keys were just fetched, an unknown key is not fetched again within the interval
+	server.RotateKey()
+	if err := exchange(); err == nil || !strings.Contains(err.Error(), "unknown key") {
+		t.Fatalf("exchange with a rotated key %v, want unknown key", err)
+	}
+
+	interval := oauth.JWKSRefreshInterval
+	oauth.JWKSRefreshInterval = 0
+	t.Cleanup(func() {
+		oauth.JWKSRefreshInterval = interval
+	})
+	if err := exchange(); err != nil {
+		t.Fatalf("exchange after the refetch: %v", err)
+	}
+	if fetches := server.JWKSFetches(); fetches != 2 {
+		t.Fatalf("keys fetched %d times, want 2", fetches)
+	}
+}
@@ -0,0 +1,175 @@
+// Package oidctest is a local OpenID Connect provider for the tests of the social login, it serves the discovery
+// document, the signing keys and a token endpoint that checks the PKCE verifier
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/mstgnz/starter-kit/api/infra/oauth"
+)
+
+// Server is the stub provider, Authorize stands in for the consent page
+type Server struct {
+	*httptest.Server
+	ClientID string
+
+	mu          sync.Mutex
+	key         *rsa.PrivateKey
+	kid         int
+	codes       map[string]grant
+	jwksFetches int
+}
+
+// grant is an authorization code waiting to be redeemed
+type grant struct {
+	challenge   string
+	redirectURI string
+	claims      jwt.MapClaims
+}
+
+// NewServer starts a provider for the client id, it is closed at the end of the test
+func NewServer(tb testing.TB, clientID string) *Server {
+	tb.Helper()
+	s := &Server{ClientID: clientID, codes: map[string]grant{}}
+	s.RotateKey()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("GET /jwks", s.jwks)
+	mux.HandleFunc("POST /token", s.token)
+	s.Server = httptest.NewServer(mux)
+	tb.Cleanup(s.Close)
+	return s
+}
+
+// Provider returns a provider of the server with the name
+func (s *Server) Provider(name string) *oauth.OIDC {
+	return oauth.NewOIDC(oauth.Config{Name: name, Issuer: s.URL, ClientID: s.ClientID, ClientSecret: "secret", RedirectURL: "https://app.test/callback"})
+}
+
+// Authorize consents to the login of authURL and returns the code and the state the provider redirects back with.
+// The id token of the code has the iss, aud, exp, iat and nonce of the login, claims are added and replace them.
+func (s *Server) Authorize(tb testing.TB, authURL string, claims map[string]any) (string, string) {
+	tb.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	query := u.Query()
+	if query.Get("client_id") != s.ClientID || query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		tb.Fatalf("invalid authorization request %s", authURL)
+	}
+
+	now := time.Now()
+	idClaims := jwt.MapClaims{
+		"iss":   s.URL,
+		"aud":   s.ClientID,
+		"exp":   now.Add(time.Hour).Unix(),
+		"iat":   now.Unix(),
+		"nonce": query.Get("nonce"),
+	}
+	for name, value := range claims {
+		idClaims[name] = value
+	}
+
+	code := oauth.RandomString(16)
+	s.mu.Lock()
+	s.codes[code] = grant{challenge: query.Get("code_challenge"), redirectURI: query.Get("redirect_uri"), claims: idClaims}
+	s.mu.Unlock()
+	return code, query.Get("state")
+}
+
+// RotateKey replaces the signing key with a new one under a new kid, the old key is no longer published
+func (s *Server) RotateKey() {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.key = key
+	s.kid++
+}
+
+// JWKSFetches returns how many times the keys were fetched
+func (s *Server) JWKSFetches() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jwksFetches
+}
+
+func (s *Server) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                 s.URL,
+		"authorization_endpoint": s.URL + "/authorize",
+		"token_endpoint":         s.URL + "/token",
+		"jwks_uri":               s.URL + "/jwks",
+	})
+}
+
+func (s *Server) jwks(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jwksFetches++
+	writeJSON(w, http.StatusOK, map[string]any{"keys": []map[string]any{{
+		"kty": "RSA",
+		"use": "sig",
+		"alg": "RS256",
+		"kid": fmt.Sprintf("key-%d", s.kid),
+		"n":   base64.RawURLEncoding.EncodeToString(s.key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(s.key.E)).Bytes()),
+	}}})
+}
+
+// token redeems a code once, the verifier must match the challenge of the authorization request
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid_request"})
+		return
+	}
+	if clientID, secret, ok := r.BasicAuth(); !ok || clientID != s.ClientID || secret == "" {
+		writeJSON(w, http.StatusUnauthorized, map[string]any{"error": "invalid_client"})
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	code := r.PostForm.Get("code")
+	grant, ok := s.codes[code]
+	delete(s.codes, code)
+	if !ok || r.PostForm.Get("grant_type") != "authorization_code" || r.PostForm.Get("redirect_uri") != grant.redirectURI {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid_grant"})
+		return
+	}
+	if oauth.Challenge(r.PostForm.Get("code_verifier")) != grant.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid_grant", "error_description": "PKCE verification failed"})
+		return
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, grant.claims)
+	token.Header["kid"] = fmt.Sprintf("key-%d", s.kid)
+	idToken, err := token.SignedString(s.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"access_token": oauth.RandomString(16), "token_type": "Bearer", "id_token": idToken})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,112 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Identity is the user a provider authenticated, Subject is the stable id of the user at the provider
+type Identity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Provider is an OAuth2 / OpenID Connect login provider using the authorization code flow with PKCE
+type Provider interface {
+	// Name is the name of the provider in the routes, e.g. google for /oauth/google/start
+	Name() string
+	// AuthURL returns the url of the consent page, state and nonce are echoed back, challenge is the S256 PKCE challenge
+	AuthURL(ctx context.Context, state, nonce, challenge string) (string, error)
+	// Exchange redeems the code with the PKCE verifier and returns the authenticated user,
+	// nonce is the one given to AuthURL
+	Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error)
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   = map[string]Provider{}
+)
+
+// Register adds the provider, a provider with the same name is replaced
+func Register(provider Provider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[provider.Name()] = provider
+}
+
+// Get returns the registered provider with the name
+func Get(name string) (Provider, bool) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	provider, ok := providers[name]
+	return provider, ok
+}
+
+// Names returns the names of the registered providers
+func Names() []string {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadProviders registers the OpenID Connect providers configured in env, "google" is configured with:
+//
+//	OAUTH_PROVIDERS             comma separated provider names, e.g. google,microsoft
+//	OAUTH_GOOGLE_ISSUER         issuer url, the endpoints are read from its /.well-known/openid-configuration
+//	OAUTH_GOOGLE_CLIENT_ID      client id
+//	OAUTH_GOOGLE_CLIENT_SECRET  client secret, empty for public clients
+//	OAUTH_GOOGLE_SCOPES         space separated scopes, defaults to "openid email profile"
+//	OAUTH_GOOGLE_REDIRECT_URL   defaults to API_URL/v1/oauth/google/callback
+func LoadProviders() error {
+	for _, name := range strings.Split(os.Getenv("OAUTH_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := "OAUTH_" + strings.ToUpper(name) + "_"
+		cfg := Config{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			Scopes:       strings.Fields(os.Getenv(prefix + "SCOPES")),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+		}
+		if cfg.Issuer == "" || cfg.ClientID == "" {
+			return fmt.Errorf("oauth provider %s needs %sISSUER and %sCLIENT_ID", name, prefix, prefix)
+		}
+		if cfg.RedirectURL == "" {
+			cfg.RedirectURL = os.Getenv("API_URL") + "/v1/oauth/" + name + "/callback"
+		}
+		Register(NewOIDC(cfg))
+	}
+	return nil
+}
+
+// RandomString returns a url safe random string of n bytes of entropy, used for states, nonces and PKCE verifiers
+func RandomString(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Challenge returns the S256 PKCE challenge of the verifier
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
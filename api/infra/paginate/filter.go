@@ -0,0 +1,155 @@
+package paginate
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mstgnz/starter-kit/api/infra/response"
+	"github.com/mstgnz/starter-kit/api/pkg/mstgnz/gobuilder"
+)
+
+// operators are the filter operators and their SQL operators, like, in and null are compiled on their own
+var operators = map[string]string{
+	"eq":   "=",
+	"ne":   "<>",
+	"gt":   ">",
+	"gte":  ">=",
+	"lt":   "<",
+	"lte":  "<=",
+	"like": "LIKE",
+	"in":   "IN",
+	"null": "IS",
+}
+
+// Error is an invalid pagination, sort or filter parameter, it is the client's fault
+type Error struct {
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Response returns the 400 response of the error
+func (e *Error) Response() response.Response {
+	return response.Response{Code: http.StatusBadRequest, Success: false, Message: e.Message}
+}
+
+// Condition is a filter of a field, filter[created_at][gte]=2025-01-01 is {created_at gte 2025-01-01}
+type Condition struct {
+	Field    string
+	Operator string
+	Value    string
+}
+
+// Filters are the conditions of the filter query parameters, they are combined with AND:
+//
+//	filter[status]=active               status = 'active', eq is the default operator
+//	filter[created_at][gte]=2025-01-01  created_at >= '2025-01-01', also ne, gt, lt and lte
+//	filter[email][like]=example         email contains example, case insensitive, % and _ are matched as they are
+//	filter[id][in]=1,2,3                id IN (1, 2, 3)
+//	filter[deleted_at][null]=true       deleted_at IS NULL, false for IS NOT NULL
+//
+// Values are parsed to the type of the field before the query runs, times as 2006-01-02, 2006-01-02 15:04:05
+// or RFC 3339, and a value that does not parse is an Error.
+type Filters []Condition
+
+// LikeEscape is the escape clause of the Contains patterns. The escape character is ! since a backslash
+// is an escape in the string literals of MySQL as well.
+const LikeEscape = "ESCAPE '!'"
+
+var likeReplacer = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// Contains returns the lower case LIKE pattern that matches value anywhere, the wildcards % and _ in value
+// are escaped so they only match themselves, the LIKE must be followed by LikeEscape
+func Contains(value string) string {
+	return "%" + likeReplacer.Replace(strings.ToLower(value)) + "%"
+}
+
+// BindQuery reads the filters from the query parameters with the name prefix, handle.Handle calls it
+func (f *Filters) BindQuery(name string, query url.Values) error {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		rest, ok := strings.CutPrefix(key, name+"[")
+		if !ok {
+			continue
+		}
+		field, rest, _ := strings.Cut(rest, "]")
+		operator := "eq"
+		if rest != "" {
+			op, ok := strings.CutPrefix(rest, "[")
+			if !ok || !strings.HasSuffix(op, "]") {
+				return &Error{Message: "invalid filter " + key}
+			}
+			operator = strings.TrimSuffix(op, "]")
+		}
+		if field == "" {
+			return &Error{Message: "invalid filter " + key}
+		}
+		if _, ok := operators[operator]; !ok {
+			return &Error{Message: "unknown filter operator " + operator}
+		}
+		for _, value := range query[key] {
+			*f = append(*f, Condition{Field: field, Operator: operator, Value: value})
+		}
+	}
+	return nil
+}
+
+// Compile parses the value of the condition and returns the where clause of it,
+// parse converts a value to the type of the field
+func (c Condition) Compile(parse func(value string) (any, error)) (func(b *gobuilder.GoBuilder) *gobuilder.GoBuilder, error) {
+	invalid := &Error{Message: fmt.Sprintf("invalid value for filter %s", c.Field)}
+
+	switch c.Operator {
+	case "like":
+		like := Contains(c.Value)
+		return func(b *gobuilder.GoBuilder) *gobuilder.GoBuilder {
+			return b.WhereRaw("lower("+gobuilder.Quote(b.Dialect(), c.Field)+") LIKE ? "+LikeEscape, like)
+		}, nil
+	case "in":
+		var values []any
+		for _, item := range strings.Split(c.Value, ",") {
+			value, err := parse(strings.TrimSpace(item))
+			if err != nil {
+				return nil, invalid
+			}
+			values = append(values, value)
+		}
+		return func(b *gobuilder.GoBuilder) *gobuilder.GoBuilder {
+			return b.In(c.Field, values...)
+		}, nil
+	case "null":
+		isNull, err := strconv.ParseBool(c.Value)
+		if err != nil {
+			return nil, invalid
+		}
+		return func(b *gobuilder.GoBuilder) *gobuilder.GoBuilder {
+			if isNull {
+				return b.IsNull(c.Field)
+			}
+			return b.IsNotNull(c.Field)
+		}, nil
+	}
+
+	operator, ok := operators[c.Operator]
+	if !ok {
+		return nil, &Error{Message: "unknown filter operator " + c.Operator}
+	}
+	value, err := parse(c.Value)
+	if err != nil {
+		return nil, invalid
+	}
+	return func(b *gobuilder.GoBuilder) *gobuilder.GoBuilder {
+		return b.Where(c.Field, operator, value)
+	}, nil
+}
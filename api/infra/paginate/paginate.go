@@ -0,0 +1,164 @@
+package paginate
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/mstgnz/starter-kit/api/infra/config"
+	"github.com/mstgnz/starter-kit/api/infra/response"
+)
+
+var (
+	DefaultPerPage = 20
+	MaxPerPage     = 100
+
+	ErrInvalidCursor = &Error{Message: "invalid cursor"}
+)
+
+// Params are the pagination, sort, search and filter query parameters, embed it in a request to bind them with handle.Handle:
+//
+//	type UserList struct {
+//		paginate.Params
+//	}
+//
+// A request with a cursor is served in keyset mode, otherwise in offset mode.
+// ?sort=-created_at sorts by created_at desc, ?q=ali searches the searchable fields and
+// ?filter[email]=a@b.c keeps the rows with that email, see Filters for the operators.
+type Params struct {
+	Page    int     `json:"-" query:"page"`
+	PerPage int     `json:"-" query:"per_page"`
+	Cursor  string  `json:"-" query:"cursor"`
+	Sort    string  `json:"-" query:"sort"`
+	Search  string  `json:"-" query:"q"`
+	Filters Filters `json:"-" query:"filter"`
+}
+
+// Normalize clamps page and per_page and sets the default sort, page is clamped so Offset cannot overflow
+func (p *Params) Normalize(defaultSort string) {
+	if p.PerPage == 0 {
+		p.PerPage = DefaultPerPage
+	}
+	p.PerPage = config.Clamp(p.PerPage, 1, MaxPerPage)
+	p.Page = config.Clamp(p.Page, 1, int(^uint(0)>>1)/p.PerPage)
+	if p.Sort == "" {
+		p.Sort = defaultSort
+	}
+}
+
+// Offset returns the offset of the page
+func (p *Params) Offset() int {
+	return (p.Page - 1) * p.PerPage
+}
+
+// SortColumn returns the sort column and whether it is descending, "-created_at" sorts by created_at desc
+func (p *Params) SortColumn() (string, bool) {
+	if column, ok := strings.CutPrefix(p.Sort, "-"); ok {
+		return column, true
+	}
+	return p.Sort, false
+}
+
+// Keyset reports whether the request is in keyset mode
+func (p *Params) Keyset() bool {
+	return p.Cursor != ""
+}
+
+// Cursor is the position after the last item of a page, Value is the sort column and ID the primary key
+type Cursor struct {
+	Value any `json:"v"`
+	ID    any `json:"id"`
+}
+
+// EncodeCursor encodes the cursor to an opaque url safe string
+func EncodeCursor(cursor Cursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor decodes a cursor created by EncodeCursor, numbers are kept as json.Number
+func DecodeCursor(value string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.UseNumber()
+	cursor := &Cursor{}
+	if err := dec.Decode(cursor); err != nil || cursor.ID == nil {
+		return nil, ErrInvalidCursor
+	}
+	return cursor, nil
+}
+
+// Meta is the pagination metadata, Total, Page and LastPage are only set in offset mode
+type Meta struct {
+	Total      *int   `json:"total,omitempty"`
+	Page       int    `json:"page,omitempty"`
+	PerPage    int    `json:"per_page"`
+	LastPage   int    `json:"last_page,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Page is a page of items
+type Page[T any] struct {
+	Items []T
+	Meta  Meta
+}
+
+// Response returns the page as a response with the RFC 8288 Link header,
+// links are built from the request url that handle.Handle puts in the context
+func (p *Page[T]) Response(ctx context.Context, message string) response.Response {
+	res := response.Response{
+		Code:    http.StatusOK,
+		Success: true,
+		Message: message,
+		Data:    map[string]any{"items": p.Items, "meta": p.Meta},
+	}
+	if requestUrl, ok := ctx.Value(config.CKey("requestUrl")).(*url.URL); ok {
+		if link := p.Link(requestUrl); link != "" {
+			res.SetHeader("Link", link)
+		}
+	}
+	return res
+}
+
+// Link returns the Link header value for the page
+func (p *Page[T]) Link(requestUrl *url.URL) string {
+	var links []string
+	add := func(rel string, set map[string]string) {
+		u := *requestUrl
+		query := u.Query()
+		for key, value := range set {
+			if value == "" {
+				query.Del(key)
+			} else {
+				query.Set(key, value)
+			}
+		}
+		u.RawQuery = query.Encode()
+		links = append(links, fmt.Sprintf(`<%s>; rel="%s"`, u.RequestURI(), rel))
+	}
+
+	if p.Meta.Page > 0 {
+		add("first", map[string]string{"page": "1", "cursor": ""})
+		if p.Meta.Page > 1 {
+			add("prev", map[string]string{"page": strconv.Itoa(p.Meta.Page - 1), "cursor": ""})
+		}
+		if p.Meta.Page < p.Meta.LastPage {
+			add("next", map[string]string{"page": strconv.Itoa(p.Meta.Page + 1), "cursor": ""})
+		}
+		if p.Meta.LastPage > 0 {
+			add("last", map[string]string{"page": strconv.Itoa(p.Meta.LastPage), "cursor": ""})
+		}
+	} else if p.Meta.NextCursor != "" {
+		add("next", map[string]string{"cursor": p.Meta.NextCursor, "page": ""})
+	}
+
+	return strings.Join(links, ", ")
+}
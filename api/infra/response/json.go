@@ -15,6 +15,10 @@ func ReadJSON(w http.ResponseWriter, r *http.Request, data any) error {
 	dec := json.NewDecoder(r.Body)
 	err := dec.Decode(data)
 	if err != nil {
+		// requests without a body (GET, DELETE) are valid
+		if err == io.EOF {
+			return nil
+		}
 		return err
 	}
 
@@ -1,12 +1,16 @@
 package response
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"net/http"
+)
 
 type Response struct {
 	Code    int            `json:"code"`
 	Success bool           `json:"success"`
 	Message string         `json:"message"`
 	Data    map[string]any `json:"data"`
+	Headers http.Header    `json:"-"`
 }
 
 func (r *Response) SetCode(code int) *Response {
@@ -29,6 +33,14 @@ func (r *Response) SetData(key string, value any) *Response {
 	return r
 }
 
+func (r *Response) SetHeader(key, value string) *Response {
+	if r.Headers == nil {
+		r.Headers = http.Header{}
+	}
+	r.Headers.Add(key, value)
+	return r
+}
+
 func (r *Response) SetModel(model any, key string) error {
 	data, err := json.Marshal(r.Data[key])
 	if err != nil {
@@ -0,0 +1,56 @@
+package seed
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/mstgnz/starter-kit/api/infra/auth"
+	"github.com/mstgnz/starter-kit/api/infra/conn"
+	"github.com/mstgnz/starter-kit/api/pkg/mstgnz/gobuilder"
+)
+
+func init() {
+	Register("admin", Admin)
+}
+
+// Admin creates the admin user from ADMIN_NAME, ADMIN_EMAIL and ADMIN_PASSWORD and gives it the admin role,
+// a random password is generated and logged when ADMIN_PASSWORD is empty
+func Admin(ctx context.Context, db *conn.DB) error {
+	name := os.Getenv("ADMIN_NAME")
+	if name == "" {
+		name = "Admin"
+	}
+	email := os.Getenv("ADMIN_EMAIL")
+	if email == "" {
+		email = "admin@example.com"
+	}
+	password := os.Getenv("ADMIN_PASSWORD")
+	if password == "" {
+		password = auth.RandomHex(8)
+		log.Printf("Admin user %s created with password %s", email, password)
+	}
+
+	user := map[string]any{"fullname": name, "email": email, "password": auth.HashAndSalt(password), "is_admin": true}
+	if err := db.DynamicUpdate(ctx, db.Builder().Table("users").Create(user).OnConflict("email").DoNothing()); err != nil {
+		return err
+	}
+
+	var userId, roleId int
+	if err := scanID(ctx, db, db.Builder().Table("users").Select("id").Where("email", "=", email), &userId); err != nil {
+		return err
+	}
+	if err := scanID(ctx, db, db.Builder().Table("roles").Select("id").Where("name", "=", "admin"), &roleId); err != nil {
+		return fmt.Errorf("admin role: %w", err)
+	}
+
+	role := map[string]any{"user_id": userId, "role_id": roleId}
+	return db.DynamicUpdate(ctx, db.Builder().Table("user_roles").Create(role).OnConflict().DoNothing())
+}
+
+// scanID scans the id selected by the builder into dest
+func scanID(ctx context.Context, db *conn.DB, builder *gobuilder.GoBuilder, dest *int) error {
+	query, params := builder.Prepare()
+	return db.QueryRowContext(ctx, query, params...).Scan(dest)
+}
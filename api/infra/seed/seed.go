@@ -0,0 +1,198 @@
+package seed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/mstgnz/starter-kit/api/infra/conn"
+	"github.com/mstgnz/starter-kit/api/pkg/mstgnz/gobuilder"
+	"gopkg.in/yaml.v3"
+)
+
+// Func is a Go seeder, it runs in a transaction carried by ctx
+type Func func(ctx context.Context, db *conn.DB) error
+
+// Table is a set of rows for a table in a YAML or JSON seed or fixture file:
+//
+//	# roles.yaml
+//	- table: roles
+//	  rows:
+//	    - name: editor
+type Table struct {
+	Table string           `json:"table" yaml:"table"`
+	Rows  []map[string]any `json:"rows" yaml:"rows"`
+}
+
+// Seeder is a named seeder, either a Go function or a data file
+type Seeder struct {
+	Name string
+	Run  Func
+}
+
+var registry []Seeder
+
+// Register adds a Go seeder, seeders run in registration order before the file seeders
+func Register(name string, fn Func) {
+	registry = append(registry, Seeder{Name: name, Run: fn})
+}
+
+// Seeders returns the registered Go seeders followed by the *.yaml, *.yml and *.json files of fsys sorted by name,
+// a file seeder is named after its file without the extension
+func Seeders(fsys fs.FS) ([]Seeder, error) {
+	seeders := append([]Seeder{}, registry...)
+	if fsys == nil {
+		return seeders, nil
+	}
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	for _, entry := range entries {
+		ext := path.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml" && ext != ".json") {
+			continue
+		}
+		tables, err := ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		seeders = append(seeders, Seeder{
+			Name: strings.TrimSuffix(entry.Name(), ext),
+			Run: func(ctx context.Context, db *conn.DB) error {
+				return Insert(ctx, db, tables, false)
+			},
+		})
+	}
+	return seeders, nil
+}
+
+// Run runs the seeders that did not run before, or only the named ones when names are given.
+// Every seeder runs in its own transaction and is recorded in the seeders table, force runs it again.
+// The queries are built for the dialect of db.
+func Run(ctx context.Context, db *conn.DB, seeders []Seeder, force bool, names ...string) ([]string, error) {
+	_, err := db.ExecContext(conn.OneShot(ctx), `CREATE TABLE IF NOT EXISTS seeders (
+		name VARCHAR(255) PRIMARY KEY,
+		ran_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := seeders
+	if len(names) > 0 {
+		selected = nil
+		for _, name := range names {
+			seeder, ok := find(seeders, name)
+			if !ok {
+				return nil, fmt.Errorf("seeder %s not found", name)
+			}
+			selected = append(selected, seeder)
+		}
+	}
+
+	var ran []string
+	for _, seeder := range selected {
+		skipped := false
+		err := db.WithTx(ctx, func(ctx context.Context) error {
+			if !force {
+				exists, err := db.DynamicCount(ctx, db.Builder().Table("seeders").SelectRaw("count(*)").Where("name", "=", seeder.Name))
+				if err != nil {
+					return err
+				}
+				if skipped = exists > 0; skipped {
+					return nil
+				}
+			}
+			if err := seeder.Run(ctx, db); err != nil {
+				return err
+			}
+			record := db.Builder().Table("seeders").
+				Create(map[string]any{"name": seeder.Name, "ran_at": gobuilder.Raw("CURRENT_TIMESTAMP")}).
+				OnConflict("name").DoUpdate("ran_at")
+			return db.DynamicUpdate(ctx, record)
+		})
+		if err != nil {
+			return ran, fmt.Errorf("seeder %s: %w", seeder.Name, err)
+		}
+		if !skipped {
+			ran = append(ran, seeder.Name)
+		}
+	}
+	return ran, nil
+}
+
+// LoadFixtures replaces the rows of the tables in the fixture files with the rows of the files,
+// it is meant for test databases:
+//
+//	err := seed.LoadFixtures(ctx, testDB, os.DirFS("testdata"), "users.yaml", "roles.yaml")
+func LoadFixtures(ctx context.Context, db *conn.DB, fsys fs.FS, files ...string) error {
+	var tables []Table
+	for _, file := range files {
+		fileTables, err := ReadFile(fsys, file)
+		if err != nil {
+			return err
+		}
+		tables = append(tables, fileTables...)
+	}
+
+	return db.WithTx(ctx, func(ctx context.Context) error {
+		return Insert(ctx, db, tables, true)
+	})
+}
+
+// ReadFile reads the tables of a YAML or JSON file
+func ReadFile(fsys fs.FS, file string) ([]Table, error) {
+	data, err := fs.ReadFile(fsys, file)
+	if err != nil {
+		return nil, err
+	}
+
+	var tables []Table
+	if path.Ext(file) == ".json" {
+		err = json.Unmarshal(data, &tables)
+	} else {
+		err = yaml.Unmarshal(data, &tables)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return tables, nil
+}
+
+// Insert inserts the rows of the tables in order, existing rows are skipped.
+// With clean the tables are emptied first, in reverse order so referencing tables go first.
+func Insert(ctx context.Context, db *conn.DB, tables []Table, clean bool) error {
+	if clean {
+		for i := len(tables) - 1; i >= 0; i-- {
+			if _, err := db.ExecContext(conn.OneShot(ctx), "DELETE FROM "+tables[i].Table); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, table := range tables {
+		for _, row := range table.Rows {
+			if err := db.DynamicUpdate(ctx, db.Builder().Table(table.Table).Create(row).OnConflict().DoNothing()); err != nil {
+				return fmt.Errorf("%s: %w", table.Table, err)
+			}
+		}
+	}
+	return nil
+}
+
+func find(seeders []Seeder, name string) (Seeder, bool) {
+	for _, seeder := range seeders {
+		if seeder.Name == name {
+			return seeder, true
+		}
+	}
+	return Seeder{}, false
+}
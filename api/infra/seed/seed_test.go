@@ -0,0 +1,101 @@
+package seed
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"github.com/mstgnz/starter-kit/api/infra/testdb"
+)
+
+func TestRun(t *testing.T) {
+	db := testdb.Open(t)
+	ctx := context.Background()
+	t.Setenv("ADMIN_EMAIL", "admin@seed.test")
+	t.Setenv("ADMIN_PASSWORD", "secret")
+
+	seeders, err := Seeders(fstest.MapFS{
+		"people.yaml": {Data: []byte("- table: users\n  rows:\n    - fullname: Seeded\n      email: seeded@seed.test\n      password: x\n")},
+		"notes.txt":   {Data: []byte("not a seeder")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ran, err := Run(ctx, db, seeders, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(ran, []string{"admin", "people"}) {
+		t.Fatalf("ran %v, want [admin people]", ran)
+	}
+
+	admins, err := db.DynamicCount(ctx, db.Builder().Table("user_roles ur").SelectRaw("count(*)").
+		Join("users u", "u.id", "=", "ur.user_id").Join("roles r", "r.id", "=", "ur.role_id").
+		Where("u.email", "=", "admin@seed.test").Where("r.name", "=", "admin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if admins != 1 {
+		t.Fatalf("admin has %d admin roles, want 1", admins)
+	}
+
+	if ran, err = Run(ctx, db, seeders, false); err != nil || len(ran) != 0 {
+		t.Fatalf("second run ran %v, %v, want nothing", ran, err)
+	}
+	if ran, err = Run(ctx, db, seeders, true, "people"); err != nil || !slices.Equal(ran, []string{"people"}) {
+		t.Fatalf("forced run ran %v, %v, want [people]", ran, err)
+	}
+	if _, err = Run(ctx, db, seeders, false, "missing"); err == nil {
+		t.Fatal("an unknown seeder must fail")
+	}
+}
+
+func TestLoadFixtures(t *testing.T) {
+	db := testdb.Open(t)
+	ctx := context.Background()
+
+	fixtures := fstest.MapFS{
+		"roles.yaml": {Data: []byte("- table: roles\n  rows:\n    - name: fixture_editor\n    - name: fixture_viewer\n")},
+		"roles.json": {Data: []byte(`[{"table": "roles", "rows": [{"name": "fixture_owner"}]}]`)},
+	}
+
+	names := func() []string {
+		rows, err := db.QueryContext(ctx, "SELECT name FROM roles ORDER BY name")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = rows.Close()
+		}()
+		var names []string
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				t.Fatal(err)
+			}
+			names = append(names, name)
+		}
+		return names
+	}
+
+	if err := LoadFixtures(ctx, db, fixtures, "roles.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	if got := names(); !slices.Equal(got, []string{"fixture_editor", "fixture_viewer"}) {
+		t.Fatalf("roles %v, want the rows of roles.yaml", got)
+	}
+
+	// loading again replaces the rows
+	if err := LoadFixtures(ctx, db, fixtures, "roles.json"); err != nil {
+		t.Fatal(err)
+	}
+	if got := names(); !slices.Equal(got, []string{"fixture_owner"}) {
+		t.Fatalf("roles %v, want the rows of roles.json", got)
+	}
+
+	if err := LoadFixtures(ctx, db, fixtures, "missing.yaml"); err == nil {
+		t.Fatal("a missing fixture file must fail")
+	}
+}
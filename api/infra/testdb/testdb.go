@@ -0,0 +1,79 @@
+// Package testdb connects config.App() to a migrated SQLite database for the tests of a package
+package testdb
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/mstgnz/starter-kit/api/asset"
+	"github.com/mstgnz/starter-kit/api/infra/auth"
+	"github.com/mstgnz/starter-kit/api/infra/config"
+	"github.com/mstgnz/starter-kit/api/infra/conn"
+	"github.com/mstgnz/starter-kit/api/infra/load"
+	"github.com/mstgnz/starter-kit/api/infra/migrate"
+)
+
+var (
+	once     sync.Once
+	setupErr error
+)
+
+// Open returns config.App().DB on a new SQLite file with every migration applied and the named queries loaded.
+// The database is created once per test binary and shared by its tests, so tests must not depend on an empty table.
+func Open(tb testing.TB) *conn.DB {
+	tb.Helper()
+	once.Do(func() {
+		setupErr = setup()
+	})
+	if setupErr != nil {
+		tb.Fatalf("test database: %v", setupErr)
+	}
+	return config.App().DB
+}
+
+func setup() error {
+	dir, err := os.MkdirTemp("", "starter-kit-test-")
+	if err != nil {
+		return err
+	}
+
+	// config.App() reads the environment on its first call
+	env := map[string]string{
+		"DB_DRIVER":      "sqlite",
+		"DB_NAME":        filepath.Join(dir, "test.db"),
+		"DB_CONNECTIONS": "",
+		"DB_REPLICAS":    "",
+		"JWT_SECRET":     "test-secret",
+		"JWT_KEYS":       "",
+	}
+	for key, value := range env {
+		if err := os.Setenv(key, value); err != nil {
+			return err
+		}
+	}
+
+	db := config.App().DB
+	migrations, err := fs.Sub(asset.Migrations, "migrations/sqlite")
+	if err != nil {
+		return err
+	}
+	if _, err := migrate.New(db.DB, migrations).WithDialect(db.Dialect()).Up(context.Background(), 0); err != nil {
+		return err
+	}
+
+	queryFS, err := fs.Sub(asset.Queries, "queries")
+	if err != nil {
+		return err
+	}
+	queries, err := load.NewQueries(queryFS)
+	if err != nil {
+		return err
+	}
+	config.App().QUERY = queries
+
+	return auth.InitKeys()
+}
@@ -10,10 +10,22 @@ import (
 	"github.com/mstgnz/starter-kit/api/infra/config"
 	"github.com/mstgnz/starter-kit/api/infra/response"
 	"github.com/mstgnz/starter-kit/api/repository"
+	"github.com/mstgnz/starter-kit/api/service"
 )
 
+var (
+	sessionService = service.NewSessionService()
+	apiKeyService  = service.NewAPIKeyService()
+)
+
+// AuthMiddleware authenticates the request with either an "Authorization: Bearer <jwt>" or an "X-API-Key" header
 func AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key := r.Header.Get("X-API-Key"); key != "" {
+			apiKeyAuth(next, w, r, key)
+			return
+		}
+
 		token := r.Header.Get("Authorization")
 		if token == "" {
 			_ = response.WriteJSON(w, http.StatusUnauthorized, response.Response{Success: false, Message: "Invalid Token"})
@@ -21,15 +33,22 @@ func AuthMiddleware(next http.Handler) http.Handler {
 		}
 		token = strings.Replace(token, "Bearer ", "", 1)
 
-		userId, err := auth.GetUserIDByToken(token)
+		claims, err := auth.ParseToken(token)
 		if err != nil {
 			_ = response.WriteJSON(w, http.StatusUnauthorized, response.Response{Success: false, Message: err.Error()})
 			return
 		}
 
-		user_id, err := strconv.Atoi(userId)
-		if err != nil && user_id == 0 {
-			_ = response.WriteJSON(w, http.StatusUnauthorized, response.Response{Success: false, Message: err.Error()})
+		user_id, err := strconv.Atoi(claims.Subject)
+		if err != nil || user_id == 0 {
+			_ = response.WriteJSON(w, http.StatusUnauthorized, response.Response{Success: false, Message: "Invalid Token"})
+			return
+		}
+
+		// the session is checked on every request so a revoked session stops working immediately
+		session, err := sessionService.Validate(r.Context(), claims.Family, user_id)
+		if err != nil {
+			_ = response.WriteJSON(w, http.StatusUnauthorized, response.Response{Success: false, Message: "Token revoked"})
 			return
 		}
 
@@ -40,8 +59,34 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			_ = response.WriteJSON(w, http.StatusUnauthorized, response.Response{Success: false, Message: err.Error()})
 			return
 		}
+		if !user.Active {
+			_ = response.WriteJSON(w, http.StatusUnauthorized, response.Response{Success: false, Message: "User is not active"})
+			return
+		}
 
 		ctx := context.WithValue(r.Context(), config.CKey("user"), user)
+		ctx = context.WithValue(ctx, config.CKey("family"), claims.Family)
+		ctx = context.WithValue(ctx, config.CKey("session"), session)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// apiKeyAuth puts the owner of the api key and the key itself in the context
+func apiKeyAuth(next http.Handler, w http.ResponseWriter, r *http.Request, key string) {
+	apiKey, err := apiKeyService.Authenticate(r.Context(), key)
+	if err != nil {
+		_ = response.WriteJSON(w, http.StatusUnauthorized, response.Response{Success: false, Message: "Invalid API Key"})
+		return
+	}
+
+	userRepository := repository.NewUserRepository()
+	user, err := userRepository.GetWithId(r.Context(), apiKey.UserID)
+	if err != nil || !user.Active {
+		_ = response.WriteJSON(w, http.StatusUnauthorized, response.Response{Success: false, Message: "Invalid API Key"})
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), config.CKey("user"), user)
+	ctx = context.WithValue(ctx, config.CKey("apiKey"), apiKey)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
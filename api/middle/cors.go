@@ -79,6 +79,7 @@ func CORSMiddleware() func(http.Handler) http.Handler {
 		AllowedHeaders: []string{
 			"Accept",
 			"Authorization",
+			"X-API-Key",
 			"Content-Type",
 			"Timestamp",
 			"Hash",
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/mstgnz/starter-kit/api/infra/config"
+	"github.com/mstgnz/starter-kit/api/infra/conn"
 	"github.com/mstgnz/starter-kit/api/infra/response"
 )
 
@@ -40,6 +41,13 @@ func IPMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// DBSessionMiddleware starts a database session per request, reads after a write of the request go to the primary
+func DBSessionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(conn.WithSession(r.Context())))
+	})
+}
+
 // GetClientIP extracts the real client IP from various headers and sources
 func GetClientIP(r *http.Request) string {
 	// Check X-Forwarded-For header first (most common proxy header)
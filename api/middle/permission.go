@@ -0,0 +1,79 @@
+package middle
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/mstgnz/starter-kit/api/infra/config"
+	"github.com/mstgnz/starter-kit/api/infra/response"
+	"github.com/mstgnz/starter-kit/api/model"
+	"github.com/mstgnz/starter-kit/api/service"
+)
+
+var roleService = service.NewRoleService()
+
+// RequirePermission allows the request if the user has any of the permissions, it must run after AuthMiddleware.
+// Requests authenticated with an api key are also limited to the scopes of the key.
+//
+//	r.With(middle.RequirePermission("users.delete")).Delete("/users/{id}", ...)
+func RequirePermission(permissions ...string) func(http.Handler) http.Handler {
+	return authorize(func(ctx context.Context, user *model.User) (bool, error) {
+		allowed := permissions
+		if apiKey, ok := ctx.Value(config.CKey("apiKey")).(*model.APIKey); ok {
+			allowed = nil
+			for _, permission := range permissions {
+				if apiKeyService.HasScope(apiKey, permission) {
+					allowed = append(allowed, permission)
+				}
+			}
+			if len(allowed) == 0 {
+				return false, nil
+			}
+		}
+		return roleService.HasPermission(ctx, user, allowed...)
+	})
+}
+
+// RequireRole allows the request if the user has any of the roles, it must run after AuthMiddleware
+func RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return authorize(func(ctx context.Context, user *model.User) (bool, error) {
+		return roleService.HasRole(ctx, user, roles...)
+	})
+}
+
+// DenyAPIKey rejects requests authenticated with an api key, it must run after AuthMiddleware.
+// Self service routes such as the profile, password, two factor authentication, sessions and api keys of the user
+// need a login, scopes of a key do not reach them.
+func DenyAPIKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value(config.CKey("apiKey")).(*model.APIKey); ok {
+			_ = response.WriteJSON(w, http.StatusForbidden, response.Response{Code: http.StatusForbidden, Success: false, Message: "Forbidden"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func authorize(check func(ctx context.Context, user *model.User) (bool, error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, ok := r.Context().Value(config.CKey("user")).(*model.User)
+			if !ok || user == nil {
+				_ = response.WriteJSON(w, http.StatusUnauthorized, response.Response{Code: http.StatusUnauthorized, Success: false, Message: "Invalid Token"})
+				return
+			}
+
+			allowed, err := check(r.Context(), user)
+			if err != nil {
+				_ = response.WriteJSON(w, http.StatusInternalServerError, response.Response{Code: http.StatusInternalServerError, Success: false, Message: "Authorization failed"})
+				return
+			}
+			if !allowed {
+				_ = response.WriteJSON(w, http.StatusForbidden, response.Response{Code: http.StatusForbidden, Success: false, Message: "Forbidden"})
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
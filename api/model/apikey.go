@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+type APIKey struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  *time.Time `json:"created_at,omitempty"`
+}
+
+type APIKeyCreate struct {
+	Name      string     `json:"name" validate:"required,max=100"`
+	Scopes    []string   `json:"scopes" validate:"dive,required"`
+	ExpiresAt *time.Time `json:"expires_at" validate:"omitempty"`
+}
+
+type APIKeyRequest struct {
+	ID int `json:"-" param:"id" validate:"required"`
+}
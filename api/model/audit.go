@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// AuditLog is a security relevant event of a user, ActorID is the admin who caused it if it was not the user
+type AuditLog struct {
+	ID        int        `json:"id"`
+	UserID    int        `json:"user_id,omitempty"`
+	ActorID   int        `json:"actor_id,omitempty"`
+	Event     string     `json:"event"`
+	IP        string     `json:"ip,omitempty"`
+	Detail    string     `json:"detail,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+}
+
+// Lockout is the failed login state of a user, the user cannot log in until LockedUntil
+type Lockout struct {
+	UserID         int        `json:"-"`
+	FailedAttempts int        `json:"failed_attempts"`
+	LockedUntil    *time.Time `json:"locked_until,omitempty"`
+}
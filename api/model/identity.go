@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+// UserIdentity links a user to the account of the user at an OAuth provider, Subject is the id at the provider
+type UserIdentity struct {
+	ID          int        `json:"id"`
+	UserID      int        `json:"user_id"`
+	Provider    string     `json:"provider"`
+	Subject     string     `json:"-"`
+	Email       string     `json:"email"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+}
+
+// OAuthStart starts a login, browsers are redirected to the provider and clients that accept json get the url
+type OAuthStart struct {
+	Provider string `json:"-" param:"provider" validate:"required"`
+	Accept   string `json:"-" header:"Accept"`
+}
+
+// OAuthCallback is the query the provider redirects back with, error is set when the user denied the consent.
+// Cookie carries the login cookie set by the start of the login.
+type OAuthCallback struct {
+	Provider         string `json:"-" param:"provider" validate:"required"`
+	Cookie           string `json:"-" header:"Cookie"`
+	Code             string `json:"-" query:"code"`
+	State            string `json:"-" query:"state" validate:"required"`
+	Error            string `json:"-" query:"error"`
+	ErrorDescription string `json:"-" query:"error_description"`
+}
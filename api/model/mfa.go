@@ -0,0 +1,41 @@
+package model
+
+import "time"
+
+// TOTP is the authenticator app secret of a user, two factor authentication is on once it is confirmed
+type TOTP struct {
+	UserID      int        `json:"-"`
+	Secret      string     `json:"-"`
+	LastCounter int64      `json:"-"`
+	ConfirmedAt *time.Time `json:"confirmed_at,omitempty"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+}
+
+// MFAEnrollment is the secret to add to an authenticator app, URI is the otpauth:// uri for a QR code
+type MFAEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type MFACode struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// MFALogin is the second step of the login, code is a TOTP code or a recovery code
+type MFALogin struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
+
+// MFADisable turns two factor authentication off, code is a TOTP code or a recovery code
+type MFADisable struct {
+	Password string `json:"password" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
+
+// MFAChallenge is the login response of a user with two factor authentication,
+// the token is exchanged with a code at POST /login/mfa
+type MFAChallenge struct {
+	MFAToken  string    `json:"mfa_token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
@@ -0,0 +1,12 @@
+package model
+
+// Access is the list of roles and permissions of a user
+type Access struct {
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+}
+
+type RoleAssign struct {
+	UserID int    `json:"-" param:"id" validate:"required"`
+	Role   string `json:"role" param:"role" validate:"required"`
+}
@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// Session is a login of a user on a device, it lives as long as the refresh token family of the login
+type Session struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"-"`
+	Family     string     `json:"-"`
+	Device     string     `json:"device"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	CreatedAt  *time.Time `json:"created_at,omitempty"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+	Current    bool       `json:"current"`
+}
+
+type SessionRequest struct {
+	ID int `json:"-" param:"id" validate:"required"`
+}
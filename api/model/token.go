@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+type Token struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+type RefreshToken struct {
+	ID        int        `json:"id"`
+	UserID    int        `json:"user_id"`
+	Family    string     `json:"family"`
+	TokenHash string     `json:"-"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+}
+
+type Refresh struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
@@ -9,20 +9,22 @@ import (
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/mstgnz/starter-kit/api/infra/paginate"
 )
 
 type User struct {
-	ID        int        `json:"id"`
-	Fullname  string     `json:"fullname" validate:"required"`
-	Email     string     `json:"email" validate:"required,email"`
-	Password  string     `json:"-" validate:"required"`
-	Phone     string     `json:"phone" validate:"required,e164"`
-	Active    bool       `json:"active"`
-	IsAdmin   bool       `json:"is_admin"`
-	LastLogin *time.Time `json:"last_login,omitempty"`
-	CreatedAt *time.Time `json:"created_at,omitempty"`
-	UpdatedAt *time.Time `json:"updated_at,omitempty"`
-	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	ID              int        `json:"id" db:"id,pk,filter"`
+	Fullname        string     `json:"fullname" db:"fullname,sort,search" validate:"required"`
+	Email           string     `json:"email" db:"email,sort,filter,search" validate:"required,email"`
+	Password        string     `json:"-" db:"password" validate:"required"`
+	Phone           string     `json:"phone" db:"phone,filter,search" validate:"required,e164"`
+	Active          bool       `json:"active" db:"active,filter"`
+	IsAdmin         bool       `json:"is_admin" db:"is_admin,filter"`
+	LastLogin       *time.Time `json:"last_login,omitempty" db:"last_login,sort,filter"`
+	CreatedAt       *time.Time `json:"created_at,omitempty" db:"created_at,readonly,sort,filter"`
+	UpdatedAt       *time.Time `json:"updated_at,omitempty" db:"updated_at"`
+	DeletedAt       *time.Time `json:"deleted_at,omitempty" db:"deleted_at,filter"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty" db:"email_verified_at,filter"`
 }
 
 type Login struct {
@@ -38,9 +40,48 @@ type Register struct {
 }
 
 type PasswordUpdate struct {
-	ID         int    `json:"id" validate:"omitempty"` // This field is required if the administrator wants to update a user.
+	ID              int    `json:"id" validate:"omitempty"` // This field is required if the administrator wants to update a user.
+	CurrentPassword string `json:"current_password" validate:"required"`
+	Password        string `json:"password" validate:"required,min=6"`
+	RePassword      string `json:"re-password" validate:"required,min=6,eqfield=Password"`
+}
+
+// PasswordForgot is the body of POST /password/forgot
+type PasswordForgot struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// PasswordReset is the body of POST /password/reset, the token is the one mailed by POST /password/forgot
+type PasswordReset struct {
+	Token      string `json:"token" validate:"required"`
 	Password   string `json:"password" validate:"required,min=6"`
-	RePassword string `json:"re-password" validate:"required,min=6"`
+	RePassword string `json:"re-password" validate:"required,min=6,eqfield=Password"`
+}
+
+// EmailVerify is the query of the verification link
+type EmailVerify struct {
+	Token string `json:"-" query:"token" validate:"required"`
+}
+
+// ProfileUpdate is the body of PATCH /me, only the given fields are updated
+type ProfileUpdate struct {
+	Fullname *string `json:"fullname" validate:"omitempty,min=1"`
+	Phone    *string `json:"phone" validate:"omitempty,e164"`
+}
+
+// UserUpdate is the body of PATCH /users/{id}, only the given fields are updated
+type UserUpdate struct {
+	ID       int     `json:"-" param:"id" validate:"required"`
+	Fullname *string `json:"fullname" validate:"omitempty,min=1"`
+	Email    *string `json:"email" validate:"omitempty,email"`
+	Phone    *string `json:"phone" validate:"omitempty,e164"`
+	Active   *bool   `json:"active"`
+}
+
+// UserList is the query of GET /users, deactivated users are only listed with ?trashed=true
+type UserList struct {
+	paginate.Params
+	Trashed bool `json:"-" query:"trashed"`
 }
 
 type UserRequest struct {
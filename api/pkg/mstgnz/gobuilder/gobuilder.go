@@ -1,9 +1,12 @@
 package gobuilder
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -21,21 +24,56 @@ const (
 // Default time for query statute of limitations, you can change this value for each query as you need.
 var Timeout time.Duration = 30
 
+// operators are the comparison operators Where, OrWhere and Join accept
+var operators = map[string]bool{
+	"=": true, "!=": true, "<>": true, "<": true, "<=": true, ">": true, ">=": true,
+	"LIKE": true, "NOT LIKE": true, "ILIKE": true, "NOT ILIKE": true,
+	"IN": true, "NOT IN": true, "IS": true, "IS NOT": true,
+}
+
+// GoBuilder is an immutable query builder, every method returns a new builder and leaves the receiver as is:
+//
+//	users := gobuilder.NewGoBuilder(gobuilder.Postgres).Table("users")
+//	active := users.Select().Where("active", "=", true) // users is unchanged
+//
+// Tables and columns are quoted for the dialect, "u.email" becomes "u"."email" and "users u" becomes "users" "u",
+// so they cannot carry SQL. Expressions go through the Raw methods, e.g. SelectRaw("count(*)").
+// An operator that is not allowed is an error, Prepare and Sql return an empty query and Err returns the error.
+//
 // https://github.com/mstgnz/gobuilder
 type GoBuilder struct {
-	tableClause   string
-	selectClause  string
-	whereClause   string
-	groupByClause string
-	havingClause  string
-	orderByClause string
-	limitClause   string
-	unionClause   string
-	joinClauses   []string
-	paramsClause  []any
-	counterClause int
-	holderClause  SQLDialect
-	holderCode    string
+	tableClause     string
+	selectClause    string
+	conflictClause  string
+	whereClause     string
+	groupByClause   string
+	havingClause    string
+	orderByClause   string
+	limitClause     string
+	unionClause     string
+	returningClause string
+	joinClauses     []string
+	withClauses     []string
+	withParams      []any
+	withRecursive   bool
+	insertColumns   []string
+	conflictColumns []string
+	paramsClause    []any
+	counterClause   int
+	holderClause    SQLDialect
+	err             error
+}
+
+// Expr is a raw SQL expression with its own bind arguments, see Raw
+type Expr struct {
+	sql  string
+	args []any
+}
+
+// Raw returns an expression that is written into the query as is, each ? outside of quotes is bound to the next argument.
+// It can be used wherever a value is bound, e.g. Update(map[string]any{"hits": gobuilder.Raw("hits + ?", 1)})
+func Raw(sql string, args ...any) Expr {
+	return Expr{sql: sql, args: args}
 }
 
 // NewGoBuilder initializes a new instance of GoBuilder
@@ -45,36 +83,74 @@ func NewGoBuilder(holderClause SQLDialect) *GoBuilder {
 		counterClause: 1,
 		holderClause:  holderClause,
 	}
-	gb.holderCode = gb.getPlaceholderCode()
 	return gb
 }
 
 // Table specifies the table name for the query
 func (gb *GoBuilder) Table(table string) *GoBuilder {
-	gb.tableClause = table
+	gb = gb.clone()
+	gb.tableClause = gb.ident(table)
 	return gb
 }
 
 // Select defines the columns to be selected in the query
 func (gb *GoBuilder) Select(columns ...string) *GoBuilder {
+	gb = gb.clone()
 	if len(columns) == 0 {
 		columns = append(columns, "*")
 	}
-	gb.selectClause = fmt.Sprintf("SELECT %s FROM %s", strings.Join(columns, ", "), gb.tableClause)
+	gb.selectClause = fmt.Sprintf("SELECT %s FROM %s", strings.Join(gb.idents(columns), ", "), gb.tableClause)
 	return gb
 }
 
 // SelectDistinct defines the distinct columns to be selected
 func (gb *GoBuilder) SelectDistinct(columns ...string) *GoBuilder {
+	gb = gb.clone()
 	if len(columns) == 0 {
 		columns = append(columns, "*")
 	}
-	gb.selectClause = fmt.Sprintf("SELECT DISTINCT %s FROM %s", strings.Join(columns, ", "), gb.tableClause)
+	gb.selectClause = fmt.Sprintf("SELECT DISTINCT %s FROM %s", strings.Join(gb.idents(columns), ", "), gb.tableClause)
 	return gb
 }
 
+// SelectRaw defines a raw select expression, each ? in the expression is bound to the next argument
+//
+//	SelectRaw("id, price * ? AS total", rate)
+func (gb *GoBuilder) SelectRaw(expr string, args ...any) *GoBuilder {
+	gb = gb.clone()
+	gb.selectClause = fmt.Sprintf("SELECT %s FROM %s", gb.bindRaw(expr, args), gb.tableClause)
+	return gb
+}
+
+// With adds a common table expression, the CTE is written before the query:
+//
+//	active := gobuilder.NewGoBuilder(gobuilder.Postgres).Table("users").Select("id").Where("active", "=", true)
+//	gb.With("active_users", active).Table("active_users").Select()
+func (gb *GoBuilder) With(name string, sub *GoBuilder) *GoBuilder {
+	gb = gb.clone()
+	if sub.err != nil {
+		gb.err = sub.err
+		return gb
+	}
+	dialect := sub.holderClause
+	query, params := sub.Prepare()
+	offset := len(gb.withParams)
+	query = rebind(query, dialect, func(n int) string { return gb.placeholder(n + offset) })
+	gb.withClauses = append(gb.withClauses, fmt.Sprintf("%s AS (%s)", gb.ident(name), query))
+	gb.withParams = append(gb.withParams, params...)
+	return gb
+}
+
+// WithRecursive adds a recursive common table expression, the query of sub usually has a UNION
+func (gb *GoBuilder) WithRecursive(name string, sub *GoBuilder) *GoBuilder {
+	gb = gb.clone()
+	gb.withRecursive = true
+	return gb.With(name, sub)
+}
+
 // Create adds an INSERT INTO statement to the query with bind parameters
 func (gb *GoBuilder) Create(args map[string]any, returning ...string) *GoBuilder {
+	gb = gb.clone()
 	if len(args) != 0 {
 		keys := make([]string, 0, len(args))
 		for key := range args {
@@ -85,8 +161,8 @@ func (gb *GoBuilder) Create(args map[string]any, returning ...string) *GoBuilder
 		columns := make([]string, 0, len(keys))
 		values := make([]string, 0, len(keys))
 		for _, key := range keys {
-			columns = append(columns, key)
-			values = append(values, gb.addParam(args[key]))
+			columns = append(columns, gb.ident(key))
+			values = append(values, gb.value(args[key]))
 		}
 
 		gb.selectClause = fmt.Sprintf(
@@ -95,15 +171,78 @@ func (gb *GoBuilder) Create(args map[string]any, returning ...string) *GoBuilder
 			strings.Join(columns, ", "),
 			strings.Join(values, ", "),
 		)
+		gb.insertColumns = keys
 		if len(returning) > 0 {
-			gb.selectClause += fmt.Sprintf(" RETURNING %s", strings.Join(returning, ", "))
+			gb.returningClause = fmt.Sprintf("RETURNING %s", strings.Join(gb.idents(returning), ", "))
 		}
 	}
 	return gb
 }
 
+// OnConflict sets the conflict target of an upsert, follow it with DoNothing, DoUpdate or DoUpdateSet:
+//
+//	gb.Table("users").Create(values).OnConflict("email").DoUpdate("fullname", "phone")
+//
+// Postgres and SQLite use ON CONFLICT, MySQL uses ON DUPLICATE KEY UPDATE and ignores the target.
+func (gb *GoBuilder) OnConflict(columns ...string) *GoBuilder {
+	gb = gb.clone()
+	gb.conflictColumns = columns
+	return gb
+}
+
+// DoNothing skips the insert of a conflicting row
+func (gb *GoBuilder) DoNothing() *GoBuilder {
+	gb = gb.clone()
+	if gb.holderClause == MySQL {
+		gb.selectClause = strings.Replace(gb.selectClause, "INSERT INTO", "INSERT IGNORE INTO", 1)
+		return gb
+	}
+	gb.conflictClause = gb.conflictTarget() + " DO NOTHING"
+	return gb
+}
+
+// DoUpdate updates the columns of a conflicting row with the inserted values,
+// without columns every inserted column except the conflict target is updated
+func (gb *GoBuilder) DoUpdate(columns ...string) *GoBuilder {
+	gb = gb.clone()
+	if len(columns) == 0 {
+		for _, column := range gb.insertColumns {
+			if !slices.Contains(gb.conflictColumns, column) {
+				columns = append(columns, column)
+			}
+		}
+	}
+
+	sets := make([]string, len(columns))
+	for i, column := range gb.idents(columns) {
+		if gb.holderClause == MySQL {
+			sets[i] = fmt.Sprintf("%s = VALUES(%s)", column, column)
+		} else {
+			sets[i] = fmt.Sprintf("%s = EXCLUDED.%s", column, column)
+		}
+	}
+	return gb.doUpdate(sets)
+}
+
+// DoUpdateSet updates a conflicting row with the given values, e.g. DoUpdateSet(map[string]any{"hits": gobuilder.Raw("hits + 1")})
+func (gb *GoBuilder) DoUpdateSet(args map[string]any) *GoBuilder {
+	gb = gb.clone()
+	keys := make([]string, 0, len(args))
+	for key := range args {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	sets := make([]string, len(keys))
+	for i, key := range keys {
+		sets[i] = fmt.Sprintf("%s = %s", gb.ident(key), gb.value(args[key]))
+	}
+	return gb.doUpdate(sets)
+}
+
 // Update builds an UPDATE statement with bind parameters
 func (gb *GoBuilder) Update(args map[string]any) *GoBuilder {
+	gb = gb.clone()
 	if len(args) != 0 {
 		keys := make([]string, 0, len(args))
 		for key := range args {
@@ -113,7 +252,7 @@ func (gb *GoBuilder) Update(args map[string]any) *GoBuilder {
 
 		setClauses := make([]string, 0, len(keys))
 		for _, key := range keys {
-			setClauses = append(setClauses, fmt.Sprintf("%s = %s", key, gb.addParam(args[key])))
+			setClauses = append(setClauses, fmt.Sprintf("%s = %s", gb.ident(key), gb.value(args[key])))
 		}
 
 		gb.selectClause = fmt.Sprintf(
@@ -127,74 +266,149 @@ func (gb *GoBuilder) Update(args map[string]any) *GoBuilder {
 
 // Delete builds a DELETE statement
 func (gb *GoBuilder) Delete() *GoBuilder {
+	gb = gb.clone()
 	gb.selectClause = fmt.Sprintf("DELETE FROM %s", gb.tableClause)
 	return gb
 }
 
-// Where adds a WHERE clause with bind parameters
+// Where adds a WHERE clause with bind parameters, val may be a Raw expression or a sub query builder
 func (gb *GoBuilder) Where(key, opt string, val any) *GoBuilder {
-	clause := fmt.Sprintf("%s %s %s", key, opt, gb.addParam(val))
+	gb = gb.clone()
+	clause := fmt.Sprintf("%s %s %s", gb.ident(key), gb.operator(opt), gb.value(val))
 	gb.addClause("AND", clause)
 	return gb
 }
 
-// OrWhere adds an OR WHERE clause with bind parameters
+// OrWhere adds an OR WHERE clause with bind parameters, val may be a Raw expression or a sub query builder
 func (gb *GoBuilder) OrWhere(key, opt string, val any) *GoBuilder {
-	clause := fmt.Sprintf("%s %s %s", key, opt, gb.addParam(val))
+	gb = gb.clone()
+	clause := fmt.Sprintf("%s %s %s", gb.ident(key), gb.operator(opt), gb.value(val))
 	gb.addClause("OR", clause)
 	return gb
 }
 
+// WhereGroup adds the conditions of fn in parentheses:
+//
+//	gb.Where("a", "=", 1).WhereGroup(func(q *gobuilder.GoBuilder) *gobuilder.GoBuilder {
+//		return q.Where("b", "=", 2).OrWhere("c", "=", 3)
+//	}) // WHERE a = $1 AND (b = $2 OR c = $3)
+func (gb *GoBuilder) WhereGroup(fn func(q *GoBuilder) *GoBuilder) *GoBuilder {
+	gb = gb.clone()
+	return gb.group("AND", fn)
+}
+
+// OrWhereGroup adds the conditions of fn in parentheses with OR
+func (gb *GoBuilder) OrWhereGroup(fn func(q *GoBuilder) *GoBuilder) *GoBuilder {
+	gb = gb.clone()
+	return gb.group("OR", fn)
+}
+
+// WhereIn adds an IN clause with a sub query, e.g. WhereIn("id", sub.Table("user_roles").Select("user_id"))
+func (gb *GoBuilder) WhereIn(column string, sub *GoBuilder) *GoBuilder {
+	gb = gb.clone()
+	gb.addClause("AND", fmt.Sprintf("%s IN %s", gb.ident(column), gb.subquery(sub)))
+	return gb
+}
+
+// WhereNotIn adds a NOT IN clause with a sub query
+func (gb *GoBuilder) WhereNotIn(column string, sub *GoBuilder) *GoBuilder {
+	gb = gb.clone()
+	gb.addClause("AND", fmt.Sprintf("%s NOT IN %s", gb.ident(column), gb.subquery(sub)))
+	return gb
+}
+
+// WhereExists adds an EXISTS clause with a sub query
+func (gb *GoBuilder) WhereExists(sub *GoBuilder) *GoBuilder {
+	gb = gb.clone()
+	gb.addClause("AND", "EXISTS "+gb.subquery(sub))
+	return gb
+}
+
+// WhereNotExists adds a NOT EXISTS clause with a sub query
+func (gb *GoBuilder) WhereNotExists(sub *GoBuilder) *GoBuilder {
+	gb = gb.clone()
+	gb.addClause("AND", "NOT EXISTS "+gb.subquery(sub))
+	return gb
+}
+
+// WhereRaw adds a raw WHERE condition, each ? in the expression is bound to the next argument
+//
+//	WhereRaw("(created_at, id) < (?, ?)", createdAt, id)
+func (gb *GoBuilder) WhereRaw(expr string, args ...any) *GoBuilder {
+	gb = gb.clone()
+	gb.addClause("AND", gb.bindRaw(expr, args))
+	return gb
+}
+
+// OrWhereRaw adds a raw OR WHERE condition, each ? in the expression is bound to the next argument
+func (gb *GoBuilder) OrWhereRaw(expr string, args ...any) *GoBuilder {
+	gb = gb.clone()
+	gb.addClause("OR", gb.bindRaw(expr, args))
+	return gb
+}
+
 // In adds an IN clause with bind parameters
 func (gb *GoBuilder) In(column string, args ...any) *GoBuilder {
+	gb = gb.clone()
 	return gb.addInClause("AND", column, args...)
 }
 
 // OrIn adds an OR IN clause with bind parameters
 func (gb *GoBuilder) OrIn(column string, args ...any) *GoBuilder {
+	gb = gb.clone()
 	return gb.addInClause("OR", column, args...)
 }
 
 // Between adds a BETWEEN clause with bind parameters
 func (gb *GoBuilder) Between(column string, args ...any) *GoBuilder {
+	gb = gb.clone()
 	return gb.between("AND", column, args...)
 }
 
 // OrBetween adds an OR BETWEEN clause with bind parameters
 func (gb *GoBuilder) OrBetween(column string, args ...any) *GoBuilder {
+	gb = gb.clone()
 	return gb.between("OR", column, args...)
 }
 
 // IsNull adds an IS NULL clause
 func (gb *GoBuilder) IsNull(column string) *GoBuilder {
-	clause := fmt.Sprintf("%s IS NULL", column)
+	gb = gb.clone()
+	clause := fmt.Sprintf("%s IS NULL", gb.ident(column))
 	gb.addClause("AND", clause)
 	return gb
 }
 
 // OrIsNull adds an OR IS NULL clause
 func (gb *GoBuilder) OrIsNull(column string) *GoBuilder {
-	clause := fmt.Sprintf("%s IS NULL", column)
+	gb = gb.clone()
+	clause := fmt.Sprintf("%s IS NULL", gb.ident(column))
 	gb.addClause("OR", clause)
 	return gb
 }
 
 // IsNotNull adds an IS NOT NULL clause
 func (gb *GoBuilder) IsNotNull(column string) *GoBuilder {
-	clause := fmt.Sprintf("%s IS NOT NULL", column)
+	gb = gb.clone()
+	clause := fmt.Sprintf("%s IS NOT NULL", gb.ident(column))
 	gb.addClause("AND", clause)
 	return gb
 }
 
 // OrIsNotNull adds an OR IS NOT NULL clause
 func (gb *GoBuilder) OrIsNotNull(column string) *GoBuilder {
-	clause := fmt.Sprintf("%s IS NOT NULL", column)
+	gb = gb.clone()
+	clause := fmt.Sprintf("%s IS NOT NULL", gb.ident(column))
 	gb.addClause("OR", clause)
 	return gb
 }
 
-// Having adds a HAVING clause
+// Having adds a HAVING clause, each ? in the condition is bound to the next argument
+//
+//	Having("count(*) > ?", 10)
 func (gb *GoBuilder) Having(condition string, args ...any) *GoBuilder {
+	gb = gb.clone()
+	condition = gb.bindRaw(condition, args)
 	if gb.havingClause != "" {
 		gb.havingClause = fmt.Sprintf("%s OR %s", gb.havingClause, condition)
 	} else {
@@ -205,51 +419,85 @@ func (gb *GoBuilder) Having(condition string, args ...any) *GoBuilder {
 
 // Join adds a JOIN clause
 func (gb *GoBuilder) Join(table, first, operator, last string) *GoBuilder {
-	join := fmt.Sprintf("INNER JOIN %s ON %s %s %s", table, first, operator, last)
+	gb = gb.clone()
+	join := fmt.Sprintf("INNER JOIN %s ON %s %s %s", gb.ident(table), gb.ident(first), gb.operator(operator), gb.ident(last))
 	gb.joinClauses = append(gb.joinClauses, join)
 	return gb
 }
 
+// JoinRaw adds a raw join, each ? in the join is bound to the next argument
+//
+//	JoinRaw("LEFT JOIN user_roles ur ON ur.user_id = users.id AND ur.role_id = ?", roleID)
+func (gb *GoBuilder) JoinRaw(join string, args ...any) *GoBuilder {
+	gb = gb.clone()
+	gb.joinClauses = append(gb.joinClauses, gb.bindRaw(join, args))
+	return gb
+}
+
 // LeftJoin adds a LEFT JOIN clause
 func (gb *GoBuilder) LeftJoin(table, first, operator, last string) *GoBuilder {
-	join := fmt.Sprintf("LEFT JOIN %s ON %s %s %s", table, first, operator, last)
+	gb = gb.clone()
+	join := fmt.Sprintf("LEFT JOIN %s ON %s %s %s", gb.ident(table), gb.ident(first), gb.operator(operator), gb.ident(last))
 	gb.joinClauses = append(gb.joinClauses, join)
 	return gb
 }
 
 // RightJoin adds a RIGHT JOIN clause
 func (gb *GoBuilder) RightJoin(table, first, operator, last string) *GoBuilder {
-	join := fmt.Sprintf("RIGHT JOIN %s ON %s %s %s", table, first, operator, last)
+	gb = gb.clone()
+	join := fmt.Sprintf("RIGHT JOIN %s ON %s %s %s", gb.ident(table), gb.ident(first), gb.operator(operator), gb.ident(last))
 	gb.joinClauses = append(gb.joinClauses, join)
 	return gb
 }
 
-// Limit adds a LIMIT clause
+// Limit adds a LIMIT clause, SQL Server and Oracle use OFFSET FETCH which needs an ORDER BY
 func (gb *GoBuilder) Limit(offset, limit int) *GoBuilder {
-	gb.limitClause = fmt.Sprintf("OFFSET %d LIMIT %d", offset, limit)
+	gb = gb.clone()
+	switch gb.holderClause {
+	case SQLServer, Oracle:
+		gb.limitClause = fmt.Sprintf("OFFSET %d ROWS FETCH NEXT %d ROWS ONLY", offset, limit)
+	default:
+		gb.limitClause = fmt.Sprintf("LIMIT %d OFFSET %d", limit, offset)
+	}
 	return gb
 }
 
 // GroupBy adds a GROUP BY clause
 func (gb *GoBuilder) GroupBy(columns ...string) *GoBuilder {
-	gb.groupByClause = fmt.Sprintf("GROUP BY %v", strings.Join(columns, ", "))
+	gb = gb.clone()
+	gb.groupByClause = fmt.Sprintf("GROUP BY %v", strings.Join(gb.idents(columns), ", "))
 	return gb
 }
 
-// OrderBy adds an ORDER BY ASC clause
+// OrderBy adds an ORDER BY ASC clause, calling it again appends the columns
 func (gb *GoBuilder) OrderBy(columns ...string) *GoBuilder {
-	gb.orderByClause = fmt.Sprintf("ORDER BY %v ASC", strings.Join(columns, ", "))
-	return gb
+	gb = gb.clone()
+	return gb.addOrder("ASC", columns)
 }
 
-// OrderByDesc adds an ORDER BY DESC clause
+// OrderByDesc adds an ORDER BY DESC clause, calling it again appends the columns
 func (gb *GoBuilder) OrderByDesc(columns ...string) *GoBuilder {
-	gb.orderByClause = fmt.Sprintf("ORDER BY %v DESC", strings.Join(columns, ", "))
+	gb = gb.clone()
+	return gb.addOrder("DESC", columns)
+}
+
+// OrderByRaw adds a raw ORDER BY expression, each ? in the expression is bound to the next argument
+//
+//	OrderByRaw("lower(fullname) DESC")
+func (gb *GoBuilder) OrderByRaw(expr string, args ...any) *GoBuilder {
+	gb = gb.clone()
+	expr = gb.bindRaw(expr, args)
+	if gb.orderByClause != "" {
+		gb.orderByClause = fmt.Sprintf("%s, %s", gb.orderByClause, expr)
+	} else {
+		gb.orderByClause = fmt.Sprintf("ORDER BY %s", expr)
+	}
 	return gb
 }
 
 // Union adds a UNION clause
 func (gb *GoBuilder) Union(sql string) *GoBuilder {
+	gb = gb.clone()
 	if gb.unionClause == "" {
 		gb.unionClause = fmt.Sprintf("UNION %v", sql)
 	} else {
@@ -258,35 +506,86 @@ func (gb *GoBuilder) Union(sql string) *GoBuilder {
 	return gb
 }
 
-// Sql returns the final SQL query
+// Sql returns the final SQL query, it is empty when the builder has an error
 func (gb *GoBuilder) Sql() string {
-	clauses := []string{
-		gb.selectClause,
-		strings.Join(gb.joinClauses, " "),
-		gb.whereClause,
-		gb.groupByClause,
-		gb.havingClause,
-		gb.orderByClause,
-		gb.limitClause,
-		gb.unionClause,
+	if gb.err != nil {
+		return ""
 	}
-	query := strings.Join(clauses, " ")
-	re := regexp.MustCompile(`\s+`)
-	query = strings.TrimSpace(re.ReplaceAllString(query, " "))
-
-	params := gb.paramsClause
-	for i, param := range params {
-		placeholder := fmt.Sprintf("%s%d", gb.holderCode, i+1)
-		query = strings.Replace(query, placeholder, gb.cleanValue(param), 1)
-	}
-	gb.reset()
+	query, params := gb.build()
+	// placeholders are replaced in one pass so a placeholder in a value is kept
+	query = rebind(query, gb.holderClause, func(n int) string {
+		if n < 1 || n > len(params) {
+			return gb.placeholder(n)
+		}
+		return gb.cleanValue(params[n-1])
+	})
 	return query
 }
 
-// Prepare returns the final SQL query and the associated bind parameters
+// Prepare returns the final SQL query and the associated bind parameters, the query is empty when the builder has an error
 func (gb *GoBuilder) Prepare() (string, []any) {
+	if gb.err != nil {
+		return "", nil
+	}
+	return gb.build()
+}
+
+// Err returns the first error of the builder, an operator that is not allowed or an invalid alias
+func (gb *GoBuilder) Err() error {
+	return gb.err
+}
+
+// Dialect returns the SQL dialect of the builder
+func (gb *GoBuilder) Dialect() SQLDialect {
+	return gb.holderClause
+}
+
+// ValidOperator reports whether Where, OrWhere and Join accept the comparison operator
+func ValidOperator(operator string) bool {
+	return operators[strings.ToUpper(strings.Join(strings.Fields(operator), " "))]
+}
+
+// Quote quotes a table or column name for the dialect, each part of a qualified name is quoted and * is kept:
+// "u.email" is "u"."email" for Postgres, `u`.`email` for MySQL and [u].[email] for SQL Server
+func Quote(dialect SQLDialect, name string) string {
+	left, right := `"`, `"`
+	switch dialect {
+	case MySQL:
+		left, right = "`", "`"
+	case SQLServer:
+		left, right = "[", "]"
+	}
+
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		if part != "*" {
+			parts[i] = left + strings.ReplaceAll(part, right, right+right) + right
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// Placeholder returns the nth bind parameter of the dialect, e.g. $1 for Postgres and ? for MySQL
+func Placeholder(dialect SQLDialect, n int) string {
+	switch dialect {
+	case Postgres:
+		return fmt.Sprintf("$%d", n)
+	case SQLServer:
+		return fmt.Sprintf("@p%d", n)
+	case Oracle:
+		return fmt.Sprintf(":%d", n)
+	case SQLite:
+		return fmt.Sprintf("?%d", n)
+	default: // mysql has no numbered parameters
+		return "?"
+	}
+}
+
+// Private method to join the clauses, the CTEs are written first so the placeholders of the query follow theirs
+func (gb *GoBuilder) build() (string, []any) {
 	clauses := []string{
 		gb.selectClause,
+		gb.conflictClause,
 		strings.Join(gb.joinClauses, " "),
 		gb.whereClause,
 		gb.groupByClause,
@@ -294,28 +593,147 @@ func (gb *GoBuilder) Prepare() (string, []any) {
 		gb.orderByClause,
 		gb.limitClause,
 		gb.unionClause,
+		gb.returningClause,
 	}
 	query := strings.Join(clauses, " ")
 	re := regexp.MustCompile(`\s+`)
 	query = strings.TrimSpace(re.ReplaceAllString(query, " "))
 	params := gb.paramsClause
-	gb.reset()
+
+	if len(gb.withClauses) > 0 {
+		offset := len(gb.withParams)
+		query = rebind(query, gb.holderClause, func(n int) string { return gb.placeholder(n + offset) })
+		with := "WITH "
+		if gb.withRecursive {
+			with = "WITH RECURSIVE "
+		}
+		query = with + strings.Join(gb.withClauses, ", ") + " " + query
+		params = append(append([]any{}, gb.withParams...), params...)
+	}
 	return query, params
 }
 
-// Private method to RESET builder
-func (gb *GoBuilder) reset() {
-	*gb = *NewGoBuilder(Postgres)
+// Private method to copy the builder. Every method changes a copy, so a builder can be shared between
+// goroutines and a base query can be extended many times. The slices are clipped, an append copies them.
+func (gb *GoBuilder) clone() *GoBuilder {
+	c := *gb
+	c.joinClauses = slices.Clip(gb.joinClauses)
+	c.withClauses = slices.Clip(gb.withClauses)
+	c.withParams = slices.Clip(gb.withParams)
+	c.insertColumns = slices.Clip(gb.insertColumns)
+	c.conflictColumns = slices.Clip(gb.conflictColumns)
+	c.paramsClause = slices.Clip(gb.paramsClause)
+	return &c
 }
 
 // Private method to add parameters
 func (gb *GoBuilder) addParam(value any) string {
 	gb.paramsClause = append(gb.paramsClause, value)
-	placeholder := fmt.Sprintf("%s%d", gb.holderCode, gb.counterClause)
+	placeholder := gb.placeholder(gb.counterClause)
 	gb.counterClause++
 	return placeholder
 }
 
+func (gb *GoBuilder) placeholder(n int) string {
+	return Placeholder(gb.holderClause, n)
+}
+
+// Private method to quote a table or column with an optional alias, "users u" and "users AS u"
+func (gb *GoBuilder) ident(name string) string {
+	fields := strings.Fields(name)
+	switch {
+	case len(fields) == 1:
+		return Quote(gb.holderClause, fields[0])
+	case len(fields) == 2:
+		return Quote(gb.holderClause, fields[0]) + " " + Quote(gb.holderClause, fields[1])
+	case len(fields) == 3 && strings.EqualFold(fields[1], "AS"):
+		return Quote(gb.holderClause, fields[0]) + " AS " + Quote(gb.holderClause, fields[2])
+	}
+	if gb.err == nil {
+		gb.err = fmt.Errorf("gobuilder: invalid name %q", name)
+	}
+	return ""
+}
+
+// Private method to quote every column
+func (gb *GoBuilder) idents(columns []string) []string {
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = gb.ident(column)
+	}
+	return quoted
+}
+
+// Private method to check a comparison operator against the allowed operators
+func (gb *GoBuilder) operator(operator string) string {
+	if !ValidOperator(operator) {
+		if gb.err == nil {
+			gb.err = errors.New("gobuilder: operator not allowed: " + operator)
+		}
+		return ""
+	}
+	return strings.ToUpper(strings.Join(strings.Fields(operator), " "))
+}
+
+// Private method to bind a value: a Raw expression is written with its arguments, a builder as a sub query
+func (gb *GoBuilder) value(val any) string {
+	switch v := val.(type) {
+	case Expr:
+		return gb.bindRaw(v.sql, v.args)
+	case *GoBuilder:
+		return gb.subquery(v)
+	default:
+		return gb.addParam(val)
+	}
+}
+
+// Private method to write the query of sub in parentheses, its placeholders are numbered after the current ones
+func (gb *GoBuilder) subquery(sub *GoBuilder) string {
+	if sub.err != nil && gb.err == nil {
+		gb.err = sub.err
+	}
+	dialect := sub.holderClause
+	query, params := sub.Prepare()
+	offset := gb.counterClause - 1
+	query = rebind(query, dialect, func(n int) string { return gb.placeholder(n + offset) })
+	gb.paramsClause = append(gb.paramsClause, params...)
+	gb.counterClause += len(params)
+	return "(" + query + ")"
+}
+
+// Private method to add the conditions of fn as one parenthesized condition
+func (gb *GoBuilder) group(OP string, fn func(q *GoBuilder) *GoBuilder) *GoBuilder {
+	q := NewGoBuilder(gb.holderClause)
+	q.counterClause = gb.counterClause
+	if q = fn(q); q != nil && q.err != nil && gb.err == nil {
+		gb.err = q.err
+	}
+	if q != nil && q.whereClause != "" {
+		gb.paramsClause = append(gb.paramsClause, q.paramsClause...)
+		gb.counterClause = q.counterClause
+		gb.addClause(OP, "("+strings.TrimPrefix(q.whereClause, "WHERE ")+")")
+	}
+	return gb
+}
+
+// Private method to set the DO UPDATE part of an upsert
+func (gb *GoBuilder) doUpdate(sets []string) *GoBuilder {
+	if gb.holderClause == MySQL {
+		gb.conflictClause = "ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
+	} else {
+		gb.conflictClause = gb.conflictTarget() + " DO UPDATE SET " + strings.Join(sets, ", ")
+	}
+	return gb
+}
+
+// Private method to write ON CONFLICT with the conflict columns
+func (gb *GoBuilder) conflictTarget() string {
+	if len(gb.conflictColumns) == 0 {
+		return "ON CONFLICT"
+	}
+	return fmt.Sprintf("ON CONFLICT (%s)", strings.Join(gb.idents(gb.conflictColumns), ", "))
+}
+
 // Private method to add clauses with logical operators
 func (gb *GoBuilder) addClause(OP, clause string) {
 	if gb.whereClause != "" {
@@ -325,14 +743,50 @@ func (gb *GoBuilder) addClause(OP, clause string) {
 	}
 }
 
+// Private method to add ORDER BY columns with a direction
+func (gb *GoBuilder) addOrder(direction string, columns []string) *GoBuilder {
+	order := make([]string, len(columns))
+	for i, column := range columns {
+		order[i] = fmt.Sprintf("%s %s", gb.ident(column), direction)
+	}
+	if gb.orderByClause != "" {
+		gb.orderByClause = fmt.Sprintf("%s, %s", gb.orderByClause, strings.Join(order, ", "))
+	} else {
+		gb.orderByClause = fmt.Sprintf("ORDER BY %s", strings.Join(order, ", "))
+	}
+	return gb
+}
+
+// Private method to replace ? marks of a raw expression with bind parameters, a ? in a quoted string or name is kept
+func (gb *GoBuilder) bindRaw(expr string, args []any) string {
+	var sb strings.Builder
+	i := 0
+	var quote rune
+	for _, r := range expr {
+		switch {
+		case quote == 0 && (r == '\'' || r == '"'):
+			quote = r
+		case r == quote:
+			quote = 0
+		}
+		if r == '?' && quote == 0 && i < len(args) {
+			sb.WriteString(gb.value(args[i]))
+			i++
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
 // Private method to add IN clauses with values directly
 func (gb *GoBuilder) addInClause(OP, column string, args ...any) *GoBuilder {
 	if len(args) > 0 {
 		values := make([]string, len(args))
 		for i, arg := range args {
-			values[i] = gb.addParam(arg)
+			values[i] = gb.value(arg)
 		}
-		clause := fmt.Sprintf("%s IN (%s)", column, strings.Join(values, ", "))
+		clause := fmt.Sprintf("%s IN (%s)", gb.ident(column), strings.Join(values, ", "))
 		gb.addClause(OP, clause)
 	}
 	return gb
@@ -341,7 +795,7 @@ func (gb *GoBuilder) addInClause(OP, column string, args ...any) *GoBuilder {
 // Private method to add BETWEEN clauses with values directly
 func (gb *GoBuilder) between(OP, column string, args ...any) *GoBuilder {
 	if len(args) == 2 {
-		clause := fmt.Sprintf("%s BETWEEN %s AND %s", column, gb.addParam(args[0]), gb.addParam(args[1]))
+		clause := fmt.Sprintf("%s BETWEEN %s AND %s", gb.ident(column), gb.value(args[0]), gb.value(args[1]))
 		gb.addClause(OP, clause)
 	}
 	return gb
@@ -358,17 +812,40 @@ func (gb *GoBuilder) cleanValue(value any) string {
 	}
 }
 
-func (gb *GoBuilder) getPlaceholderCode() string {
-	var code string
-	switch gb.holderClause {
-	case Postgres:
-		code = "$"
-	case SQLServer:
-		code = "@"
-	case Oracle:
-		code = ":"
-	default: // mysql and sqlite
-		code = "?"
+// rebind replaces the placeholders of the dialect outside of quoted strings with the result of fn,
+// n is the number of the placeholder or its position for MySQL
+func rebind(query string, dialect SQLDialect, fn func(n int) string) string {
+	prefix := strings.TrimSuffix(Placeholder(dialect, 0), "0")
+	numbered := prefix != "?" || dialect == SQLite
+
+	var sb strings.Builder
+	position := 0
+	quoted := false
+	for i := 0; i < len(query); i++ {
+		if query[i] == '\'' {
+			quoted = !quoted
+		}
+		if quoted || !strings.HasPrefix(query[i:], prefix) {
+			sb.WriteByte(query[i])
+			continue
+		}
+
+		end := i + len(prefix)
+		for end < len(query) && query[end] >= '0' && query[end] <= '9' {
+			end++
+		}
+		switch {
+		case !numbered:
+			position++
+			sb.WriteString(fn(position))
+			i = end - 1
+		case end > i+len(prefix):
+			n, _ := strconv.Atoi(query[i+len(prefix) : end])
+			sb.WriteString(fn(n))
+			i = end - 1
+		default:
+			sb.WriteByte(query[i])
+		}
 	}
-	return code
+	return sb.String()
 }
@@ -0,0 +1,362 @@
+package gobuilder
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+const baseJoins = `SELECT "u"."id", "u"."email" FROM "users" "u" INNER JOIN "user_roles" "ur" ON "ur"."user_id" = "u"."id" ` +
+	`INNER JOIN "roles" "r" ON "r"."id" = "ur"."role_id" INNER JOIN "teams" "t" ON "t"."id" = "u"."team_id" `
+
+const baseQuery = baseJoins + `WHERE "u"."active" = $1 AND "u"."id" > $2 AND "t"."name" != $3`
+
+var baseArgs = []any{true, 0, "guests"}
+
+// newBase returns a builder with three joins and three arguments, their slices have room for one more,
+// so an append that is not copied shows in the base
+func newBase() *GoBuilder {
+	return NewGoBuilder(Postgres).Table("users u").Select("u.id", "u.email").
+		Join("user_roles ur", "ur.user_id", "=", "u.id").
+		Join("roles r", "r.id", "=", "ur.role_id").
+		Join("teams t", "t.id", "=", "u.team_id").
+		Where("u.active", "=", true).Where("u.id", ">", 0).Where("t.name", "!=", "guests")
+}
+
+// TestConcurrentBuild extends one base builder from many goroutines, run it with -race
+func TestConcurrentBuild(t *testing.T) {
+	base := newBase()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 64; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			email := fmt.Sprintf("%%user%d%%", i)
+			query, args := base.Where("ur.role_id", "=", i).
+				Join("permissions p", "p.role_id", "=", "r.id").
+				WhereGroup(func(q *GoBuilder) *GoBuilder {
+					return q.Where("u.email", "LIKE", email).OrIsNull("u.email")
+				}).
+				OrderBy("u.email").
+				Limit(i, 10).
+				Prepare()
+
+			want := baseJoins + `INNER JOIN "permissions" "p" ON "p"."role_id" = "r"."id" ` +
+				`WHERE "u"."active" = $1 AND "u"."id" > $2 AND "t"."name" != $3 AND "ur"."role_id" = $4 ` +
+				`AND ("u"."email" LIKE $5 OR "u"."email" IS NULL) ORDER BY "u"."email" ASC ` + fmt.Sprintf("LIMIT 10 OFFSET %d", i)
+			if query != want {
+				t.Errorf("goroutine %d query\n got: %s\nwant: %s", i, query, want)
+			}
+			if wantArgs := []any{true, 0, "guests", i, email}; !reflect.DeepEqual(args, wantArgs) {
+				t.Errorf("goroutine %d args %v, want %v", i, args, wantArgs)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assertBase(t, base)
+}
+
+// TestDerivedLeavesBase checks that no method changes the builder it is called on
+func TestDerivedLeavesBase(t *testing.T) {
+	derive := map[string]func(gb *GoBuilder) *GoBuilder{
+		"Where":       func(gb *GoBuilder) *GoBuilder { return gb.Where("u.id", "=", 1) },
+		"OrWhere":     func(gb *GoBuilder) *GoBuilder { return gb.OrWhere("u.id", "=", 1) },
+		"WhereRaw":    func(gb *GoBuilder) *GoBuilder { return gb.WhereRaw("u.id > ?", 1) },
+		"In":          func(gb *GoBuilder) *GoBuilder { return gb.In("u.id", 1, 2, 3) },
+		"IsNull":      func(gb *GoBuilder) *GoBuilder { return gb.IsNull("u.deleted_at") },
+		"Join":        func(gb *GoBuilder) *GoBuilder { return gb.Join("permissions p", "p.role_id", "=", "r.id") },
+		"LeftJoin":    func(gb *GoBuilder) *GoBuilder { return gb.LeftJoin("permissions p", "p.role_id", "=", "r.id") },
+		"JoinRaw":     func(gb *GoBuilder) *GoBuilder { return gb.JoinRaw("JOIN permissions p ON p.id = ?", 1) },
+		"OrderBy":     func(gb *GoBuilder) *GoBuilder { return gb.OrderBy("u.email") },
+		"OrderByDesc": func(gb *GoBuilder) *GoBuilder { return gb.OrderByDesc("u.id") },
+		"OrderByRaw":  func(gb *GoBuilder) *GoBuilder { return gb.OrderByRaw("u.email IS NULL") },
+		"GroupBy":     func(gb *GoBuilder) *GoBuilder { return gb.GroupBy("u.id") },
+		"Limit":       func(gb *GoBuilder) *GoBuilder { return gb.Limit(0, 10) },
+		"Select":      func(gb *GoBuilder) *GoBuilder { return gb.Select("u.fullname") },
+		"WhereGroup": func(gb *GoBuilder) *GoBuilder {
+			return gb.WhereGroup(func(q *GoBuilder) *GoBuilder { return q.Where("u.id", "=", 1).OrWhere("u.id", "=", 2) })
+		},
+	}
+
+	for name, fn := range derive {
+		t.Run(name, func(t *testing.T) {
+			base := newBase()
+			derived := fn(base)
+			if derived == base {
+				t.Fatal("the derived builder is the base builder")
+			}
+			// a second call on the derived builder must not reach the base either
+			fn(derived).OrderBy("u.id")
+			assertBase(t, base)
+		})
+	}
+}
+
+// TestSiblingsDoNotShare derives two builders from one base, the append of one must not show in the other
+func TestSiblingsDoNotShare(t *testing.T) {
+	base := newBase()
+	first := base.Where("r.name", "=", "admin").Join("permissions p", "p.role_id", "=", "r.id")
+	second := base.Where("r.name", "=", "editor").LeftJoin("audit_logs a", "a.user_id", "=", "u.id")
+
+	query, args := first.Prepare()
+	want := baseJoins + `INNER JOIN "permissions" "p" ON "p"."role_id" = "r"."id" ` +
+		`WHERE "u"."active" = $1 AND "u"."id" > $2 AND "t"."name" != $3 AND "r"."name" = $4`
+	if query != want || !reflect.DeepEqual(args, append(baseArgs[:3:3], "admin")) {
+		t.Fatalf("first\n got: %s %v\nwant: %s", query, args, want)
+	}
+
+	query, args = second.Prepare()
+	want = baseJoins + `LEFT JOIN "audit_logs" "a" ON "a"."user_id" = "u"."id" ` +
+		`WHERE "u"."active" = $1 AND "u"."id" > $2 AND "t"."name" != $3 AND "r"."name" = $4`
+	if query != want || !reflect.DeepEqual(args, append(baseArgs[:3:3], "editor")) {
+		t.Fatalf("second\n got: %s %v\nwant: %s", query, args, want)
+	}
+}
+
+func assertBase(t *testing.T, base *GoBuilder) {
+	t.Helper()
+	query, args := base.Prepare()
+	if query != baseQuery {
+		t.Fatalf("base query changed\n got: %s\nwant: %s", query, baseQuery)
+	}
+	if !reflect.DeepEqual(args, baseArgs) {
+		t.Fatalf("base args changed: %v", args)
+	}
+}
+
+// TestBuild checks the query and the arguments of each feature, sub queries and CTEs are numbered after
+// the placeholders before them
+func TestBuild(t *testing.T) {
+	pg := NewGoBuilder(Postgres)
+	tests := []struct {
+		name    string
+		builder *GoBuilder
+		query   string
+		args    []any
+	}{
+		{
+			name: "WhereGroup",
+			builder: pg.Table("users").Select().Where("a", "=", 1).WhereGroup(func(q *GoBuilder) *GoBuilder {
+				return q.Where("b", "=", 2).OrWhere("c", "=", 3)
+			}).Where("d", "=", 4),
+			query: `SELECT * FROM "users" WHERE "a" = $1 AND ("b" = $2 OR "c" = $3) AND "d" = $4`,
+			args:  []any{1, 2, 3, 4},
+		},
+		{
+			name: "OrWhereGroup",
+			builder: pg.Table("users").Select().Where("a", "=", 1).OrWhereGroup(func(q *GoBuilder) *GoBuilder {
+				return q.Where("b", "=", 2).IsNull("c")
+			}),
+			query: `SELECT * FROM "users" WHERE "a" = $1 OR ("b" = $2 AND "c" IS NULL)`,
+			args:  []any{1, 2},
+		},
+		{
+			name: "empty WhereGroup",
+			builder: pg.Table("users").Select().Where("a", "=", 1).WhereGroup(func(q *GoBuilder) *GoBuilder {
+				return q
+			}),
+			query: `SELECT * FROM "users" WHERE "a" = $1`,
+			args:  []any{1},
+		},
+		{
+			name: "WhereIn",
+			builder: pg.Table("users").Select("id").Where("active", "=", true).
+				WhereIn("id", pg.Table("user_roles").Select("user_id").Where("role_id", "=", 5).Where("team_id", "=", 6)).
+				Where("email", "LIKE", "%@test"),
+			query: `SELECT "id" FROM "users" WHERE "active" = $1 AND "id" IN (SELECT "user_id" FROM "user_roles" WHERE "role_id" = $2 AND "team_id" = $3) AND "email" LIKE $4`,
+			args:  []any{true, 5, 6, "%@test"},
+		},
+		{
+			name: "WhereNotIn SQLite",
+			builder: NewGoBuilder(SQLite).Table("users").Select("id").Where("active", "=", true).
+				WhereNotIn("id", NewGoBuilder(SQLite).Table("bans").Select("user_id").Where("until", ">", "now")),
+			query: `SELECT "id" FROM "users" WHERE "active" = ?1 AND "id" NOT IN (SELECT "user_id" FROM "bans" WHERE "until" > ?2)`,
+			args:  []any{true, "now"},
+		},
+		{
+			name: "WhereExists",
+			builder: pg.Table("users u").Select("u.id").Where("u.active", "=", true).
+				WhereExists(pg.Table("sessions s").Select("s.id").WhereRaw(`"s"."user_id" = "u"."id"`).Where("s.ip", "=", "10.0.0.1")).
+				WhereNotExists(pg.Table("bans b").Select("b.id").Where("b.user_id", "=", Raw(`"u"."id"`)).Where("b.kind", "=", "login")),
+			query: `SELECT "u"."id" FROM "users" "u" WHERE "u"."active" = $1 AND EXISTS (SELECT "s"."id" FROM "sessions" "s" WHERE "s"."user_id" = "u"."id" AND "s"."ip" = $2) ` +
+				`AND NOT EXISTS (SELECT "b"."id" FROM "bans" "b" WHERE "b"."user_id" = "u"."id" AND "b"."kind" = $3)`,
+			args: []any{true, "10.0.0.1", "login"},
+		},
+		{
+			name: "Where with a sub query value",
+			builder: pg.Table("users").Select().Where("a", "=", 1).
+				Where("team_id", "=", pg.Table("teams").Select("id").Where("name", "=", "core")),
+			query: `SELECT * FROM "users" WHERE "a" = $1 AND "team_id" = (SELECT "id" FROM "teams" WHERE "name" = $2)`,
+			args:  []any{1, "core"},
+		},
+		{
+			name: "WhereIn MySQL",
+			builder: NewGoBuilder(MySQL).Table("users").Select("id").Where("active", "=", true).
+				WhereIn("id", NewGoBuilder(MySQL).Table("user_roles").Select("user_id").Where("role_id", "=", 5)),
+			query: "SELECT `id` FROM `users` WHERE `active` = ? AND `id` IN (SELECT `user_id` FROM `user_roles` WHERE `role_id` = ?)",
+			args:  []any{true, 5},
+		},
+		{
+			name: "With",
+			builder: pg.With("active", pg.Table("users").Select("id").Where("active", "=", true).Where("role", "=", "admin")).
+				With("recent", pg.Table("logins").Select("user_id").Where("at", ">", "2025-01-01")).
+				Table("active a").Select("a.id").Join("recent r", "r.user_id", "=", "a.id").Where("a.id", ">", 10),
+			query: `WITH "active" AS (SELECT "id" FROM "users" WHERE "active" = $1 AND "role" = $2), "recent" AS (SELECT "user_id" FROM "logins" WHERE "at" > $3) ` +
+				`SELECT "a"."id" FROM "active" "a" INNER JOIN "recent" "r" ON "r"."user_id" = "a"."id" WHERE "a"."id" > $4`,
+			args: []any{true, "admin", "2025-01-01", 10},
+		},
+		{
+			name: "WithRecursive",
+			builder: pg.WithRecursive("tree", pg.Table("categories").Select("id").Where("parent_id", "=", 1).Union("SELECT c.id FROM categories c JOIN tree t ON c.parent_id = t.id")).
+				Table("tree").Select(),
+			query: `WITH RECURSIVE "tree" AS (SELECT "id" FROM "categories" WHERE "parent_id" = $1 UNION SELECT c.id FROM categories c JOIN tree t ON c.parent_id = t.id) SELECT * FROM "tree"`,
+			args:  []any{1},
+		},
+		{
+			name: "Having",
+			builder: pg.Table("orders").Select("user_id").Where("status", "=", "paid").GroupBy("user_id").
+				Having("count(*) > ?", 5).Having("sum(total) > ?", 100),
+			query: `SELECT "user_id" FROM "orders" WHERE "status" = $1 GROUP BY "user_id" HAVING count(*) > $2 OR sum(total) > $3`,
+			args:  []any{"paid", 5, 100},
+		},
+		{
+			name:    "OnConflict DoNothing",
+			builder: pg.Table("users").Create(map[string]any{"email": "a@test", "fullname": "A"}).OnConflict("email").DoNothing(),
+			query:   `INSERT INTO "users" ("email", "fullname") VALUES ($1, $2) ON CONFLICT ("email") DO NOTHING`,
+			args:    []any{"a@test", "A"},
+		},
+		{
+			name:    "OnConflict DoUpdate",
+			builder: pg.Table("users").Create(map[string]any{"email": "a@test", "fullname": "A", "phone": "1"}, "id").OnConflict("email").DoUpdate(),
+			query:   `INSERT INTO "users" ("email", "fullname", "phone") VALUES ($1, $2, $3) ON CONFLICT ("email") DO UPDATE SET "fullname" = EXCLUDED."fullname", "phone" = EXCLUDED."phone" RETURNING "id"`,
+			args:    []any{"a@test", "A", "1"},
+		},
+		{
+			name: "OnConflict DoUpdateSet",
+			builder: NewGoBuilder(SQLite).Table("counters").Create(map[string]any{"name": "home", "hits": 1}).OnConflict("name").
+				DoUpdateSet(map[string]any{"hits": Raw(`"hits" + ?`, 1), "updated_at": "now"}),
+			query: `INSERT INTO "counters" ("hits", "name") VALUES (?1, ?2) ON CONFLICT ("name") DO UPDATE SET "hits" = "hits" + ?3, "updated_at" = ?4`,
+			args:  []any{1, "home", 1, "now"},
+		},
+		{
+			name:    "OnConflict MySQL",
+			builder: NewGoBuilder(MySQL).Table("users").Create(map[string]any{"email": "a@test", "fullname": "A"}).OnConflict("email").DoUpdate("fullname"),
+			query:   "INSERT INTO `users` (`email`, `fullname`) VALUES (?, ?) ON DUPLICATE KEY UPDATE `fullname` = VALUES(`fullname`)",
+			args:    []any{"a@test", "A"},
+		},
+		{
+			name:    "OnConflict DoNothing MySQL",
+			builder: NewGoBuilder(MySQL).Table("users").Create(map[string]any{"email": "a@test"}).OnConflict("email").DoNothing(),
+			query:   "INSERT IGNORE INTO `users` (`email`) VALUES (?)",
+			args:    []any{"a@test"},
+		},
+		{
+			name:    "WhereRaw quoted marks",
+			builder: pg.Table("posts").Select().WhereRaw(`title = 'why?' AND "what?" = ? AND body LIKE 'it''s ?'`, 1).WhereRaw("id > ?", 2),
+			query:   `SELECT * FROM "posts" WHERE title = 'why?' AND "what?" = $1 AND body LIKE 'it''s ?' AND id > $2`,
+			args:    []any{1, 2},
+		},
+		{
+			name:    "Raw value",
+			builder: pg.Table("posts").Update(map[string]any{"hits": Raw("hits + ?", 1), "title": "t"}).Where("id", "=", 3),
+			query:   `UPDATE "posts" SET "hits" = hits + $1, "title" = $2 WHERE "id" = $3`,
+			args:    []any{1, "t", 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.builder.Err(); err != nil {
+				t.Fatal(err)
+			}
+			query, args := tt.builder.Prepare()
+			if query != tt.query {
+				t.Errorf("query\n got: %s\nwant: %s", query, tt.query)
+			}
+			if !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("args %v, want %v", args, tt.args)
+			}
+		})
+	}
+}
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		name string
+		want map[SQLDialect]string
+	}{
+		{"users", map[SQLDialect]string{Postgres: `"users"`, SQLite: `"users"`, Oracle: `"users"`, MySQL: "`users`", SQLServer: "[users]"}},
+		{"u.*", map[SQLDialect]string{Postgres: `"u".*`, SQLite: `"u".*`, Oracle: `"u".*`, MySQL: "`u`.*", SQLServer: "[u].*"}},
+		{`users"; DROP TABLE users; --`, map[SQLDialect]string{
+			Postgres: `"users""; DROP TABLE users; --"`, SQLite: `"users""; DROP TABLE users; --"`, Oracle: `"users""; DROP TABLE users; --"`,
+			MySQL: "`users\"; DROP TABLE users; --`", SQLServer: `[users"; DROP TABLE users; --]`,
+		}},
+		{"a`b.c", map[SQLDialect]string{Postgres: "\"a`b\".\"c\"", SQLite: "\"a`b\".\"c\"", Oracle: "\"a`b\".\"c\"", MySQL: "`a``b`.`c`", SQLServer: "[a`b].[c]"}},
+		{"x]; DELETE FROM t; --", map[SQLDialect]string{
+			Postgres: `"x]; DELETE FROM t; --"`, SQLite: `"x]; DELETE FROM t; --"`, Oracle: `"x]; DELETE FROM t; --"`,
+			MySQL: "`x]; DELETE FROM t; --`", SQLServer: "[x]]; DELETE FROM t; --]",
+		}},
+	}
+	for _, tt := range tests {
+		for dialect, want := range tt.want {
+			if quoted := Quote(dialect, tt.name); quoted != want {
+				t.Errorf("%s quote of %q is %s, want %s", dialect, tt.name, quoted, want)
+			}
+		}
+	}
+}
+
+// TestRejected checks that names with SQL and operators that are not allowed make the builder fail in every dialect
+func TestRejected(t *testing.T) {
+	tests := map[string]func(gb *GoBuilder) *GoBuilder{
+		"table":          func(gb *GoBuilder) *GoBuilder { return gb.Table("users; DROP TABLE users").Select() },
+		"column":         func(gb *GoBuilder) *GoBuilder { return gb.Table("users").Select("id, (SELECT password FROM users) x") },
+		"where column":   func(gb *GoBuilder) *GoBuilder { return gb.Table("users").Select().Where("id = 1 OR 1 =", "=", 1) },
+		"order":          func(gb *GoBuilder) *GoBuilder { return gb.Table("users").Select().OrderBy("id; DROP TABLE users") },
+		"where operator": func(gb *GoBuilder) *GoBuilder { return gb.Table("users").Select().Where("id", "= 1 OR 1 =", 1) },
+		"or operator": func(gb *GoBuilder) *GoBuilder {
+			return gb.Table("users").Select().OrWhere("id", "; DROP TABLE users; --", 1)
+		},
+		"join operator": func(gb *GoBuilder) *GoBuilder {
+			return gb.Table("users u").Select().Join("roles r", "r.id", "= r.id OR", "u.id")
+		},
+		"unknown operator": func(gb *GoBuilder) *GoBuilder { return gb.Table("users").Select().Where("id", "SIMILAR TO", 1) },
+		"group operator": func(gb *GoBuilder) *GoBuilder {
+			return gb.Table("users").Select().WhereGroup(func(q *GoBuilder) *GoBuilder { return q.Where("id", "=>", 1) })
+		},
+		"sub query operator": func(gb *GoBuilder) *GoBuilder {
+			return gb.Table("users").Select().WhereIn("id", gb.Table("roles").Select("user_id").Where("id", "OR", 1))
+		},
+	}
+	for _, dialect := range []SQLDialect{Postgres, MySQL, SQLite, SQLServer, Oracle} {
+		for name, fn := range tests {
+			t.Run(string(dialect)+" "+name, func(t *testing.T) {
+				gb := fn(NewGoBuilder(dialect))
+				if gb.Err() == nil {
+					t.Fatal("no error")
+				}
+				if query, args := gb.Prepare(); query != "" || args != nil {
+					t.Fatalf("prepared %q %v, want an empty query", query, args)
+				}
+				if query := gb.Sql(); query != "" {
+					t.Fatalf("sql %q, want an empty query", query)
+				}
+			})
+		}
+	}
+}
+
+func TestValidOperator(t *testing.T) {
+	for _, operator := range []string{"=", "!=", "<>", "<", "<=", ">", ">=", "like", "Not  Like", "ILIKE", "in", "NOT IN", "is", "is not"} {
+		if !ValidOperator(operator) {
+			t.Errorf("operator %q rejected", operator)
+		}
+	}
+	for _, operator := range []string{"", "==", "=>", "OR", "; --", "= 1 OR 1 =", "LIKE '%'", "SIMILAR TO", "NOTLIKE"} {
+		if ValidOperator(operator) {
+			t.Errorf("operator %q accepted", operator)
+		}
+	}
+}
@@ -26,6 +26,8 @@ type Mail struct {
 	Cc          []string
 	Bcc         []string
 	Attachments map[string][]byte
+
+	contentType string
 }
 
 // SetFrom sets the sender's email address
@@ -102,13 +104,13 @@ func (m *Mail) SetAttachment(attachments map[string][]byte) *Mail {
 
 // SendText sends the email with plain text content
 func (m *Mail) SendText() error {
-	m.Subject = "text/plain: " + m.Subject
+	m.contentType = "text/plain"
 	return m.send()
 }
 
 // SendHTML sends the email with HTML content
 func (m *Mail) SendHTML() error {
-	m.Subject = "text/html: " + m.Subject
+	m.contentType = "text/html"
 	return m.send()
 }
 
@@ -130,7 +132,7 @@ func (m *Mail) send() error {
 	message.WriteString("Content-Type: multipart/mixed; boundary=BOUNDARY\n\n")
 
 	// Add email content
-	message.WriteString(fmt.Sprintf("--BOUNDARY\nContent-Type: text/plain\n\n%s\n\n", m.Content))
+	message.WriteString(fmt.Sprintf("--BOUNDARY\nContent-Type: %s; charset=UTF-8\n\n%s\n\n", m.contentType, m.Content))
 
 	// Add attachments
 	for filename, data := range m.Attachments {
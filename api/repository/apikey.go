@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/mstgnz/starter-kit/api/infra/config"
+	"github.com/mstgnz/starter-kit/api/model"
+)
+
+type apiKeyRepository struct {
+}
+
+func NewAPIKeyRepository() *apiKeyRepository {
+	return &apiKeyRepository{}
+}
+
+func (r *apiKeyRepository) Create(ctx context.Context, apiKey *model.APIKey) error {
+	query, err := config.App().QUERY.Get("API_KEY_INSERT")
+	if err != nil {
+		return err
+	}
+
+	var expiresAt any
+	if apiKey.ExpiresAt != nil {
+		expiresAt = apiKey.ExpiresAt.Format("2006-01-02 15:04:05")
+	}
+
+	return config.App().DB.QueryRowContext(ctx, query, apiKey.UserID, apiKey.Name, apiKey.Prefix, apiKey.KeyHash, strings.Join(apiKey.Scopes, ","), expiresAt).Scan(&apiKey.ID, &apiKey.CreatedAt)
+}
+
+func (r *apiKeyRepository) List(ctx context.Context, userId int) ([]*model.APIKey, error) {
+	apiKeys := []*model.APIKey{}
+
+	query, err := config.App().QUERY.Get("API_KEY_LIST")
+	if err != nil {
+		return apiKeys, err
+	}
+
+	rows, err := config.App().DB.QueryContext(ctx, query, userId)
+	if err != nil {
+		return apiKeys, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	for rows.Next() {
+		apiKey, err := scanAPIKey(rows)
+		if err != nil {
+			return apiKeys, err
+		}
+		apiKeys = append(apiKeys, apiKey)
+	}
+
+	return apiKeys, rows.Err()
+}
+
+// GetWithHash returns the active, not expired api key with the given hash
+func (r *apiKeyRepository) GetWithHash(ctx context.Context, keyHash string) (*model.APIKey, error) {
+	query, err := config.App().QUERY.Get("API_KEY_GET_WITH_HASH")
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Format("2006-01-02 15:04:05")
+	apiKey, err := scanAPIKey(config.App().DB.QueryRowContext(ctx, query, keyHash, now))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("api key not found")
+		}
+		return nil, err
+	}
+
+	return apiKey, nil
+}
+
+func (r *apiKeyRepository) LastUsedUpdate(ctx context.Context, id int) error {
+	query, err := config.App().QUERY.Get("API_KEY_LAST_USED")
+	if err != nil {
+		return err
+	}
+
+	lastUsed := time.Now().Format("2006-01-02 15:04:05")
+	_, err = config.App().DB.ExecContext(ctx, query, lastUsed, id)
+	return err
+}
+
+func (r *apiKeyRepository) Revoke(ctx context.Context, id, userId int) error {
+	query, err := config.App().QUERY.Get("API_KEY_REVOKE")
+	if err != nil {
+		return err
+	}
+
+	revokedAt := time.Now().Format("2006-01-02 15:04:05")
+	result, err := config.App().DB.ExecContext(ctx, query, revokedAt, id, userId)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return errors.New("api key not found")
+	}
+
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAPIKey(row scanner) (*model.APIKey, error) {
+	apiKey := &model.APIKey{}
+	var scopes string
+	if err := row.Scan(&apiKey.ID, &apiKey.UserID, &apiKey.Name, &apiKey.Prefix, &scopes, &apiKey.LastUsedAt, &apiKey.ExpiresAt, &apiKey.RevokedAt, &apiKey.CreatedAt); err != nil {
+		return nil, err
+	}
+	apiKey.Scopes = []string{}
+	if scopes != "" {
+		apiKey.Scopes = strings.Split(scopes, ",")
+	}
+	return apiKey, nil
+}
@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/mstgnz/starter-kit/api/infra/config"
+	"github.com/mstgnz/starter-kit/api/model"
+)
+
+type auditRepository struct {
+}
+
+func NewAuditRepository() *auditRepository {
+	return &auditRepository{}
+}
+
+// Create stores the entry, a zero user or actor id is stored as null
+func (r *auditRepository) Create(ctx context.Context, entry *model.AuditLog) error {
+	query, err := config.App().QUERY.Get("AUDIT_INSERT")
+	if err != nil {
+		return err
+	}
+
+	_, err = config.App().DB.ExecContext(ctx, query, nullID(entry.UserID), nullID(entry.ActorID), entry.Event, entry.IP, entry.Detail)
+	return err
+}
+
+func nullID(id int) any {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
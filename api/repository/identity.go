@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/mstgnz/starter-kit/api/infra/config"
+	"github.com/mstgnz/starter-kit/api/model"
+)
+
+type identityRepository struct {
+}
+
+func NewIdentityRepository() *identityRepository {
+	return &identityRepository{}
+}
+
+// Get returns the identity of the subject at the provider, sql.ErrNoRows if it is not linked to a user
+func (r *identityRepository) Get(ctx context.Context, provider, subject string) (*model.UserIdentity, error) {
+	query, err := config.App().QUERY.Get("IDENTITY_GET")
+	if err != nil {
+		return nil, err
+	}
+
+	identity := &model.UserIdentity{}
+	err = config.App().DB.QueryRowContext(ctx, query, provider, subject).Scan(&identity.ID, &identity.UserID, &identity.Provider, &identity.Subject, &identity.Email, &identity.LastLoginAt, &identity.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return identity, nil
+}
+
+// Create links the identity to the user, it is created at a login so the login time is set
+func (r *identityRepository) Create(ctx context.Context, identity *model.UserIdentity) error {
+	query, err := config.App().QUERY.Get("IDENTITY_INSERT")
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Format("2006-01-02 15:04:05")
+	_, err = config.App().DB.ExecContext(ctx, query, identity.UserID, identity.Provider, identity.Subject, identity.Email, now)
+	return err
+}
+
+// Login records a login with the identity and the current email at the provider
+func (r *identityRepository) Login(ctx context.Context, id int, email string) error {
+	query, err := config.App().QUERY.Get("IDENTITY_LOGIN")
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Format("2006-01-02 15:04:05")
+	_, err = config.App().DB.ExecContext(ctx, query, email, now, id)
+	return err
+}
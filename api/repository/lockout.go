@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/mstgnz/starter-kit/api/infra/config"
+	"github.com/mstgnz/starter-kit/api/model"
+)
+
+type lockoutRepository struct {
+}
+
+func NewLockoutRepository() *lockoutRepository {
+	return &lockoutRepository{}
+}
+
+// Locked returns the lockout of the user if the user cannot log in now, sql.ErrNoRows otherwise
+func (r *lockoutRepository) Locked(ctx context.Context, userId int) (*model.Lockout, error) {
+	query, err := config.App().QUERY.Get("LOCKOUT_GET")
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Format("2006-01-02 15:04:05")
+	lockout := &model.Lockout{UserID: userId}
+	if err := config.App().DB.QueryRowContext(ctx, query, userId, now).Scan(&lockout.FailedAttempts, &lockout.LockedUntil); err != nil {
+		return nil, err
+	}
+	if lockout.LockedUntil != nil {
+		// the time is stored as local wall clock time and read back without a zone
+		t := *lockout.LockedUntil
+		local := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.Local)
+		lockout.LockedUntil = &local
+	}
+	return lockout, nil
+}
+
+// Fail counts a failed login of the user and returns the number of failures since windowStart,
+// older failures are forgotten
+func (r *lockoutRepository) Fail(ctx context.Context, userId int, windowStart time.Time) (int, error) {
+	query, err := config.App().QUERY.Get("LOCKOUT_FAIL")
+	if err != nil {
+		return 0, err
+	}
+
+	attempts := 0
+	now := time.Now().Format("2006-01-02 15:04:05")
+	err = config.App().DB.QueryRowContext(ctx, query, userId, now, windowStart.Format("2006-01-02 15:04:05")).Scan(&attempts)
+	return attempts, err
+}
+
+// Lock keeps the user from logging in until the given time
+func (r *lockoutRepository) Lock(ctx context.Context, userId int, until time.Time) error {
+	query, err := config.App().QUERY.Get("LOCKOUT_LOCK")
+	if err != nil {
+		return err
+	}
+
+	_, err = config.App().DB.ExecContext(ctx, query, until.Format("2006-01-02 15:04:05"), userId)
+	return err
+}
+
+// Delete forgets the failed logins of the user, it reports whether there were any
+func (r *lockoutRepository) Delete(ctx context.Context, userId int) (bool, error) {
+	query, err := config.App().QUERY.Get("LOCKOUT_DELETE")
+	if err != nil {
+		return false, err
+	}
+
+	result, err := config.App().DB.ExecContext(ctx, query, userId)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mstgnz/starter-kit/api/infra/testdb"
+	"github.com/mstgnz/starter-kit/api/model"
+)
+
+// TestLockedZone checks that locked_until is read back as the time that was written when the local zone is not UTC
+func TestLockedZone(t *testing.T) {
+	testdb.Open(t)
+	ctx := context.Background()
+
+	local := time.Local
+	time.Local = time.FixedZone("UTC+3", 3*60*60)
+	t.Cleanup(func() {
+		time.Local = local
+	})
+
+	user, err := NewUserRepository().Create(ctx, &model.Register{Fullname: "Zone", Email: "zone@lockout.test", Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lockouts := NewLockoutRepository()
+	if _, err := lockouts.Fail(ctx, user.ID, time.Now().Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	until := time.Now().Add(10 * time.Minute).Truncate(time.Second)
+	if err := lockouts.Lock(ctx, user.ID, until); err != nil {
+		t.Fatal(err)
+	}
+
+	lockout, err := lockouts.Locked(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lockout.FailedAttempts != 1 || lockout.LockedUntil == nil || !lockout.LockedUntil.Equal(until) {
+		t.Fatalf("lockout %d until %v, want 1 until %v", lockout.FailedAttempts, lockout.LockedUntil, until)
+	}
+}
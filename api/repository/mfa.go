@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/mstgnz/starter-kit/api/infra/config"
+	"github.com/mstgnz/starter-kit/api/model"
+)
+
+type mfaRepository struct {
+}
+
+func NewMFARepository() *mfaRepository {
+	return &mfaRepository{}
+}
+
+// TOTP returns the secret of the user, sql.ErrNoRows if the user has none
+func (r *mfaRepository) TOTP(ctx context.Context, userId int) (*model.TOTP, error) {
+	query, err := config.App().QUERY.Get("TOTP_GET")
+	if err != nil {
+		return nil, err
+	}
+
+	totp := &model.TOTP{}
+	err = config.App().DB.QueryRowContext(ctx, query, userId).Scan(&totp.UserID, &totp.Secret, &totp.LastCounter, &totp.ConfirmedAt, &totp.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return totp, nil
+}
+
+// CreateTOTP stores a new unconfirmed secret for the user
+func (r *mfaRepository) CreateTOTP(ctx context.Context, userId int, secret string) error {
+	query, err := config.App().QUERY.Get("TOTP_INSERT")
+	if err != nil {
+		return err
+	}
+
+	_, err = config.App().DB.ExecContext(ctx, query, userId, secret)
+	return err
+}
+
+func (r *mfaRepository) DeleteTOTP(ctx context.Context, userId int) error {
+	query, err := config.App().QUERY.Get("TOTP_DELETE")
+	if err != nil {
+		return err
+	}
+
+	_, err = config.App().DB.ExecContext(ctx, query, userId)
+	return err
+}
+
+// ConfirmTOTP turns the secret on, counter is the step of the code that confirmed it
+func (r *mfaRepository) ConfirmTOTP(ctx context.Context, userId int, counter int64) (bool, error) {
+	query, err := config.App().QUERY.Get("TOTP_CONFIRM")
+	if err != nil {
+		return false, err
+	}
+
+	confirmedAt := time.Now().Format("2006-01-02 15:04:05")
+	result, err := config.App().DB.ExecContext(ctx, query, confirmedAt, counter, userId)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// UseTOTP records the step of a used code, it reports false if the step or a later one was already used
+func (r *mfaRepository) UseTOTP(ctx context.Context, userId int, counter int64) (bool, error) {
+	query, err := config.App().QUERY.Get("TOTP_USE")
+	if err != nil {
+		return false, err
+	}
+
+	result, err := config.App().DB.ExecContext(ctx, query, counter, userId)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// CreateRecoveryCodes stores the hashes of the recovery codes of the user
+func (r *mfaRepository) CreateRecoveryCodes(ctx context.Context, userId int, codeHashes []string) error {
+	query, err := config.App().QUERY.Get("RECOVERY_CODE_INSERT")
+	if err != nil {
+		return err
+	}
+
+	for _, codeHash := range codeHashes {
+		if _, err := config.App().DB.ExecContext(ctx, query, userId, codeHash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *mfaRepository) DeleteRecoveryCodes(ctx context.Context, userId int) error {
+	query, err := config.App().QUERY.Get("RECOVERY_CODE_DELETE")
+	if err != nil {
+		return err
+	}
+
+	_, err = config.App().DB.ExecContext(ctx, query, userId)
+	return err
+}
+
+// UseRecoveryCode marks an unused recovery code of the user as used, it reports false if there is none
+func (r *mfaRepository) UseRecoveryCode(ctx context.Context, userId int, codeHash string) (bool, error) {
+	query, err := config.App().QUERY.Get("RECOVERY_CODE_USE")
+	if err != nil {
+		return false, err
+	}
+
+	usedAt := time.Now().Format("2006-01-02 15:04:05")
+	result, err := config.App().DB.ExecContext(ctx, query, usedAt, userId, codeHash)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/mstgnz/starter-kit/api/infra/paginate"
+	"github.com/mstgnz/starter-kit/api/pkg/mstgnz/gobuilder"
+)
+
+// Paginate returns a page of the rows matching the scopes, the search and the filters. The sort column must be the primary key
+// or a column tagged "sort", the primary key breaks ties and null values of a pointer field come last in both directions. The search matches the columns tagged "search" case insensitively
+// and filters must be columns tagged "filter". Invalid parameters are returned as *paginate.Error.
+// Offset mode runs a count and a data query, keyset mode skips the count
+// and continues after the cursor. next_cursor is set in both modes so a client can switch to keyset mode.
+func (r *Repository[T]) Paginate(ctx context.Context, params *paginate.Params, scopes ...Scope) (*paginate.Page[T], error) {
+	if r.meta.pk == "" {
+		return nil, fmt.Errorf("%s has no primary key", r.table)
+	}
+	params.Normalize("-" + r.meta.pk)
+
+	column, desc := params.SortColumn()
+	if column != r.meta.pk && !r.meta.sortable[column] {
+		return nil, &paginate.Error{Message: "invalid sort field " + column}
+	}
+
+	filters, err := r.filters(params)
+	if err != nil {
+		return nil, err
+	}
+	scopes = append(scopes[:len(scopes):len(scopes)], filters...)
+
+	page := &paginate.Page[T]{Meta: paginate.Meta{PerPage: params.PerPage}}
+
+	var cursor *paginate.Cursor
+	if params.Keyset() {
+		var err error
+		if cursor, err = paginate.DecodeCursor(params.Cursor); err != nil {
+			return nil, err
+		}
+		if cursor.Value, err = r.meta.cursorValue(column, cursor.Value); err != nil {
+			return nil, paginate.ErrInvalidCursor
+		}
+		if cursor.ID, err = r.meta.cursorValue(r.meta.pk, cursor.ID); err != nil {
+			return nil, paginate.ErrInvalidCursor
+		}
+	} else {
+		total, err := r.Count(ctx, scopes...)
+		if err != nil {
+			return nil, err
+		}
+		page.Meta.Total = &total
+		page.Meta.Page = params.Page
+		page.Meta.LastPage = (total + params.PerPage - 1) / params.PerPage
+	}
+
+	nullable := column != r.meta.pk && r.meta.fields[column].nullable
+	if cursor != nil && cursor.Value == nil && column != r.meta.pk && !nullable {
+		return nil, paginate.ErrInvalidCursor
+	}
+
+	items, err := r.List(ctx, append(scopes[:len(scopes):len(scopes)], func(b *gobuilder.GoBuilder) *gobuilder.GoBuilder {
+		operator := ">"
+		if desc {
+			operator = "<"
+		}
+		quoted := gobuilder.Quote(b.Dialect(), column)
+		after := fmt.Sprintf("(%s, %s) %s (?, ?)", quoted, gobuilder.Quote(b.Dialect(), r.meta.pk), operator)
+		switch {
+		case cursor == nil:
+		case column == r.meta.pk:
+			b = b.Where(column, operator, cursor.ID)
+		case cursor.Value == nil:
+			// a null never compares, the page is in the null rows at the end
+			b = b.IsNull(column).Where(r.meta.pk, operator, cursor.ID)
+		case nullable:
+			b = b.WhereGroup(func(q *gobuilder.GoBuilder) *gobuilder.GoBuilder {
+				return q.WhereRaw(after, cursor.Value, cursor.ID).OrIsNull(column)
+			})
+		default:
+			b = b.WhereRaw(after, cursor.Value, cursor.ID)
+		}
+
+		// dialects put nulls first or last, they are sorted last explicitly
+		if nullable {
+			b = b.OrderByRaw(quoted + " IS NULL")
+		}
+		order := []string{column}
+		if column != r.meta.pk {
+			order = append(order, r.meta.pk)
+		}
+		if desc {
+			b = b.OrderByDesc(order...)
+		} else {
+			b = b.OrderBy(order...)
+		}
+
+		// one more row tells whether there is a next page
+		offset := 0
+		if cursor == nil {
+			offset = params.Offset()
+		}
+		return b.Limit(offset, params.PerPage+1)
+	})...)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(items) > params.PerPage {
+		items = items[:params.PerPage]
+		last := reflect.ValueOf(&items[len(items)-1]).Elem()
+		page.Meta.NextCursor = paginate.EncodeCursor(paginate.Cursor{
+			Value: last.FieldByIndex(r.meta.fields[column].index).Interface(),
+			ID:    last.FieldByIndex(r.meta.fields[r.meta.pk].index).Interface(),
+		})
+	}
+	page.Items = items
+
+	return page, nil
+}
+
+// filters returns the search and the filters of the params as scopes, unknown fields and invalid values are errors
+func (r *Repository[T]) filters(params *paginate.Params) ([]Scope, error) {
+	var scopes []Scope
+	if params.Search != "" && len(r.meta.searchable) > 0 {
+		like := paginate.Contains(params.Search)
+		scopes = append(scopes, func(b *gobuilder.GoBuilder) *gobuilder.GoBuilder {
+			return b.WhereGroup(func(q *gobuilder.GoBuilder) *gobuilder.GoBuilder {
+				for _, column := range r.meta.searchable {
+					q = q.OrWhereRaw("lower("+gobuilder.Quote(q.Dialect(), column)+") LIKE ? "+paginate.LikeEscape, like)
+				}
+				return q
+			})
+		})
+	}
+
+	for _, condition := range params.Filters {
+		if !r.meta.filterable[condition.Field] {
+			return nil, &paginate.Error{Message: "invalid filter field " + condition.Field}
+		}
+		scope, err := condition.Compile(func(value string) (any, error) { return r.meta.parse(condition.Field, value) })
+		if err != nil {
+			return nil, err
+		}
+		scopes = append(scopes, scope)
+	}
+	return scopes, nil
+}
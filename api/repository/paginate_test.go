@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mstgnz/starter-kit/api/infra/paginate"
+	"github.com/mstgnz/starter-kit/api/infra/testdb"
+	"github.com/mstgnz/starter-kit/api/model"
+)
+
+// TestPaginateKeysetWalk follows next_cursor until the last page and checks every row is returned once,
+// last_login is null for some users and created_at is the same for all of them
+func TestPaginateKeysetWalk(t *testing.T) {
+	db := testdb.Open(t)
+	ctx := context.Background()
+
+	users := NewUserRepository()
+	want := map[int]bool{}
+	for i := 0; i < 7; i++ {
+		user, err := users.Create(ctx, &model.Register{Fullname: fmt.Sprintf("Walk %d", i), Email: fmt.Sprintf("walk%d@paginate.test", i), Password: "secret", Phone: "+905550000000"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want[user.ID] = true
+
+		if i%3 == 0 {
+			continue
+		}
+		// two users share a last login so the primary key has to break the tie
+		lastLogin := time.Date(2025, 1, 1, 10, i/2, 0, 0, time.UTC).Format("2006-01-02 15:04:05")
+		if err := db.QueryExec(ctx, db.Builder().Table("users").Update(map[string]any{"last_login": lastLogin}).Where("id", "=", user.ID)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, sort := range []string{"id", "-id", "fullname", "-fullname", "last_login", "-last_login", "created_at", "-created_at"} {
+		t.Run(sort, func(t *testing.T) {
+			seen := map[int]bool{}
+			params := &paginate.Params{PerPage: 2, Sort: sort, Search: "@paginate.test"}
+			for pages := 0; ; pages++ {
+				if pages > len(want) {
+					t.Fatalf("no last page after %d pages", pages)
+				}
+				page, err := users.users.Paginate(ctx, params)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, user := range page.Items {
+					if seen[user.ID] {
+						t.Fatalf("user %d returned twice", user.ID)
+					}
+					seen[user.ID] = true
+				}
+				if page.Meta.NextCursor == "" {
+					break
+				}
+				params = &paginate.Params{PerPage: 2, Sort: sort, Search: "@paginate.test", Cursor: page.Meta.NextCursor}
+			}
+			if len(seen) != len(want) {
+				t.Fatalf("walked %d users, want %d", len(seen), len(want))
+			}
+		})
+	}
+}
+
+// TestPaginateFilters runs the filter query parameters against the users, % and _ in a like value are no wildcards
+func TestPaginateFilters(t *testing.T) {
+	db := testdb.Open(t)
+	ctx := context.Background()
+
+	users := NewUserRepository()
+	ids := map[string]int{}
+	for _, email := range []string{"under_score@filter.test", "underxscore@filter.test", "100%@filter.test", "bang!@filter.test"} {
+		user, err := users.Create(ctx, &model.Register{Fullname: "Filter", Email: email, Password: "secret", Phone: "+905550000000"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[email] = user.ID
+	}
+	lastLogin := db.Builder().Table("users").Update(map[string]any{"last_login": "2025-01-02 10:00:00"}).Where("id", "=", ids["under_score@filter.test"])
+	if err := db.QueryExec(ctx, lastLogin); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"filter[email][like]=%25", []string{"100%@filter.test"}},
+		{"filter[email][like]=under_", []string{"under_score@filter.test"}},
+		{"filter[email][like]=!", []string{"bang!@filter.test"}},
+		{"filter[email][like]=UNDER", []string{"under_score@filter.test", "underxscore@filter.test"}},
+		{"filter[id][in]=" + strconv.Itoa(ids["100%@filter.test"]) + "," + strconv.Itoa(ids["bang!@filter.test"]), []string{"100%@filter.test", "bang!@filter.test"}},
+		{"q=_score", []string{"under_score@filter.test"}},
+		{"filter[last_login][gte]=2025-01-02", []string{"under_score@filter.test"}},
+		{"filter[last_login][lt]=2025-01-02", nil},
+		{"filter[last_login]=2025-01-02 10:00:00", []string{"under_score@filter.test"}},
+		{"filter[last_login][gt]=2025-01-02T10:00:00", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			params := &paginate.Params{PerPage: 10, Sort: "email", Search: query.Get("q")}
+			if err := params.Filters.BindQuery("filter", query); err != nil {
+				t.Fatal(err)
+			}
+			// keep the rows of the other tests out
+			params.Filters = append(params.Filters, paginate.Condition{Field: "email", Operator: "like", Value: "@filter.test"})
+
+			page, err := users.users.Paginate(ctx, params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, user := range page.Items {
+				got = append(got, user.Email)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestPaginateInvalid checks that invalid filter values are a paginate.Error before the query runs and that a huge
+// page does not overflow the offset
+func TestPaginateInvalid(t *testing.T) {
+	testdb.Open(t)
+	ctx := context.Background()
+	users := NewUserRepository()
+
+	for _, query := range []string{
+		"filter[id]=abc",
+		"filter[id][in]=1,x",
+		"filter[id][gt]=99999999999999999999",
+		"filter[active]=maybe",
+		"filter[last_login][gte]=yesterday",
+		"filter[created_at][lt]=2025-13-01",
+		"filter[deleted_at][null]=x",
+		"filter[fullname]=a",
+	} {
+		values, err := url.ParseQuery(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		params := &paginate.Params{}
+		if err := params.Filters.BindQuery("filter", values); err != nil {
+			t.Fatal(err)
+		}
+		var invalid *paginate.Error
+		if _, err := users.users.Paginate(ctx, params); !errors.As(err, &invalid) {
+			t.Errorf("%s: %v, want a paginate.Error", query, err)
+		}
+	}
+
+	params := &paginate.Params{Page: int(^uint(0) >> 1), PerPage: 10}
+	page, err := users.users.Paginate(ctx, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 0 || params.Offset() < 0 {
+		t.Fatalf("%d items at offset %d, want none", len(page.Items), params.Offset())
+	}
+}
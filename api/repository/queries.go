@@ -0,0 +1,63 @@
+package repository
+
+import "github.com/mstgnz/starter-kit/api/infra/load"
+
+// queryNames are the named queries the repositories run, loading the queries fails when one of them is missing.
+// The queries of user.sql are not loaded by name, user.go runs the functions generated for them in queries.
+var queryNames = []string{
+	// apikey.go
+	"API_KEY_GET_WITH_HASH",
+	"API_KEY_INSERT",
+	"API_KEY_LAST_USED",
+	"API_KEY_LIST",
+	"API_KEY_REVOKE",
+	// audit.go
+	"AUDIT_INSERT",
+	// identity.go
+	"IDENTITY_GET",
+	"IDENTITY_INSERT",
+	"IDENTITY_LOGIN",
+	// lockout.go
+	"LOCKOUT_DELETE",
+	"LOCKOUT_FAIL",
+	"LOCKOUT_GET",
+	"LOCKOUT_LOCK",
+	// mfa.go
+	"RECOVERY_CODE_DELETE",
+	"RECOVERY_CODE_INSERT",
+	"RECOVERY_CODE_USE",
+	"TOTP_CONFIRM",
+	"TOTP_DELETE",
+	"TOTP_GET",
+	"TOTP_INSERT",
+	"TOTP_USE",
+	// role.go
+	"ROLE_EXISTS",
+	"ROLE_GRANT_ALL_PERMISSIONS",
+	"USER_PERMISSIONS",
+	"USER_ROLES",
+	"USER_ROLE_ASSIGN",
+	"USER_ROLE_REVOKE",
+	// session.go
+	"SESSION_FAMILIES",
+	"SESSION_GET",
+	"SESSION_GET_WITH_FAMILY",
+	"SESSION_INSERT",
+	"SESSION_LIST",
+	"SESSION_REVOKE",
+	"SESSION_TOUCH",
+	// token.go
+	"REFRESH_TOKEN_GET_WITH_HASH",
+	"REFRESH_TOKEN_INSERT",
+	"REFRESH_TOKEN_REVOKE_FAMILY",
+	"REFRESH_TOKEN_REVOKE_USER",
+	"REFRESH_TOKEN_USE",
+	// user_token.go
+	"USER_TOKEN_DELETE_UNUSED",
+	"USER_TOKEN_INSERT",
+	"USER_TOKEN_USE",
+}
+
+func init() {
+	load.Require(queryNames...)
+}
@@ -0,0 +1,145 @@
+// Code generated by "generate queries". DO NOT EDIT.
+
+package queries
+
+import (
+	"context"
+	"database/sql"
+)
+
+// DBTX is satisfied by *conn.DB, *sql.DB and *sql.Tx, *conn.DB runs the queries in the transaction of the context
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+const userAdminUpdateSQL = "UPDATE users SET is_admin=$1, updated_at=$2 WHERE id=$3;"
+
+// UserAdminUpdate runs USER_ADMIN_UPDATE of user.sql and returns the number of affected rows
+func UserAdminUpdate(ctx context.Context, db DBTX, isAdmin bool, updatedAt string, id int) (int64, error) {
+	result, err := db.ExecContext(ctx, userAdminUpdateSQL, isAdmin, updatedAt, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const userDeleteSQL = "UPDATE users SET active=$1, deleted_at=$2, updated_at=$3 WHERE id=$4;"
+
+// UserDelete runs USER_DELETE of user.sql and returns the number of affected rows
+func UserDelete(ctx context.Context, db DBTX, active bool, deletedAt string, updatedAt string, id int) (int64, error) {
+	result, err := db.ExecContext(ctx, userDeleteSQL, active, deletedAt, updatedAt, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const userExistsWithEmailSQL = "SELECT count(*) FROM users WHERE email=$1;"
+
+// UserExistsWithEmail runs USER_EXISTS_WITH_EMAIL of user.sql, it returns sql.ErrNoRows when there is no row
+func UserExistsWithEmail(ctx context.Context, db DBTX, email string) (int64, error) {
+	var item int64
+	err := db.QueryRowContext(ctx, userExistsWithEmailSQL, email).Scan(&item)
+	return item, err
+}
+
+const userGetWithEmailSQL = "SELECT id, fullname, email, is_admin, active, password FROM users WHERE email=$1 AND deleted_at isnull;"
+
+// UserGetWithEmailRow is a row of USER_GET_WITH_EMAIL
+type UserGetWithEmailRow struct {
+	ID       int    `json:"id"`
+	Fullname string `json:"fullname"`
+	Email    string `json:"email"`
+	IsAdmin  bool   `json:"is_admin"`
+	Active   bool   `json:"active"`
+	Password string `json:"password"`
+}
+
+// UserGetWithEmail runs USER_GET_WITH_EMAIL of user.sql, it returns sql.ErrNoRows when there is no row
+func UserGetWithEmail(ctx context.Context, db DBTX, email string) (UserGetWithEmailRow, error) {
+	var item UserGetWithEmailRow
+	err := db.QueryRowContext(ctx, userGetWithEmailSQL, email).Scan(&item.ID, &item.Fullname, &item.Email, &item.IsAdmin, &item.Active, &item.Password)
+	return item, err
+}
+
+const userGetWithIDSQL = "SELECT id, fullname, email, is_admin, active, password FROM users WHERE id=$1 AND deleted_at isnull;"
+
+// UserGetWithIDRow is a row of USER_GET_WITH_ID
+type UserGetWithIDRow struct {
+	ID       int    `json:"id"`
+	Fullname string `json:"fullname"`
+	Email    string `json:"email"`
+	IsAdmin  bool   `json:"is_admin"`
+	Active   bool   `json:"active"`
+	Password string `json:"password"`
+}
+
+// UserGetWithID runs USER_GET_WITH_ID of user.sql, it returns sql.ErrNoRows when there is no row
+func UserGetWithID(ctx context.Context, db DBTX, id int) (UserGetWithIDRow, error) {
+	var item UserGetWithIDRow
+	err := db.QueryRowContext(ctx, userGetWithIDSQL, id).Scan(&item.ID, &item.Fullname, &item.Email, &item.IsAdmin, &item.Active, &item.Password)
+	return item, err
+}
+
+const userInsertSQL = "INSERT INTO users (fullname,email,password,phone) VALUES ($1,$2,$3,$4) RETURNING id,fullname,email,phone;"
+
+// UserInsertRow is a row of USER_INSERT
+type UserInsertRow struct {
+	ID       int    `json:"id"`
+	Fullname string `json:"fullname"`
+	Email    string `json:"email"`
+	Phone    string `json:"phone"`
+}
+
+// UserInsert runs USER_INSERT of user.sql, it returns sql.ErrNoRows when there is no row
+func UserInsert(ctx context.Context, db DBTX, fullname string, email string, password string, phone string) (UserInsertRow, error) {
+	var item UserInsertRow
+	err := db.QueryRowContext(ctx, userInsertSQL, fullname, email, password, phone).Scan(&item.ID, &item.Fullname, &item.Email, &item.Phone)
+	return item, err
+}
+
+const userLastLoginSQL = "UPDATE users SET last_login=$1 WHERE id=$2;"
+
+// UserLastLogin runs USER_LAST_LOGIN of user.sql and returns the number of affected rows
+func UserLastLogin(ctx context.Context, db DBTX, lastLogin string, id int) (int64, error) {
+	result, err := db.ExecContext(ctx, userLastLoginSQL, lastLogin, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const userRestoreSQL = "UPDATE users SET active=$1, deleted_at=NULL, updated_at=$2 WHERE id=$3 AND deleted_at IS NOT NULL;"
+
+// UserRestore runs USER_RESTORE of user.sql and returns the number of affected rows
+func UserRestore(ctx context.Context, db DBTX, active bool, updatedAt string, id int) (int64, error) {
+	result, err := db.ExecContext(ctx, userRestoreSQL, active, updatedAt, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const userUpdatePassSQL = "UPDATE users SET password=$1, updated_at=$2 WHERE id=$3;"
+
+// UserUpdatePass runs USER_UPDATE_PASS of user.sql and returns the number of affected rows
+func UserUpdatePass(ctx context.Context, db DBTX, password string, updatedAt string, id int) (int64, error) {
+	result, err := db.ExecContext(ctx, userUpdatePassSQL, password, updatedAt, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const userVerifyEmailSQL = "UPDATE users SET email_verified_at=$1, updated_at=$1 WHERE id=$2 AND email_verified_at isnull;"
+
+// UserVerifyEmail runs USER_VERIFY_EMAIL of user.sql and returns the number of affected rows
+func UserVerifyEmail(ctx context.Context, db DBTX, emailVerifiedAt string, id int) (int64, error) {
+	result, err := db.ExecContext(ctx, userVerifyEmailSQL, emailVerifiedAt, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
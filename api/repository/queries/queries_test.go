@@ -0,0 +1,52 @@
+package queries
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/mstgnz/starter-kit/api/asset"
+	"github.com/mstgnz/starter-kit/api/infra/load"
+)
+
+// TestGenerated checks that the generated functions run the queries of asset/queries, a changed query has to be
+// generated again with "generate queries repository/queries/queries.go user.sql"
+func TestGenerated(t *testing.T) {
+	generated := map[string]string{
+		"USER_ADMIN_UPDATE":      userAdminUpdateSQL,
+		"USER_DELETE":            userDeleteSQL,
+		"USER_EXISTS_WITH_EMAIL": userExistsWithEmailSQL,
+		"USER_GET_WITH_EMAIL":    userGetWithEmailSQL,
+		"USER_GET_WITH_ID":       userGetWithIDSQL,
+		"USER_INSERT":            userInsertSQL,
+		"USER_LAST_LOGIN":        userLastLoginSQL,
+		"USER_RESTORE":           userRestoreSQL,
+		"USER_UPDATE_PASS":       userUpdatePassSQL,
+		"USER_VERIFY_EMAIL":      userVerifyEmailSQL,
+	}
+
+	queryFS, err := fs.Sub(asset.Queries, "queries")
+	if err != nil {
+		t.Fatal(err)
+	}
+	queries, err := load.NewQueries(queryFS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, query := range queries.All() {
+		if query.File != "user.sql" {
+			continue
+		}
+		sql, ok := generated[query.Name]
+		if !ok {
+			t.Errorf("%s is not generated", query.Name)
+			continue
+		}
+		if sql != query.SQL {
+			t.Errorf("%s changed since it was generated", query.Name)
+		}
+		delete(generated, query.Name)
+	}
+	for name := range generated {
+		t.Errorf("%s is generated but no longer in user.sql", name)
+	}
+}
@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"io/fs"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mstgnz/starter-kit/api/asset"
+	"github.com/mstgnz/starter-kit/api/infra/load"
+)
+
+// TestQueryNames checks that every query name used in the repositories is in queryNames and in asset/queries
+func TestQueryNames(t *testing.T) {
+	literal := regexp.MustCompile(`"([A-Z][A-Z0-9_]*)"`)
+
+	entries, err := os.ReadDir(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".go") || strings.HasSuffix(entry.Name(), "_test.go") || entry.Name() == "queries.go" {
+			continue
+		}
+		src, err := os.ReadFile(entry.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, match := range literal.FindAllStringSubmatch(string(src), -1) {
+			if !slices.Contains(queryNames, match[1]) {
+				t.Errorf("%s: query %s is not in queryNames", entry.Name(), match[1])
+			}
+		}
+	}
+
+	queryFS, err := fs.Sub(asset.Queries, "queries")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := load.NewQueries(queryFS); err != nil {
+		t.Fatal(err)
+	}
+}
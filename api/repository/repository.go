@@ -0,0 +1,364 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/mstgnz/starter-kit/api/infra/config"
+	"github.com/mstgnz/starter-kit/api/infra/conn"
+	"github.com/mstgnz/starter-kit/api/pkg/mstgnz/gobuilder"
+)
+
+// Scope narrows a query, e.g. func(b *gobuilder.GoBuilder) *gobuilder.GoBuilder { return b.Where("active", "=", true) }
+type Scope func(b *gobuilder.GoBuilder) *gobuilder.GoBuilder
+
+// Repository is a typed CRUD repository for the table of T.
+// Columns are mapped with `db:"column"` struct tags, untagged fields fall back to the snake case field name
+// and `db:"-"` skips the field. The options "pk" and "readonly" mark the primary key and the columns
+// filled by the database, both are left out of inserts and updates. The options "sort", "filter" and "search"
+// allow a list endpoint to sort, filter and search by the column, the primary key can always be sorted by:
+//
+//	type User struct {
+//		ID        int        `db:"id,pk"`
+//		Email     string     `db:"email,sort,filter,search"`
+//		CreatedAt *time.Time `db:"created_at,readonly,sort"`
+//	}
+//
+// Tables with a deleted_at column are soft deleted and deleted rows are excluded from Find and List
+// unless the repository is returned by WithTrashed.
+type Repository[T any] struct {
+	table   string
+	meta    *modelMeta
+	trashed bool
+}
+
+func NewRepository[T any](table string) *Repository[T] {
+	return &Repository[T]{
+		table: table,
+		meta:  metaOf(reflect.TypeFor[T]()),
+	}
+}
+
+// WithTrashed returns a copy of the repository that includes the soft deleted rows
+func (r *Repository[T]) WithTrashed() *Repository[T] {
+	trashed := *r
+	trashed.trashed = true
+	return &trashed
+}
+
+// Find returns the row with the given primary key
+func (r *Repository[T]) Find(ctx context.Context, id any) (*T, error) {
+	items, err := r.List(ctx, func(b *gobuilder.GoBuilder) *gobuilder.GoBuilder {
+		return b.Where(r.meta.pk, "=", id).Limit(0, 1)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &items[0], nil
+}
+
+// List returns the rows matching the scopes
+func (r *Repository[T]) List(ctx context.Context, scopes ...Scope) ([]T, error) {
+	builder := r.apply(r.builder().Select(r.meta.columns...), scopes)
+	if err := builder.Err(); err != nil {
+		return nil, err
+	}
+	query, params := builder.Prepare()
+
+	rows, err := config.App().DB.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	return scanRows[T](rows, r.meta)
+}
+
+// Count returns the number of rows matching the scopes
+func (r *Repository[T]) Count(ctx context.Context, scopes ...Scope) (int, error) {
+	builder := r.apply(r.builder().SelectRaw("count(*)"), scopes)
+	return config.App().DB.DynamicCount(ctx, builder)
+}
+
+// Create inserts the item and sets its primary key
+func (r *Repository[T]) Create(ctx context.Context, item *T) error {
+	values := r.meta.values(reflect.ValueOf(item).Elem())
+	if r.meta.pk == "" {
+		return config.App().DB.QueryExec(ctx, r.builder().Create(values))
+	}
+
+	pk := reflect.ValueOf(item).Elem().FieldByIndex(r.meta.fields[r.meta.pk].index)
+	return config.App().DB.Insert(ctx, r.builder().Create(values), r.meta.pk, pk.Addr().Interface())
+}
+
+// Update writes every column of the item to the row with the same primary key
+func (r *Repository[T]) Update(ctx context.Context, item *T) error {
+	if r.meta.pk == "" {
+		return fmt.Errorf("%s has no primary key", r.table)
+	}
+	value := reflect.ValueOf(item).Elem()
+	values := r.meta.values(value)
+	if _, ok := r.meta.fields["updated_at"]; ok {
+		values["updated_at"] = time.Now().Format("2006-01-02 15:04:05")
+	}
+	id := value.FieldByIndex(r.meta.fields[r.meta.pk].index).Interface()
+
+	return config.App().DB.QueryExec(ctx, r.builder().Update(values).Where(r.meta.pk, "=", id))
+}
+
+// ErrNotSoftDeleted is returned by SoftDelete when no row with the id is left to delete
+var ErrNotSoftDeleted = errors.New("not soft deleted")
+
+// SoftDelete sets deleted_at of the row, it fails for tables without a deleted_at column and returns
+// ErrNotSoftDeleted when the row does not exist or is already deleted
+func (r *Repository[T]) SoftDelete(ctx context.Context, id any) error {
+	if !r.meta.soft {
+		return fmt.Errorf("%s has no deleted_at column", r.table)
+	}
+	deleteAndUpdate := time.Now().Format("2006-01-02 15:04:05")
+	values := map[string]any{"deleted_at": deleteAndUpdate}
+	if _, ok := r.meta.fields["updated_at"]; ok {
+		values["updated_at"] = deleteAndUpdate
+	}
+
+	builder := r.builder().Update(values).Where(r.meta.pk, "=", id).IsNull("deleted_at")
+	err := config.App().DB.QueryExec(ctx, builder)
+	if errors.Is(err, conn.ErrZeroAffected) {
+		return ErrNotSoftDeleted
+	}
+	return err
+}
+
+func (r *Repository[T]) builder() *gobuilder.GoBuilder {
+	return config.App().DB.Builder().Table(r.table)
+}
+
+func (r *Repository[T]) apply(builder *gobuilder.GoBuilder, scopes []Scope) *gobuilder.GoBuilder {
+	if r.meta.soft && !r.trashed {
+		builder = builder.IsNull("deleted_at")
+	}
+	for _, scope := range scopes {
+		builder = scope(builder)
+	}
+	return builder
+}
+
+// fieldMeta is the mapping of a struct field to a column, pointer and sql.Null* fields are nullable
+type fieldMeta struct {
+	index    []int
+	typ      reflect.Type
+	readonly bool
+	nullable bool
+}
+
+// modelMeta is the column mapping of a struct type, it is built once per type
+type modelMeta struct {
+	pk         string
+	soft       bool
+	columns    []string
+	fields     map[string]fieldMeta
+	sortable   map[string]bool
+	filterable map[string]bool
+	searchable []string
+}
+
+var metaCache sync.Map
+
+func metaOf(t reflect.Type) *modelMeta {
+	if cached, ok := metaCache.Load(t); ok {
+		return cached.(*modelMeta)
+	}
+	if t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("repository: %s is not a struct", t))
+	}
+
+	meta := &modelMeta{fields: make(map[string]fieldMeta), sortable: make(map[string]bool), filterable: make(map[string]bool)}
+	collectFields(t, nil, meta)
+	if meta.pk == "" {
+		if _, ok := meta.fields["id"]; ok {
+			meta.pk = "id"
+			f := meta.fields["id"]
+			f.readonly = true
+			meta.fields["id"] = f
+		}
+	}
+	_, meta.soft = meta.fields["deleted_at"]
+
+	cached, _ := metaCache.LoadOrStore(t, meta)
+	return cached.(*modelMeta)
+}
+
+func collectFields(t reflect.Type, parent []int, meta *modelMeta) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		index := append(append([]int{}, parent...), i)
+
+		tag := field.Tag.Get("db")
+		if tag == "-" {
+			continue
+		}
+		if tag == "" && field.Anonymous && field.Type.Kind() == reflect.Struct {
+			collectFields(field.Type, index, meta)
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = snakeCase(field.Name)
+		}
+
+		nullable := field.Type.Kind() == reflect.Pointer || reflect.PointerTo(field.Type).Implements(reflect.TypeFor[sql.Scanner]())
+		f := fieldMeta{index: index, typ: field.Type, nullable: nullable}
+		for _, opt := range strings.Split(opts, ",") {
+			switch opt {
+			case "pk":
+				meta.pk = name
+				f.readonly = true
+			case "readonly":
+				f.readonly = true
+			case "sort":
+				meta.sortable[name] = true
+			case "filter":
+				meta.filterable[name] = true
+			case "search":
+				meta.searchable = append(meta.searchable, name)
+			}
+		}
+
+		meta.columns = append(meta.columns, name)
+		meta.fields[name] = f
+	}
+}
+
+// values returns the writable columns of the struct value
+func (m *modelMeta) values(v reflect.Value) map[string]any {
+	values := make(map[string]any, len(m.fields))
+	for name, f := range m.fields {
+		if f.readonly {
+			continue
+		}
+		values[name] = v.FieldByIndex(f.index).Interface()
+	}
+	return values
+}
+
+// filterTimeLayouts are the layouts a time filter value is accepted in, a value without a zone is local time
+var filterTimeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02"}
+
+// parse converts a query string value to the type of the column field, pointers are parsed as their element.
+// Times are bound in the layout the rows are written with, other types are passed as strings.
+func (m *modelMeta) parse(column, value string) (any, error) {
+	t := m.fields[column].typ
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == reflect.TypeFor[time.Time]() {
+		for _, layout := range filterTimeLayouts {
+			if parsed, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+				return parsed.In(time.Local).Format("2006-01-02 15:04:05.999999999"), nil
+			}
+		}
+		return nil, fmt.Errorf("invalid time %q", value)
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return strconv.ParseBool(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.ParseInt(value, 10, 64)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.ParseUint(value, 10, 64)
+	case reflect.Float32, reflect.Float64:
+		return strconv.ParseFloat(value, 64)
+	}
+	return value, nil
+}
+
+// cursorValue converts a value of a decoded cursor back to the type of the column field. Times are bound in the
+// layout the rows are written with, SQLite compares them as text and an RFC 3339 time would not match.
+func (m *modelMeta) cursorValue(column string, value any) (any, error) {
+	t := m.fields[column].typ
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case json.Number:
+		return m.parse(column, v.String())
+	case string:
+		if t != reflect.TypeFor[time.Time]() {
+			return v, nil
+		}
+		parsed, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return nil, err
+		}
+		return parsed.Format("2006-01-02 15:04:05.999999999"), nil
+	}
+	return value, nil
+}
+
+// scanRows scans every row into a new T, columns the model does not have are discarded
+func scanRows[T any](rows *sql.Rows, meta *modelMeta) ([]T, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	items := []T{}
+	for rows.Next() {
+		var item T
+		value := reflect.ValueOf(&item).Elem()
+
+		fieldPointers := make([]any, len(columns))
+		for i, column := range columns {
+			if f, ok := meta.fields[column]; ok {
+				fieldPointers[i] = value.FieldByIndex(f.index).Addr().Interface()
+			} else {
+				var dummy any
+				fieldPointers[i] = &dummy
+			}
+		}
+
+		if err := rows.Scan(fieldPointers...); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// snakeCase converts a Go field name to a column name, e.g. LastLogin to last_login and UserID to user_id
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
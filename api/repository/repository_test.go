@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mstgnz/starter-kit/api/infra/testdb"
+	"github.com/mstgnz/starter-kit/api/model"
+)
+
+// TestSoftDelete checks that a missing or deleted row gives ErrNotSoftDeleted and a database error is returned as is
+func TestSoftDelete(t *testing.T) {
+	db := testdb.Open(t)
+	ctx := context.Background()
+	users := NewUserRepository()
+	create := func(email string) *model.User {
+		t.Helper()
+		user, err := users.Create(ctx, &model.Register{Fullname: "Soft", Email: email, Password: "secret"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return user
+	}
+
+	user := create("soft@repository.test")
+	if err := users.users.SoftDelete(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := users.users.Find(ctx, user.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("find of a deleted user %v, want sql.ErrNoRows", err)
+	}
+	if err := users.users.SoftDelete(ctx, user.ID); !errors.Is(err, ErrNotSoftDeleted) {
+		t.Fatalf("second delete %v, want ErrNotSoftDeleted", err)
+	}
+	if err := users.users.SoftDelete(ctx, -1); !errors.Is(err, ErrNotSoftDeleted) {
+		t.Fatalf("delete of a missing user %v, want ErrNotSoftDeleted", err)
+	}
+
+	failing := create("soft-fail@repository.test")
+	trigger := fmt.Sprintf("CREATE TRIGGER soft_delete_test BEFORE UPDATE ON users WHEN NEW.id = %d BEGIN SELECT RAISE(ABORT, 'soft delete failed'); END", failing.ID)
+	if _, err := db.ExecContext(ctx, trigger); err != nil {
+		t.Fatal(err)
+	}
+	err := users.users.SoftDelete(ctx, failing.ID)
+	if _, dropErr := db.ExecContext(ctx, "DROP TRIGGER soft_delete_test"); dropErr != nil {
+		t.Fatal(dropErr)
+	}
+	if err == nil || errors.Is(err, ErrNotSoftDeleted) || !strings.Contains(err.Error(), "soft delete failed") {
+		t.Fatalf("delete with a database error %v, want the error of the database", err)
+	}
+}
@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/mstgnz/starter-kit/api/infra/config"
+)
+
+type roleRepository struct {
+}
+
+func NewRoleRepository() *roleRepository {
+	return &roleRepository{}
+}
+
+func (r *roleRepository) Roles(ctx context.Context, userId int) ([]string, error) {
+	return r.names(ctx, "USER_ROLES", userId)
+}
+
+func (r *roleRepository) Permissions(ctx context.Context, userId int) ([]string, error) {
+	return r.names(ctx, "USER_PERMISSIONS", userId)
+}
+
+// Exists reports whether the role is defined
+func (r *roleRepository) Exists(ctx context.Context, role string) (bool, error) {
+	query, err := config.App().QUERY.Get("ROLE_EXISTS")
+	if err != nil {
+		return false, err
+	}
+
+	var exists bool
+	err = config.App().DB.QueryRowContext(ctx, query, role).Scan(&exists)
+	return exists, err
+}
+
+func (r *roleRepository) Assign(ctx context.Context, userId int, role string) error {
+	query, err := config.App().QUERY.Get("USER_ROLE_ASSIGN")
+	if err != nil {
+		return err
+	}
+
+	_, err = config.App().DB.ExecContext(ctx, query, userId, role)
+	return err
+}
+
+// Revoke takes the role from the user, it returns sql.ErrNoRows when the user does not have the role
+func (r *roleRepository) Revoke(ctx context.Context, userId int, role string) error {
+	query, err := config.App().QUERY.Get("USER_ROLE_REVOKE")
+	if err != nil {
+		return err
+	}
+
+	result, err := config.App().DB.ExecContext(ctx, query, userId, role)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// GrantAllPermissions gives the role every permission defined in the permissions table
+func (r *roleRepository) GrantAllPermissions(ctx context.Context, role string) error {
+	query, err := config.App().QUERY.Get("ROLE_GRANT_ALL_PERMISSIONS")
+	if err != nil {
+		return err
+	}
+
+	_, err = config.App().DB.ExecContext(ctx, query, role)
+	return err
+}
+
+func (r *roleRepository) names(ctx context.Context, name string, userId int) ([]string, error) {
+	names := []string{}
+
+	query, err := config.App().QUERY.Get(name)
+	if err != nil {
+		return names, err
+	}
+
+	rows, err := config.App().DB.QueryContext(ctx, query, userId)
+	if err != nil {
+		return names, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return names, err
+		}
+		names = append(names, name)
+	}
+
+	return names, rows.Err()
+}
@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/mstgnz/starter-kit/api/infra/config"
+	"github.com/mstgnz/starter-kit/api/model"
+)
+
+type sessionRepository struct {
+}
+
+func NewSessionRepository() *sessionRepository {
+	return &sessionRepository{}
+}
+
+func (r *sessionRepository) Create(ctx context.Context, session *model.Session) error {
+	query, err := config.App().QUERY.Get("SESSION_INSERT")
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Format("2006-01-02 15:04:05")
+	_, err = config.App().DB.ExecContext(ctx, query, session.UserID, session.Family, session.Device, session.UserAgent, session.IP, now)
+	return err
+}
+
+// Get returns the active session of the user, sql.ErrNoRows if there is none
+func (r *sessionRepository) Get(ctx context.Context, id, userId int) (*model.Session, error) {
+	query, err := config.App().QUERY.Get("SESSION_GET")
+	if err != nil {
+		return nil, err
+	}
+	return scanSession(config.App().DB.QueryRowContext(ctx, query, id, userId))
+}
+
+// GetWithFamily returns the active session of the token family, sql.ErrNoRows if there is none
+func (r *sessionRepository) GetWithFamily(ctx context.Context, family string) (*model.Session, error) {
+	query, err := config.App().QUERY.Get("SESSION_GET_WITH_FAMILY")
+	if err != nil {
+		return nil, err
+	}
+	return scanSession(config.App().DB.QueryRowContext(ctx, query, family))
+}
+
+// List returns the active sessions of the user seen after the given time, the most recently seen first
+func (r *sessionRepository) List(ctx context.Context, userId int, seenAfter time.Time) ([]*model.Session, error) {
+	query, err := config.App().QUERY.Get("SESSION_LIST")
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := config.App().DB.QueryContext(ctx, query, userId, seenAfter.Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	sessions := []*model.Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// Families returns the token families of the active sessions of the user
+func (r *sessionRepository) Families(ctx context.Context, userId int) ([]string, error) {
+	query, err := config.App().QUERY.Get("SESSION_FAMILIES")
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := config.App().DB.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	families := []string{}
+	for rows.Next() {
+		var family string
+		if err := rows.Scan(&family); err != nil {
+			return nil, err
+		}
+		families = append(families, family)
+	}
+	return families, rows.Err()
+}
+
+// Touch sets the last seen time of the session and the ip when it is known
+func (r *sessionRepository) Touch(ctx context.Context, family, ip string) error {
+	query, err := config.App().QUERY.Get("SESSION_TOUCH")
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Format("2006-01-02 15:04:05")
+	_, err = config.App().DB.ExecContext(ctx, query, now, ip, family)
+	return err
+}
+
+func (r *sessionRepository) Revoke(ctx context.Context, family string) error {
+	query, err := config.App().QUERY.Get("SESSION_REVOKE")
+	if err != nil {
+		return err
+	}
+
+	revokedAt := time.Now().Format("2006-01-02 15:04:05")
+	_, err = config.App().DB.ExecContext(ctx, query, revokedAt, family)
+	return err
+}
+
+func scanSession(row interface{ Scan(dest ...any) error }) (*model.Session, error) {
+	session := &model.Session{}
+	err := row.Scan(&session.ID, &session.UserID, &session.Family, &session.Device, &session.UserAgent, &session.IP, &session.CreatedAt, &session.LastSeenAt)
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
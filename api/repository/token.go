@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/mstgnz/starter-kit/api/infra/config"
+	"github.com/mstgnz/starter-kit/api/model"
+)
+
+type refreshTokenRepository struct {
+}
+
+func NewRefreshTokenRepository() *refreshTokenRepository {
+	return &refreshTokenRepository{}
+}
+
+func (r *refreshTokenRepository) Create(ctx context.Context, userId int, family, tokenHash string, expiresAt time.Time) error {
+	query, err := config.App().QUERY.Get("REFRESH_TOKEN_INSERT")
+	if err != nil {
+		return err
+	}
+
+	_, err = config.App().DB.ExecContext(ctx, query, userId, family, tokenHash, expiresAt.Format("2006-01-02 15:04:05"))
+	return err
+}
+
+// Use marks an active refresh token as used and returns its owner and family.
+// It returns sql.ErrNoRows if the token is unknown, expired, revoked or already used.
+func (r *refreshTokenRepository) Use(ctx context.Context, tokenHash string) (int, string, error) {
+	var userId int
+	var family string
+
+	query, err := config.App().QUERY.Get("REFRESH_TOKEN_USE")
+	if err != nil {
+		return userId, family, err
+	}
+
+	usedAt := time.Now().Format("2006-01-02 15:04:05")
+	err = config.App().DB.QueryRowContext(ctx, query, usedAt, tokenHash).Scan(&userId, &family)
+	return userId, family, err
+}
+
+func (r *refreshTokenRepository) GetWithHash(ctx context.Context, tokenHash string) (*model.RefreshToken, error) {
+	query, err := config.App().QUERY.Get("REFRESH_TOKEN_GET_WITH_HASH")
+	if err != nil {
+		return nil, err
+	}
+
+	token := &model.RefreshToken{TokenHash: tokenHash}
+	err = config.App().DB.QueryRowContext(ctx, query, tokenHash).Scan(&token.ID, &token.UserID, &token.Family, &token.ExpiresAt, &token.UsedAt, &token.RevokedAt, &token.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// RevokeUser revokes the refresh tokens of every family of the user
+func (r *refreshTokenRepository) RevokeUser(ctx context.Context, userId int) error {
+	query, err := config.App().QUERY.Get("REFRESH_TOKEN_REVOKE_USER")
+	if err != nil {
+		return err
+	}
+
+	revokedAt := time.Now().Format("2006-01-02 15:04:05")
+	_, err = config.App().DB.ExecContext(ctx, query, revokedAt, userId)
+	return err
+}
+
+func (r *refreshTokenRepository) RevokeFamily(ctx context.Context, family string) error {
+	query, err := config.App().QUERY.Get("REFRESH_TOKEN_REVOKE_FAMILY")
+	if err != nil {
+		return err
+	}
+
+	revokedAt := time.Now().Format("2006-01-02 15:04:05")
+	_, err = config.App().DB.ExecContext(ctx, query, revokedAt, family)
+	return err
+}
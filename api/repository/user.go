@@ -2,225 +2,92 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"time"
 
 	"github.com/mstgnz/starter-kit/api/infra/auth"
 	"github.com/mstgnz/starter-kit/api/infra/config"
+	"github.com/mstgnz/starter-kit/api/infra/paginate"
 	"github.com/mstgnz/starter-kit/api/model"
+	"github.com/mstgnz/starter-kit/api/repository/queries"
 )
 
 type userRepository struct {
+	users *Repository[model.User]
 }
 
 func NewUserRepository() *userRepository {
-	return &userRepository{}
-}
-
-func (r *userRepository) Count(ctx context.Context) int {
-	rowCount := 0
-
-	// prepare count
-	stmt, err := config.App().DB.PrepareContext(ctx, config.App().QUERY["USERS_COUNT"])
-	if err != nil {
-		return rowCount
-	}
-
-	// query
-	rows, err := stmt.QueryContext(ctx)
-	if err != nil {
-		return rowCount
+	return &userRepository{
+		users: NewRepository[model.User]("users"),
 	}
-	defer func() {
-		_ = stmt.Close()
-		_ = rows.Close()
-	}()
-	for rows.Next() {
-		if err := rows.Scan(&rowCount); err != nil {
-			return rowCount
-		}
-	}
-
-	return rowCount
 }
 
-func (r *userRepository) Get(ctx context.Context, offset, limit int, search string) []*model.User {
-	users := []*model.User{}
-
-	// prepare users paginate
-	stmt, err := config.App().DB.PrepareContext(ctx, config.App().QUERY["USERS_PAGINATE"])
-	if err != nil {
-		return users
+// Paginate returns a page of users, ?q= searches the name, email and phone
+func (r *userRepository) Paginate(ctx context.Context, list *model.UserList) (*paginate.Page[model.User], error) {
+	users := r.users
+	if list.Trashed {
+		users = users.WithTrashed()
 	}
+	return users.Paginate(ctx, &list.Params)
+}
 
-	// query
-	rows, err := stmt.QueryContext(ctx, "%"+search+"%", offset, limit)
-	if err != nil {
-		return users
+// Find returns the user with every column, deactivated users are only returned when trashed is true
+func (r *userRepository) Find(ctx context.Context, id int, trashed bool) (*model.User, error) {
+	users := r.users
+	if trashed {
+		users = users.WithTrashed()
 	}
-	defer func() {
-		_ = stmt.Close()
-		_ = rows.Close()
-	}()
-	for rows.Next() {
-		user := &model.User{}
-		if err := rows.Scan(&user.ID, &user.Fullname, &user.Email, &user.Password, &user.Phone, &user.IsAdmin, &user.Active, &user.LastLogin, &user.CreatedAt, &user.UpdatedAt, &user.DeletedAt); err != nil {
-			return users
-		}
-		users = append(users, user)
-	}
-
-	return users
+	return users.Find(ctx, id)
 }
 
 func (r *userRepository) Create(ctx context.Context, register *model.Register) (*model.User, error) {
-
-	stmt, err := config.App().DB.PrepareContext(ctx, config.App().QUERY["USER_INSERT"])
-	if err != nil {
-		return nil, err
-	}
-
-	user := &model.User{}
-	hashPass := auth.HashAndSalt(register.Password)
-	err = stmt.QueryRowContext(ctx, register.Fullname, register.Email, hashPass, register.Phone).Scan(&user.ID, &user.Fullname, &user.Email, &user.Phone)
+	row, err := queries.UserInsert(ctx, config.App().DB, register.Fullname, register.Email, auth.HashAndSalt(register.Password), register.Phone)
 	if err != nil {
 		return nil, err
 	}
 
-	return user, nil
+	return &model.User{ID: row.ID, Fullname: row.Fullname, Email: row.Email, Phone: row.Phone}, nil
 }
 
 func (r *userRepository) Exists(ctx context.Context, email string) (bool, error) {
-	exists := 0
-
-	// prepare
-	stmt, err := config.App().DB.PrepareContext(ctx, config.App().QUERY["USER_EXISTS_WITH_EMAIL"])
-	if err != nil {
-		return false, err
-	}
-
-	// query
-	rows, err := stmt.QueryContext(ctx, email)
+	exists, err := queries.UserExistsWithEmail(ctx, config.App().DB, email)
 	if err != nil {
 		return false, err
 	}
-	defer func() {
-		_ = stmt.Close()
-		_ = rows.Close()
-	}()
-	for rows.Next() {
-		if err := rows.Scan(&exists); err != nil {
-			return false, err
-		}
-	}
-	return exists > 0, nil
-}
-
-func (r *userRepository) IDExists(ctx context.Context, id int) (bool, error) {
-	exists := 0
-
-	// prepare
-	stmt, err := config.App().DB.PrepareContext(ctx, config.App().QUERY["USER_EXISTS_WITH_ID"])
-	if err != nil {
-		return false, err
-	}
-
-	// query
-	rows, err := stmt.QueryContext(ctx, id)
-	if err != nil {
-		return false, err
-	}
-	defer func() {
-		_ = stmt.Close()
-		_ = rows.Close()
-	}()
-	for rows.Next() {
-		if err := rows.Scan(&exists); err != nil {
-			return false, err
-		}
-	}
 	return exists > 0, nil
 }
 
 func (r *userRepository) GetWithId(ctx context.Context, id int) (*model.User, error) {
-
-	stmt, err := config.App().DB.PrepareContext(ctx, config.App().QUERY["USER_GET_WITH_ID"])
-	if err != nil {
-		return nil, err
+	row, err := queries.UserGetWithID(ctx, config.App().DB, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("user not found")
 	}
-
-	rows, err := stmt.QueryContext(ctx, id)
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
-		_ = stmt.Close()
-		_ = rows.Close()
-	}()
-
-	found := false
-	user := &model.User{}
-	for rows.Next() {
-		if err := rows.Scan(&user.ID, &user.Fullname, &user.Email, &user.IsAdmin, &user.Password); err != nil {
-			return nil, err
-		}
-		found = true
-	}
-
-	if !found {
-		return nil, errors.New("user not found")
-	}
 
-	return user, nil
+	return &model.User{ID: row.ID, Fullname: row.Fullname, Email: row.Email, IsAdmin: row.IsAdmin, Active: row.Active, Password: row.Password}, nil
 }
 
 func (r *userRepository) GetWithMail(ctx context.Context, email string) (*model.User, error) {
-
-	stmt, err := config.App().DB.PrepareContext(ctx, config.App().QUERY["USER_GET_WITH_EMAIL"])
-	if err != nil {
-		return nil, err
+	row, err := queries.UserGetWithEmail(ctx, config.App().DB, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("user not found")
 	}
-
-	rows, err := stmt.QueryContext(ctx, email)
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
-		_ = stmt.Close()
-		_ = rows.Close()
-	}()
-
-	found := false
-	user := &model.User{}
-	for rows.Next() {
-		if err := rows.Scan(&user.ID, &user.Fullname, &user.Email, &user.IsAdmin, &user.Password); err != nil {
-			return nil, err
-		}
-		found = true
-	}
-
-	if !found {
-		return nil, errors.New("user not found")
-	}
 
-	return user, nil
+	return &model.User{ID: row.ID, Fullname: row.Fullname, Email: row.Email, IsAdmin: row.IsAdmin, Active: row.Active, Password: row.Password}, nil
 }
 
 func (r *userRepository) ProfileUpdate(ctx context.Context, query string, params []any) error {
 
-	stmt, err := config.App().DB.PrepareContext(ctx, query)
+	result, err := config.App().DB.ExecContext(ctx, query, params...)
 	if err != nil {
 		return err
 	}
-
-	result, err := stmt.ExecContext(ctx, params...)
-	if err != nil {
-		return err
-	}
-	defer func() {
-		_ = stmt.Close()
-	}()
-
 	affected, err := result.RowsAffected()
 	if err != nil {
 		return err
@@ -234,22 +101,8 @@ func (r *userRepository) ProfileUpdate(ctx context.Context, query string, params
 }
 
 func (r *userRepository) PasswordUpdate(ctx context.Context, password string, userId int) error {
-	stmt, err := config.App().DB.PrepareContext(ctx, config.App().QUERY["USER_UPDATE_PASS"])
-	if err != nil {
-		return err
-	}
-
 	updateAt := time.Now().Format("2006-01-02 15:04:05")
-	hashPass := auth.HashAndSalt(password)
-	result, err := stmt.ExecContext(ctx, hashPass, updateAt, userId)
-	if err != nil {
-		return err
-	}
-	defer func() {
-		_ = stmt.Close()
-	}()
-
-	affected, err := result.RowsAffected()
+	affected, err := queries.UserUpdatePass(ctx, config.App().DB, auth.HashAndSalt(password), updateAt, userId)
 	if err != nil {
 		return err
 	}
@@ -261,23 +114,24 @@ func (r *userRepository) PasswordUpdate(ctx context.Context, password string, us
 	return nil
 }
 
-func (r *userRepository) LastLoginUpdate(ctx context.Context, userId int) error {
-	lastLogin := time.Now().Format("2006-01-02 15:04:05")
-
-	stmt, err := config.App().DB.PrepareContext(ctx, config.App().QUERY["USER_LAST_LOGIN"])
+// AdminUpdate sets whether the user is an admin, it follows the admin role of the user
+func (r *userRepository) AdminUpdate(ctx context.Context, userId int, isAdmin bool) error {
+	updateAt := time.Now().Format("2006-01-02 15:04:05")
+	affected, err := queries.UserAdminUpdate(ctx, config.App().DB, isAdmin, updateAt, userId)
 	if err != nil {
 		return err
 	}
 
-	result, err := stmt.ExecContext(ctx, lastLogin, userId)
-	if err != nil {
-		return err
+	if affected == 0 {
+		return errors.New("user admin not updated")
 	}
-	defer func() {
-		_ = stmt.Close()
-	}()
 
-	affected, err := result.RowsAffected()
+	return nil
+}
+
+func (r *userRepository) LastLoginUpdate(ctx context.Context, userId int) error {
+	lastLogin := time.Now().Format("2006-01-02 15:04:05")
+	affected, err := queries.UserLastLogin(ctx, config.App().DB, lastLogin, userId)
 	if err != nil {
 		return err
 	}
@@ -289,28 +143,34 @@ func (r *userRepository) LastLoginUpdate(ctx context.Context, userId int) error
 }
 
 func (r *userRepository) Delete(ctx context.Context, userID int) error {
-	stmt, err := config.App().DB.PrepareContext(ctx, config.App().QUERY["USER_DELETE"])
+	deleteAndUpdate := time.Now().Format("2006-01-02 15:04:05")
+	affected, err := queries.UserDelete(ctx, config.App().DB, false, deleteAndUpdate, deleteAndUpdate, userID)
 	if err != nil {
 		return err
 	}
 
-	deleteAndUpdate := time.Now().Format("2006-01-02 15:04:05")
-
-	result, err := stmt.ExecContext(ctx, false, deleteAndUpdate, deleteAndUpdate, userID)
-	if err != nil {
-		return err
+	if affected == 0 {
+		return errors.New("user not deleted")
 	}
-	defer func() {
-		_ = stmt.Close()
-	}()
 
-	affected, err := result.RowsAffected()
+	return nil
+}
+
+func (r *userRepository) VerifyEmail(ctx context.Context, userID int) error {
+	verifiedAt := time.Now().Format("2006-01-02 15:04:05")
+	_, err := queries.UserVerifyEmail(ctx, config.App().DB, verifiedAt, userID)
+	return err
+}
+
+func (r *userRepository) Restore(ctx context.Context, userID int) error {
+	updateAt := time.Now().Format("2006-01-02 15:04:05")
+	affected, err := queries.UserRestore(ctx, config.App().DB, true, updateAt, userID)
 	if err != nil {
 		return err
 	}
 
 	if affected == 0 {
-		return errors.New("user not deleted")
+		return errors.New("user not restored")
 	}
 
 	return nil
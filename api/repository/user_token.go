@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/mstgnz/starter-kit/api/infra/config"
+)
+
+// userTokenRepository stores the hashes of the single use tokens mailed to users, such as password reset tokens
+type userTokenRepository struct {
+}
+
+func NewUserTokenRepository() *userTokenRepository {
+	return &userTokenRepository{}
+}
+
+func (r *userTokenRepository) Create(ctx context.Context, userId int, purpose, tokenHash string, expiresAt time.Time) error {
+	query, err := config.App().QUERY.Get("USER_TOKEN_INSERT")
+	if err != nil {
+		return err
+	}
+
+	_, err = config.App().DB.ExecContext(ctx, query, userId, purpose, tokenHash, expiresAt.Format("2006-01-02 15:04:05"))
+	return err
+}
+
+// Use marks an unused token of the purpose as used and returns its owner.
+// It returns sql.ErrNoRows if the token is unknown, expired or already used.
+func (r *userTokenRepository) Use(ctx context.Context, tokenHash, purpose string) (int, error) {
+	var userId int
+
+	query, err := config.App().QUERY.Get("USER_TOKEN_USE")
+	if err != nil {
+		return userId, err
+	}
+
+	usedAt := time.Now().Format("2006-01-02 15:04:05")
+	err = config.App().DB.QueryRowContext(ctx, query, usedAt, tokenHash, purpose).Scan(&userId)
+	return userId, err
+}
+
+// DeleteUnused deletes the unused tokens of the user for the purpose, so only the last mailed token works
+func (r *userTokenRepository) DeleteUnused(ctx context.Context, userId int, purpose string) error {
+	query, err := config.App().QUERY.Get("USER_TOKEN_DELETE_UNUSED")
+	if err != nil {
+		return err
+	}
+
+	_, err = config.App().DB.ExecContext(ctx, query, userId, purpose)
+	return err
+}
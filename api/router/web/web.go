@@ -9,14 +9,67 @@ import (
 )
 
 var (
-	userHandler = handler.NewUserHandler()
+	userHandler    = handler.NewUserHandler()
+	roleHandler    = handler.NewRoleHandler()
+	apiKeyHandler  = handler.NewAPIKeyHandler()
+	accountHandler = handler.NewAccountHandler()
+	mfaHandler     = handler.NewMFAHandler()
+	sessionHandler = handler.NewSessionHandler()
+	oauthHandler   = handler.NewOAuthHandler()
 )
 
 func WebRoutes(r chi.Router) {
 	r.Group(func(r chi.Router) {
 		r.Use(middle.AuthMiddleware)
 		r.Get("/verify", config.Catch(handle.Handle(userHandler.Verify)))
+
+		// self service routes need a login, an api key is rejected whatever its scopes
+		r.Group(func(r chi.Router) {
+			r.Use(middle.DenyAPIKey)
+			r.Post("/logout", config.Catch(handle.Handle(userHandler.Logout)))
+
+			r.Get("/me", config.Catch(handle.Handle(userHandler.Me)))
+			r.Patch("/me", config.Catch(handle.Handle(userHandler.UpdateMe)))
+			r.Put("/me/password", config.Catch(handle.Handle(userHandler.ChangePassword)))
+			r.With(middle.RateLimitMiddleware(middle.StrictRateLimitConfig())).Post("/email/verify/resend", config.Catch(handle.Handle(accountHandler.ResendVerification)))
+
+			r.Get("/me/sessions", config.Catch(handle.Handle(sessionHandler.List)))
+			r.Delete("/me/sessions", config.Catch(handle.Handle(sessionHandler.RevokeOthers)))
+			r.Delete("/me/sessions/{id}", config.Catch(handle.Handle(sessionHandler.Revoke)))
+
+			r.Post("/me/mfa", config.Catch(handle.Handle(mfaHandler.Enroll)))
+			r.With(middle.RateLimitMiddleware(middle.StrictRateLimitConfig())).Post("/me/mfa/confirm", config.Catch(handle.Handle(mfaHandler.Confirm)))
+			r.With(middle.RateLimitMiddleware(middle.StrictRateLimitConfig())).Delete("/me/mfa", config.Catch(handle.Handle(mfaHandler.Disable)))
+
+			r.Get("/api-keys", config.Catch(handle.Handle(apiKeyHandler.List)))
+			r.Post("/api-keys", config.Catch(handle.Handle(apiKeyHandler.Create)))
+			r.Delete("/api-keys/{id}", config.Catch(handle.Handle(apiKeyHandler.Revoke)))
+		})
+
+		r.With(middle.RequirePermission("users.read")).Get("/users", config.Catch(handle.Handle(userHandler.List)))
+		r.With(middle.RequirePermission("users.read")).Get("/users/{id}", config.Catch(handle.Handle(userHandler.Get)))
+		r.With(middle.RequirePermission("users.update")).Patch("/users/{id}", config.Catch(handle.Handle(userHandler.Update)))
+		r.With(middle.RequirePermission("users.delete")).Delete("/users/{id}", config.Catch(handle.Handle(userHandler.Deactivate)))
+		r.With(middle.RequirePermission("users.delete")).Post("/users/{id}/restore", config.Catch(handle.Handle(userHandler.Restore)))
+		r.With(middle.RequirePermission("users.update")).Post("/users/{id}/unlock", config.Catch(handle.Handle(userHandler.Unlock)))
+
+		r.With(middle.RequirePermission("roles.read")).Get("/users/{id}/access", config.Catch(handle.Handle(roleHandler.Access)))
+		r.With(middle.RequirePermission("roles.assign")).Post("/users/{id}/roles", config.Catch(handle.Handle(roleHandler.Assign)))
+		r.With(middle.RequirePermission("roles.assign")).Delete("/users/{id}/roles/{role}", config.Catch(handle.Handle(roleHandler.Revoke)))
 	})
 	r.Post("/login", config.Catch(handle.Handle(userHandler.Login)))
 	r.Post("/register", config.Catch(handle.Handle(userHandler.Register)))
+	r.Post("/refresh", config.Catch(handle.Handle(userHandler.Refresh)))
+	r.Get("/oauth/providers", config.Catch(handle.Handle(oauthHandler.Providers)))
+
+	// mailed links, two factor codes and provider logins, limited per ip so tokens and codes cannot be guessed and inboxes flooded
+	r.Group(func(r chi.Router) {
+		r.Use(middle.RateLimitMiddleware(middle.StrictRateLimitConfig()))
+		r.Post("/password/forgot", config.Catch(handle.Handle(accountHandler.ForgotPassword)))
+		r.Post("/password/reset", config.Catch(handle.Handle(accountHandler.ResetPassword)))
+		r.Post("/login/mfa", config.Catch(handle.Handle(mfaHandler.Login)))
+		r.Get("/email/verify", config.Catch(handle.Handle(accountHandler.VerifyEmail)))
+		r.Get("/oauth/{provider}/start", config.Catch(handle.Handle(oauthHandler.Start)))
+		r.Get("/oauth/{provider}/callback", config.Catch(handle.Handle(oauthHandler.Callback)))
+	})
 }
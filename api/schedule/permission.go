@@ -0,0 +1,19 @@
+package schedule
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/mstgnz/starter-kit/api/service"
+)
+
+// SetPermissionForAdmin grants newly added permissions to the admin role
+func SetPermissionForAdmin() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if err := service.NewRoleService().GrantAllPermissions(ctx, service.AdminRole); err != nil {
+		log.Println("SetPermissionForAdmin", err)
+	}
+}
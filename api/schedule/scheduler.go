@@ -31,14 +31,24 @@ func CallSchedule(ctx context.Context, c *cron.Cron) {
 		log.Println("AddFunc SetTableColumn", err)
 	}
 
-	// Set Permission For Center Admin
+	// Set Permission For Admin
 	// At 03:00 on day-of-month 1.
 	if _, err = c.AddFunc("0 3 1 * *", func() {
 		config.ShuttingWrapper(func() {
-			//SetPermissionForCenterAdmin()
+			config.IncrementRunning()
+			defer config.DecrementRunning()
+			SetPermissionForAdmin()
 		})
 
 	}); err != nil {
-		log.Println("AddFunc SetPermissionForCenterAdmin", err)
+		log.Println("AddFunc SetPermissionForAdmin", err)
+	}
+
+	// Log Statement Cache Stats
+	// At every 15th minute.
+	if _, err = c.AddFunc("*/15 * * * *", func() {
+		config.ShuttingWrapper(LogStmtStats)
+	}); err != nil {
+		log.Println("AddFunc LogStmtStats", err)
 	}
 }
@@ -0,0 +1,20 @@
+package schedule
+
+import (
+	"log"
+
+	"github.com/mstgnz/starter-kit/api/infra/config"
+)
+
+// LogStmtStats logs the prepared statement cache counters of every database, they are the numbers to tune
+// DB_STMT_CACHE_SIZE with. Databases without a cache are skipped.
+func LogStmtStats() {
+	if stats := config.App().DB.StmtStats(); stats.Capacity > 0 {
+		log.Printf("Statement cache: %s", stats)
+	}
+	for name, db := range config.App().DBs {
+		if stats := db.StmtStats(); stats.Capacity > 0 {
+			log.Printf("Statement cache %s: %s", name, stats)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/mstgnz/starter-kit/api/infra/auth"
+	"github.com/mstgnz/starter-kit/api/infra/config"
+	"github.com/mstgnz/starter-kit/api/model"
+	"github.com/mstgnz/starter-kit/api/repository"
+)
+
+// Purposes of the single use tokens mailed to users
+const (
+	TokenPasswordReset = "password_reset"
+	TokenEmailVerify   = "email_verify"
+)
+
+var (
+	// PasswordResetTTL is how long a password reset link works
+	PasswordResetTTL = time.Hour
+	// EmailVerifyTTL is how long an email verification link works
+	EmailVerifyTTL = 24 * time.Hour
+	// MailThrottle is the minimum time between two mails of the same purpose to the same user
+	MailThrottle = time.Minute
+)
+
+var (
+	ErrInvalidToken  = errors.New("invalid or expired token")
+	ErrEmailVerified = errors.New("email is already verified")
+
+	userTokenRepository = repository.NewUserTokenRepository()
+	mailer              = NewMailService()
+)
+
+type accountService struct {
+}
+
+func NewAccountService() *accountService {
+	return &accountService{}
+}
+
+// ForgotPassword mails a password reset link to the user of the email. Unknown emails are ignored
+// so the response does not tell whether an account exists.
+func (s *accountService) ForgotPassword(ctx context.Context, email string) error {
+	user, err := userRepository.GetWithMail(ctx, email)
+	if err != nil {
+		return nil
+	}
+
+	link := os.Getenv("APP_URL") + "/reset-password?token="
+	return s.mailToken(ctx, user, TokenPasswordReset, PasswordResetTTL, link, "password_reset")
+}
+
+// ResetPassword sets the password of the owner of the token, unlocks the account and ends every session of the user,
+// the token and the other reset links of the user stop working
+func (s *accountService) ResetPassword(ctx context.Context, reset *model.PasswordReset) error {
+	var families []string
+	err := config.App().DB.WithTx(ctx, func(ctx context.Context) error {
+		userId, err := userTokenRepository.Use(ctx, auth.HashToken(reset.Token), TokenPasswordReset)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidToken
+		}
+		if err != nil {
+			return err
+		}
+		if err := userRepository.PasswordUpdate(ctx, reset.Password, userId); err != nil {
+			return err
+		}
+		// the owner of the email is allowed to log in again
+		if _, err := lockoutRepository.Delete(ctx, userId); err != nil {
+			return err
+		}
+		if families, err = NewTokenService().revokeUser(ctx, userId); err != nil {
+			return err
+		}
+		return userTokenRepository.DeleteUnused(ctx, userId, TokenPasswordReset)
+	})
+	if err != nil {
+		return err
+	}
+	return markRevoked(families...)
+}
+
+// SendVerification mails an email verification link to the user
+func (s *accountService) SendVerification(ctx context.Context, userId int) error {
+	user, err := userRepository.Find(ctx, userId, false)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if user.EmailVerifiedAt != nil {
+		return ErrEmailVerified
+	}
+
+	link := os.Getenv("API_URL") + "/v1/email/verify?token="
+	return s.mailToken(ctx, user, TokenEmailVerify, EmailVerifyTTL, link, "verify_email")
+}
+
+// VerifyEmail marks the email of the owner of the token as verified
+func (s *accountService) VerifyEmail(ctx context.Context, token string) error {
+	userId, err := userTokenRepository.Use(ctx, auth.HashToken(token), TokenEmailVerify)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrInvalidToken
+	}
+	if err != nil {
+		return err
+	}
+	return userRepository.VerifyEmail(ctx, userId)
+}
+
+// mailToken replaces the unused tokens of the purpose with a new one and mails the link with the token,
+// a user gets at most one mail of a purpose per MailThrottle
+func (s *accountService) mailToken(ctx context.Context, user *model.User, purpose string, ttl time.Duration, link, template string) error {
+	throttle := []byte(fmt.Sprintf("mail:%s:%d", purpose, user.ID))
+	if config.App().Cache.Has(throttle) {
+		return nil
+	}
+
+	token := auth.RandomHex(32)
+	err := config.App().DB.WithTx(ctx, func(ctx context.Context) error {
+		if err := userTokenRepository.DeleteUnused(ctx, user.ID, purpose); err != nil {
+			return err
+		}
+		return userTokenRepository.Create(ctx, user.ID, purpose, auth.HashToken(token), time.Now().Add(ttl))
+	})
+	if err != nil {
+		return err
+	}
+	_ = config.App().Cache.Set(throttle, []byte{1}, MailThrottle)
+
+	return mailer.Send(user.Email, user.Fullname, template, map[string]any{
+		"Link": link + url.QueryEscape(token),
+		"TTL":  humanDuration(ttl),
+	})
+}
+
+// humanDuration formats whole hours as hours and shorter durations as minutes, e.g. "24 hours"
+func humanDuration(d time.Duration) string {
+	value, unit := int(d.Minutes()), "minute"
+	if d >= time.Hour && d%time.Hour == 0 {
+		value, unit = int(d.Hours()), "hour"
+	}
+	if value != 1 {
+		unit += "s"
+	}
+	return fmt.Sprintf("%d %s", value, unit)
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/mstgnz/starter-kit/api/infra/auth"
+	"github.com/mstgnz/starter-kit/api/model"
+	"github.com/mstgnz/starter-kit/api/repository"
+)
+
+// APIKeyPrefix marks the keys issued by this api
+var APIKeyPrefix = "sk_"
+
+var (
+	ErrInvalidAPIKey = errors.New("invalid api key")
+
+	apiKeyRepository = repository.NewAPIKeyRepository()
+)
+
+type apiKeyService struct {
+}
+
+func NewAPIKeyService() *apiKeyService {
+	return &apiKeyService{}
+}
+
+// Create generates a new api key for the user, the plain key is only returned here and never stored
+func (s *apiKeyService) Create(ctx context.Context, userId int, create *model.APIKeyCreate) (*model.APIKey, string, error) {
+	if create.ExpiresAt != nil && create.ExpiresAt.Before(time.Now()) {
+		return nil, "", errors.New("expires_at must be in the future")
+	}
+
+	prefix := APIKeyPrefix + auth.RandomHex(4)
+	key := prefix + "." + auth.RandomHex(24)
+
+	scopes := create.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	apiKey := &model.APIKey{
+		UserID:    userId,
+		Name:      create.Name,
+		Prefix:    prefix,
+		KeyHash:   auth.HashToken(key),
+		Scopes:    scopes,
+		ExpiresAt: create.ExpiresAt,
+	}
+	if err := apiKeyRepository.Create(ctx, apiKey); err != nil {
+		return nil, "", err
+	}
+
+	return apiKey, key, nil
+}
+
+func (s *apiKeyService) List(ctx context.Context, userId int) ([]*model.APIKey, error) {
+	return apiKeyRepository.List(ctx, userId)
+}
+
+func (s *apiKeyService) Revoke(ctx context.Context, id, userId int) error {
+	return apiKeyRepository.Revoke(ctx, id, userId)
+}
+
+// Authenticate returns the active api key matching the plain key and records its usage
+func (s *apiKeyService) Authenticate(ctx context.Context, key string) (*model.APIKey, error) {
+	apiKey, err := apiKeyRepository.GetWithHash(ctx, auth.HashToken(key))
+	if err != nil {
+		return nil, ErrInvalidAPIKey
+	}
+	_ = apiKeyRepository.LastUsedUpdate(ctx, apiKey.ID)
+	return apiKey, nil
+}
+
+// HasScope reports whether the api key grants the scope, "*" grants every scope
+func (s *apiKeyService) HasScope(apiKey *model.APIKey, scope string) bool {
+	return slices.Contains(apiKey.Scopes, "*") || slices.Contains(apiKey.Scopes, scope)
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mstgnz/starter-kit/api/infra/config"
+	"github.com/mstgnz/starter-kit/api/infra/logger"
+	"github.com/mstgnz/starter-kit/api/model"
+	"github.com/mstgnz/starter-kit/api/repository"
+)
+
+// Events of the audit log
+const (
+	AuditLoginFailed     = "login.failed"
+	AuditAccountLocked   = "account.locked"
+	AuditAccountUnlocked = "account.unlocked"
+)
+
+var auditRepository = repository.NewAuditRepository()
+
+type auditService struct {
+}
+
+func NewAuditService() *auditService {
+	return &auditService{}
+}
+
+// Record stores the entry with the ip of the request, a failure is logged and does not fail the caller
+func (s *auditService) Record(ctx context.Context, entry *model.AuditLog) {
+	if entry.IP == "" {
+		entry.IP, _ = ctx.Value(config.CKey("requestIp")).(string)
+	}
+	if err := auditRepository.Create(ctx, entry); err != nil {
+		logger.Warn(fmt.Sprintf("Audit %s of user %d failed: %v", entry.Event, entry.UserID, err))
+	}
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mstgnz/starter-kit/api/infra/config"
+	"github.com/mstgnz/starter-kit/api/infra/logger"
+	"github.com/mstgnz/starter-kit/api/model"
+	"github.com/mstgnz/starter-kit/api/repository"
+)
+
+var (
+	// LoginDelayAfter is the number of failed logins before each next attempt is delayed,
+	// the delay starts at a second and doubles with every failure up to LoginMaxDelay
+	LoginDelayAfter = 3
+	LoginMaxDelay   = time.Minute
+	// LockoutThreshold is the number of failed logins that locks the account for LockoutDuration
+	LockoutThreshold = 10
+	LockoutDuration  = 15 * time.Minute
+	// FailedLoginWindow is how long a failed login is counted
+	FailedLoginWindow = time.Hour
+)
+
+var (
+	ErrAccountLocked  = errors.New("account is temporarily locked because of too many failed logins")
+	ErrLoginThrottled = errors.New("too many failed logins, please wait before trying again")
+
+	lockoutRepository = repository.NewLockoutRepository()
+	auditor           = NewAuditService()
+)
+
+// LoginBlockedError is returned for a login attempt of a locked or throttled account, Err is
+// ErrAccountLocked or ErrLoginThrottled
+type LoginBlockedError struct {
+	Err   error
+	Until time.Time
+}
+
+func (e *LoginBlockedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *LoginBlockedError) Unwrap() error {
+	return e.Err
+}
+
+// RetryAfter returns the time until the next login attempt is accepted, at least a second
+func (e *LoginBlockedError) RetryAfter() time.Duration {
+	return max(time.Until(e.Until).Round(time.Second), time.Second)
+}
+
+type lockoutService struct {
+}
+
+func NewLockoutService() *lockoutService {
+	return &lockoutService{}
+}
+
+// Check returns a *LoginBlockedError if the user cannot try to log in now
+func (s *lockoutService) Check(ctx context.Context, userId int) error {
+	lockout, err := lockoutRepository.Locked(ctx, userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	blocked := &LoginBlockedError{Err: ErrLoginThrottled, Until: *lockout.LockedUntil}
+	if lockout.FailedAttempts >= LockoutThreshold {
+		blocked.Err = ErrAccountLocked
+	}
+	return blocked
+}
+
+// Fail counts a failed login of the user, reason is what was wrong. Repeated failures delay the next attempt
+// and LockoutThreshold failures lock the account, the user is mailed about the lock.
+func (s *lockoutService) Fail(ctx context.Context, user *model.User, reason string) error {
+	attempts, err := lockoutRepository.Fail(ctx, user.ID, time.Now().Add(-FailedLoginWindow))
+	if err != nil {
+		return err
+	}
+	auditor.Record(ctx, &model.AuditLog{UserID: user.ID, Event: AuditLoginFailed, Detail: fmt.Sprintf("%s, attempt %d", reason, attempts)})
+
+	if attempts < LockoutThreshold {
+		if delay := loginDelay(attempts); delay > 0 {
+			return lockoutRepository.Lock(ctx, user.ID, time.Now().Add(delay))
+		}
+		return nil
+	}
+
+	if err := lockoutRepository.Lock(ctx, user.ID, time.Now().Add(LockoutDuration)); err != nil {
+		return err
+	}
+	auditor.Record(ctx, &model.AuditLog{UserID: user.ID, Event: AuditAccountLocked, Detail: fmt.Sprintf("%d failed logins", attempts)})
+
+	ip, _ := ctx.Value(config.CKey("requestIp")).(string)
+	err = mailer.Send(user.Email, user.Fullname, "account_locked", map[string]any{
+		"TTL":  humanDuration(LockoutDuration),
+		"IP":   ip,
+		"Link": os.Getenv("APP_URL") + "/forgot-password",
+	})
+	if err != nil {
+		logger.Warn(fmt.Sprintf("Lockout mail to user %d failed: %v", user.ID, err))
+	}
+	return nil
+}
+
+// Reset forgets the failed logins of the user after a successful login
+func (s *lockoutService) Reset(ctx context.Context, userId int) error {
+	_, err := lockoutRepository.Delete(ctx, userId)
+	return err
+}
+
+// Unlock lets a locked or throttled user log in again, actorId is the admin who unlocked it
+func (s *lockoutService) Unlock(ctx context.Context, userId, actorId int) error {
+	if _, err := NewUserService().Get(ctx, userId); err != nil {
+		return err
+	}
+
+	unlocked, err := lockoutRepository.Delete(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if unlocked {
+		auditor.Record(ctx, &model.AuditLog{UserID: userId, ActorID: actorId, Event: AuditAccountUnlocked})
+	}
+	return nil
+}
+
+// Lockout returns the lockout of the user if the user cannot log in now, nil otherwise
+func (s *lockoutService) Lockout(ctx context.Context, userId int) (*model.Lockout, error) {
+	lockout, err := lockoutRepository.Locked(ctx, userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return lockout, err
+}
+
+// loginDelay returns the wait before the next login after the given number of failures
+func loginDelay(attempts int) time.Duration {
+	if attempts < LoginDelayAfter {
+		return 0
+	}
+	shift := attempts - LoginDelayAfter
+	if shift > 30 {
+		return LoginMaxDelay
+	}
+	return min(time.Second<<shift, LoginMaxDelay)
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mstgnz/starter-kit/api/infra/config"
+	"github.com/mstgnz/starter-kit/api/infra/testdb"
+	"github.com/mstgnz/starter-kit/api/model"
+)
+
+func TestLoginDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		delay    time.Duration
+	}{
+		{0, 0},
+		{LoginDelayAfter - 1, 0},
+		{LoginDelayAfter, time.Second},
+		{LoginDelayAfter + 1, 2 * time.Second},
+		{LoginDelayAfter + 5, 32 * time.Second},
+		{LoginDelayAfter + 6, LoginMaxDelay},
+		{LoginDelayAfter + 31, LoginMaxDelay},
+		{LoginDelayAfter + 100, LoginMaxDelay},
+	}
+	for _, tt := range tests {
+		if delay := loginDelay(tt.attempts); delay != tt.delay {
+			t.Errorf("delay after %d failures is %v, want %v", tt.attempts, delay, tt.delay)
+		}
+	}
+}
+
+// TestLockout walks a user through failed logins: the window reset, the delay, the lock and an unlock
+func TestLockout(t *testing.T) {
+	testdb.Open(t)
+	ctx := context.Background()
+	user, err := userRepository.Create(ctx, &model.Register{Fullname: "Lockout", Email: "lockout@lockout.test", Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lockouts := NewLockoutService()
+
+	fail := func(times int) {
+		t.Helper()
+		for i := 0; i < times; i++ {
+			if err := lockouts.Fail(ctx, user, "wrong password"); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// blocked checks the error of Check and that the block ends after about wait, which depends on the
+	// locked_until that was written and read back
+	blocked := func(want error, wait time.Duration) {
+		t.Helper()
+		err := lockouts.Check(ctx, user.ID)
+		var blocked *LoginBlockedError
+		if !errors.As(err, &blocked) || !errors.Is(err, want) {
+			t.Fatalf("check %v, want %v", err, want)
+		}
+		if left := time.Until(blocked.Until); left <= wait-2*time.Second || left > wait {
+			t.Fatalf("blocked for %v, want about %v", left, wait)
+		}
+	}
+
+	fail(LoginDelayAfter - 1)
+	if err := lockouts.Check(ctx, user.ID); err != nil {
+		t.Fatalf("check before the delay %v", err)
+	}
+
+	// failures older than the window are forgotten, this one is the first again
+	window := FailedLoginWindow
+	FailedLoginWindow = -2 * time.Second
+	fail(1)
+	FailedLoginWindow = window
+	fail(LoginDelayAfter - 2)
+	if err := lockouts.Check(ctx, user.ID); err != nil {
+		t.Fatalf("check after the window reset %v", err)
+	}
+
+	fail(1)
+	blocked(ErrLoginThrottled, loginDelay(LoginDelayAfter))
+
+	fail(LockoutThreshold - LoginDelayAfter)
+	blocked(ErrAccountLocked, LockoutDuration)
+
+	actor, err := userRepository.Create(ctx, &model.Register{Fullname: "Admin", Email: "admin@lockout.test", Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lockouts.Unlock(ctx, user.ID, actor.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := lockouts.Check(ctx, user.ID); err != nil {
+		t.Fatalf("check after the unlock %v", err)
+	}
+	var unlocked int
+	err = config.App().DB.QueryRowContext(ctx, "SELECT count(*) FROM audit_logs WHERE user_id=$1 AND actor_id=$2 AND event=$3", user.ID, actor.ID, AuditAccountUnlocked).Scan(&unlocked)
+	if err != nil || unlocked != 1 {
+		t.Fatalf("%d unlock audits, %v, want 1", unlocked, err)
+	}
+
+	// the failures were forgotten, the next one does not delay
+	fail(1)
+	if err := lockouts.Check(ctx, user.ID); err != nil {
+		t.Fatalf("check after a failure following the unlock %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"os"
+
+	"github.com/mstgnz/starter-kit/api/asset"
+	"github.com/mstgnz/starter-kit/api/infra/config"
+	"github.com/mstgnz/starter-kit/api/infra/logger"
+)
+
+type mailService struct {
+}
+
+func NewMailService() *mailService {
+	return &mailService{}
+}
+
+// Render renders the subject and the html body of the template in asset/mails, data is available in the
+// template along with .Name and .App
+func (s *mailService) Render(name, recipient string, data map[string]any) (string, string, error) {
+	tmpl, err := template.ParseFS(asset.Mails, "mails/layout.html", "mails/"+name+".html")
+	if err != nil {
+		return "", "", err
+	}
+
+	values := map[string]any{"Name": recipient, "App": os.Getenv("APP_NAME")}
+	for key, value := range data {
+		values[key] = value
+	}
+
+	var subject, body bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&subject, "subject", values); err != nil {
+		return "", "", err
+	}
+	if err := tmpl.ExecuteTemplate(&body, "layout.html", values); err != nil {
+		return "", "", err
+	}
+	return subject.String(), body.String(), nil
+}
+
+// Send renders the template and mails it to the address in the background, failures are logged
+func (s *mailService) Send(to, recipient, name string, data map[string]any) error {
+	subject, body, err := s.Render(name, recipient, data)
+	if err != nil {
+		return err
+	}
+
+	// the configured client is shared, every mail is sent with its own copy
+	mail := *config.App().Mail
+	mail.SetTo(to).SetSubject(subject).SetContent(body)
+	go func() {
+		if err := mail.SendHTML(); err != nil {
+			logger.Warn(fmt.Sprintf("Mail %s to %s failed: %v", name, to, err))
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,240 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mstgnz/starter-kit/api/infra/auth"
+	"github.com/mstgnz/starter-kit/api/infra/config"
+	"github.com/mstgnz/starter-kit/api/model"
+	"github.com/mstgnz/starter-kit/api/repository"
+)
+
+// RecoveryCodeCount is the number of recovery codes generated when two factor authentication is turned on
+var RecoveryCodeCount = 10
+
+var (
+	ErrMFAEnabled      = errors.New("two factor authentication is already enabled")
+	ErrMFANotEnabled   = errors.New("two factor authentication is not enabled")
+	ErrMFANotEnrolled  = errors.New("two factor authentication is not enrolled")
+	ErrInvalidMFACode  = errors.New("invalid two factor code")
+	ErrInvalidMFAToken = errors.New("invalid or expired mfa token")
+
+	mfaRepository = repository.NewMFARepository()
+)
+
+type mfaService struct {
+}
+
+func NewMFAService() *mfaService {
+	return &mfaService{}
+}
+
+// Enabled reports whether the user has a confirmed TOTP secret
+func (s *mfaService) Enabled(ctx context.Context, userId int) (bool, error) {
+	totp, err := mfaRepository.TOTP(ctx, userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return totp.ConfirmedAt != nil, nil
+}
+
+// Enroll creates a new secret for the user, it is not used until Confirm. Enrolling again replaces an unconfirmed secret.
+func (s *mfaService) Enroll(ctx context.Context, user *model.User) (*model.MFAEnrollment, error) {
+	enabled, err := s.Enabled(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, ErrMFAEnabled
+	}
+
+	secret := auth.NewTOTPSecret()
+	err = config.App().DB.WithTx(ctx, func(ctx context.Context) error {
+		if err := mfaRepository.DeleteTOTP(ctx, user.ID); err != nil {
+			return err
+		}
+		return mfaRepository.CreateTOTP(ctx, user.ID, secret)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.MFAEnrollment{Secret: secret, URI: auth.TOTPURI(os.Getenv("APP_NAME"), user.Email, secret)}, nil
+}
+
+// Confirm turns two factor authentication on with a code of the enrolled secret and returns the recovery codes,
+// they are only returned here and stored hashed
+func (s *mfaService) Confirm(ctx context.Context, userId int, code string) ([]string, error) {
+	totp, err := mfaRepository.TOTP(ctx, userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrMFANotEnrolled
+	}
+	if err != nil {
+		return nil, err
+	}
+	if totp.ConfirmedAt != nil {
+		return nil, ErrMFAEnabled
+	}
+
+	counter, ok := auth.ValidateTOTP(totp.Secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	codes, hashes := recoveryCodes(RecoveryCodeCount)
+	err = config.App().DB.WithTx(ctx, func(ctx context.Context) error {
+		confirmed, err := mfaRepository.ConfirmTOTP(ctx, userId, counter)
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			return ErrMFAEnabled
+		}
+		if err := mfaRepository.DeleteRecoveryCodes(ctx, userId); err != nil {
+			return err
+		}
+		return mfaRepository.CreateRecoveryCodes(ctx, userId, hashes)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Disable turns two factor authentication off, it needs the password and a code
+func (s *mfaService) Disable(ctx context.Context, userId int, disable *model.MFADisable) error {
+	user, err := userRepository.GetWithId(ctx, userId)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	if !auth.ComparePassword(user.Password, disable.Password) {
+		return ErrWrongPassword
+	}
+	if err := s.verify(ctx, userId, disable.Code); err != nil {
+		return err
+	}
+
+	return config.App().DB.WithTx(ctx, func(ctx context.Context) error {
+		if err := mfaRepository.DeleteTOTP(ctx, userId); err != nil {
+			return err
+		}
+		return mfaRepository.DeleteRecoveryCodes(ctx, userId)
+	})
+}
+
+// Challenge returns the token for the second login step if the user has two factor authentication, nil otherwise
+func (s *mfaService) Challenge(ctx context.Context, userId int) (*model.MFAChallenge, error) {
+	enabled, err := s.Enabled(ctx, userId)
+	if err != nil || !enabled {
+		return nil, err
+	}
+
+	token, expiresAt, err := auth.GenerateMFAToken(userId)
+	if err != nil {
+		return nil, err
+	}
+	return &model.MFAChallenge{MFAToken: token, ExpiresAt: expiresAt}, nil
+}
+
+// Login checks the code for the token of Challenge and returns the id of the user, wrong codes count as
+// failed logins
+func (s *mfaService) Login(ctx context.Context, login *model.MFALogin) (int, error) {
+	claims, err := auth.ParseMFAToken(login.MFAToken)
+	if err != nil {
+		return 0, ErrInvalidMFAToken
+	}
+	userId, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return 0, ErrInvalidMFAToken
+	}
+
+	lockouts := NewLockoutService()
+	if err := lockouts.Check(ctx, userId); err != nil {
+		return 0, err
+	}
+
+	if err := s.verify(ctx, userId, login.Code); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			user, findErr := userRepository.Find(ctx, userId, false)
+			if errors.Is(findErr, sql.ErrNoRows) {
+				return 0, ErrInvalidMFAToken
+			}
+			if findErr != nil {
+				return 0, findErr
+			}
+			if failErr := lockouts.Fail(ctx, user, "wrong two factor code"); failErr != nil {
+				return 0, failErr
+			}
+		}
+		return 0, err
+	}
+	if err := lockouts.Reset(ctx, userId); err != nil {
+		return 0, err
+	}
+
+	// the user may have been deactivated since the password was checked
+	user, err := userRepository.Find(ctx, userId, false)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !user.Active) {
+		return 0, ErrInvalidMFAToken
+	}
+	if err != nil {
+		return 0, err
+	}
+	return userId, nil
+}
+
+// verify accepts a TOTP code that was not used before or an unused recovery code
+func (s *mfaService) verify(ctx context.Context, userId int, code string) error {
+	totp, err := mfaRepository.TOTP(ctx, userId)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && totp.ConfirmedAt == nil) {
+		return ErrMFANotEnabled
+	}
+	if err != nil {
+		return err
+	}
+
+	if counter, ok := auth.ValidateTOTP(totp.Secret, code, time.Now()); ok {
+		used, err := mfaRepository.UseTOTP(ctx, userId, counter)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return ErrInvalidMFACode
+		}
+		return nil
+	}
+
+	used, err := mfaRepository.UseRecoveryCode(ctx, userId, auth.HashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+// recoveryCodes returns n codes formatted as xxxxx-xxxxx and their hashes
+func recoveryCodes(n int) ([]string, []string) {
+	codes := make([]string, n)
+	hashes := make([]string, n)
+	for i := range codes {
+		code := auth.RandomHex(5)
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i] = auth.HashToken(code)
+	}
+	return codes, hashes
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
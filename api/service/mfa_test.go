@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mstgnz/starter-kit/api/infra/auth"
+	"github.com/mstgnz/starter-kit/api/infra/testdb"
+	"github.com/mstgnz/starter-kit/api/model"
+)
+
+// TestMFAVerify checks that a TOTP code is accepted once, an earlier step is rejected after a later one was used
+// and a recovery code is accepted once
+func TestMFAVerify(t *testing.T) {
+	testdb.Open(t)
+	ctx := context.Background()
+	user, err := userRepository.Create(ctx, &model.Register{Fullname: "MFA", Email: "verify@mfa.test", Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enrollment, err := NewMFAService().Enroll(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter := time.Now().Unix() / int64(auth.TOTPPeriod.Seconds())
+	code := func(step int64) string {
+		t.Helper()
+		code, err := auth.TOTPCode(enrollment.Secret, counter+step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+
+	if _, err := NewMFAService().Confirm(ctx, user.ID, code(5)); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("confirm with a code outside the skew %v, want ErrInvalidMFACode", err)
+	}
+	recovery, err := NewMFAService().Confirm(ctx, user.ID, code(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recovery) != RecoveryCodeCount {
+		t.Fatalf("%d recovery codes, want %d", len(recovery), RecoveryCodeCount)
+	}
+
+	// the code of the confirmation is spent
+	if err := NewMFAService().verify(ctx, user.ID, code(0)); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("the confirmation code again %v, want ErrInvalidMFACode", err)
+	}
+	if err := NewMFAService().verify(ctx, user.ID, code(1)); err != nil {
+		t.Fatalf("the code of the next step %v", err)
+	}
+	for _, step := range []int64{1, 0, -1} {
+		if err := NewMFAService().verify(ctx, user.ID, code(step)); !errors.Is(err, ErrInvalidMFACode) {
+			t.Fatalf("code of step %+d after step 1 was used %v, want ErrInvalidMFACode", step, err)
+		}
+	}
+
+	// recovery codes are accepted in any case and without the dash, each one once
+	if err := NewMFAService().verify(ctx, user.ID, strings.ToUpper(strings.ReplaceAll(recovery[0], "-", ""))); err != nil {
+		t.Fatalf("recovery code %v", err)
+	}
+	if err := NewMFAService().verify(ctx, user.ID, recovery[0]); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("recovery code again %v, want ErrInvalidMFACode", err)
+	}
+	if err := NewMFAService().verify(ctx, user.ID, recovery[1]); err != nil {
+		t.Fatalf("another recovery code %v", err)
+	}
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mstgnz/starter-kit/api/infra/auth"
+	"github.com/mstgnz/starter-kit/api/infra/config"
+	"github.com/mstgnz/starter-kit/api/infra/logger"
+	"github.com/mstgnz/starter-kit/api/infra/oauth"
+	"github.com/mstgnz/starter-kit/api/model"
+	"github.com/mstgnz/starter-kit/api/repository"
+)
+
+// AuditIdentityLinked is the audit event of a provider account linked to a user at its first login
+const AuditIdentityLinked = "identity.linked"
+
+// OAuthStateTTL is how long the user has to complete the login at the provider
+var OAuthStateTTL = 10 * time.Minute
+
+var (
+	ErrOAuthProvider          = errors.New("unknown login provider")
+	ErrOAuthState             = errors.New("invalid or expired login state, please start again")
+	ErrOAuthDenied            = errors.New("login was cancelled at the provider")
+	ErrOAuthFailed            = errors.New("login with the provider failed")
+	ErrOAuthEmailUnverified   = errors.New("the provider did not return a verified email")
+	ErrOAuthAccountUnverified = errors.New("an account with this email exists, verify its email before signing in with a provider")
+	ErrOAuthAccountInactive   = errors.New("the account is deactivated")
+
+	identityRepository = repository.NewIdentityRepository()
+)
+
+// oauthState is what is kept of a started login until the provider redirects back
+type oauthState struct {
+	Provider string `json:"provider"`
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+type oauthService struct {
+}
+
+func NewOAuthService() *oauthService {
+	return &oauthService{}
+}
+
+// Start returns the consent page url of the provider and the login token. The token is a signed copy of the state,
+// nonce and PKCE verifier that expires after OAuthStateTTL, the client keeps it in a cookie and the callback
+// only accepts the state of its own token. So a login is bound to the browser that started it and no instance
+// has to remember it.
+func (s *oauthService) Start(ctx context.Context, providerName string) (string, string, error) {
+	provider, ok := oauth.Get(providerName)
+	if !ok {
+		return "", "", ErrOAuthProvider
+	}
+
+	login := oauthState{Provider: providerName, State: oauth.RandomString(32), Nonce: oauth.RandomString(16), Verifier: oauth.RandomString(32)}
+	value, err := json.Marshal(login)
+	if err != nil {
+		return "", "", err
+	}
+	token, _, err := auth.GenerateOAuthToken(string(value), OAuthStateTTL)
+	if err != nil {
+		return "", "", err
+	}
+
+	authURL, err := provider.AuthURL(ctx, login.State, login.Nonce, oauth.Challenge(login.Verifier))
+	if err != nil {
+		logger.Warn(fmt.Sprintf("OAuth %s start failed: %v", providerName, err))
+		return "", "", ErrOAuthFailed
+	}
+	return authURL, token, nil
+}
+
+// Callback completes the login with the code of the provider and returns the user, loginToken is the token Start
+// returned to the client. A new provider account is linked to the user with the same verified email, a user is
+// created when there is none. A locked or deactivated user is rejected as in a password login.
+func (s *oauthService) Callback(ctx context.Context, callback *model.OAuthCallback, loginToken string) (*model.User, error) {
+	provider, ok := oauth.Get(callback.Provider)
+	if !ok {
+		return nil, ErrOAuthProvider
+	}
+
+	// a callback without the token of the browser that started the login could be the login of someone else
+	value, err := auth.ParseOAuthToken(loginToken)
+	if err != nil {
+		return nil, ErrOAuthState
+	}
+	login := oauthState{}
+	if err := json.Unmarshal([]byte(value), &login); err != nil || login.Provider != callback.Provider {
+		return nil, ErrOAuthState
+	}
+	if login.State == "" || subtle.ConstantTimeCompare([]byte(login.State), []byte(callback.State)) != 1 {
+		return nil, ErrOAuthState
+	}
+
+	if callback.Error != "" {
+		return nil, ErrOAuthDenied
+	}
+	if callback.Code == "" {
+		return nil, ErrOAuthFailed
+	}
+
+	identity, err := provider.Exchange(ctx, callback.Code, login.Verifier, login.Nonce)
+	if err != nil {
+		logger.Warn(fmt.Sprintf("OAuth %s callback failed: %v", callback.Provider, err))
+		return nil, ErrOAuthFailed
+	}
+
+	var userId int
+	err = config.App().DB.WithTx(ctx, func(ctx context.Context) error {
+		userId, err = s.link(ctx, identity)
+		if err != nil {
+			return err
+		}
+		return s.login(ctx, userId)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return NewUserService().Profile(ctx, userId)
+}
+
+// login runs the checks of a password login for the user of the identity, see userService.Login
+func (s *oauthService) login(ctx context.Context, userId int) error {
+	user, err := userRepository.Find(ctx, userId, false)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !user.Active) {
+		return ErrOAuthAccountInactive
+	}
+	if err != nil {
+		return err
+	}
+
+	lockouts := NewLockoutService()
+	if err := lockouts.Check(ctx, userId); err != nil {
+		return err
+	}
+	// with two factor authentication the failures are forgotten after the code, see mfaService.Login
+	enabled, err := NewMFAService().Enabled(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		if err := lockouts.Reset(ctx, userId); err != nil {
+			return err
+		}
+	}
+	return userRepository.LastLoginUpdate(ctx, userId)
+}
+
+// link returns the user of the identity, linking or creating it on the first login with the provider
+func (s *oauthService) link(ctx context.Context, identity *oauth.Identity) (int, error) {
+	linked, err := identityRepository.Get(ctx, identity.Provider, identity.Subject)
+	if err == nil {
+		if _, err := userRepository.Find(ctx, linked.UserID, false); errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrOAuthAccountInactive
+		} else if err != nil {
+			return 0, err
+		}
+		return linked.UserID, identityRepository.Login(ctx, linked.ID, identity.Email)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	// only a verified email proves the provider account and the user are the same person
+	if identity.Email == "" || !identity.EmailVerified {
+		return 0, ErrOAuthEmailUnverified
+	}
+
+	userId, err := s.userWithEmail(ctx, identity)
+	if err != nil {
+		return 0, err
+	}
+
+	err = identityRepository.Create(ctx, &model.UserIdentity{UserID: userId, Provider: identity.Provider, Subject: identity.Subject, Email: identity.Email})
+	if err != nil {
+		return 0, err
+	}
+	auditor.Record(ctx, &model.AuditLog{UserID: userId, Event: AuditIdentityLinked, Detail: identity.Provider})
+	return userId, nil
+}
+
+// userWithEmail returns the id of the user with the verified email of the identity, a new user is created when
+// no account has the email. An account whose email is not verified is not linked, anyone could have registered it.
+func (s *oauthService) userWithEmail(ctx context.Context, identity *oauth.Identity) (int, error) {
+	if existing, err := userRepository.GetWithMail(ctx, identity.Email); err == nil {
+		user, err := userRepository.Find(ctx, existing.ID, false)
+		if err != nil {
+			return 0, err
+		}
+		if user.EmailVerifiedAt == nil {
+			return 0, ErrOAuthAccountUnverified
+		}
+		return user.ID, nil
+	}
+
+	// a deactivated user keeps the email
+	exists, err := userRepository.Exists(ctx, identity.Email)
+	if err != nil {
+		return 0, err
+	}
+	if exists {
+		return 0, ErrOAuthAccountInactive
+	}
+
+	name := identity.Name
+	if name == "" {
+		name, _, _ = strings.Cut(identity.Email, "@")
+	}
+	// the password is random, the user can set one with the password reset flow
+	user, err := userRepository.Create(ctx, &model.Register{Fullname: name, Email: identity.Email, Password: auth.RandomHex(32)})
+	if err != nil {
+		return 0, err
+	}
+	if err := userRepository.VerifyEmail(ctx, user.ID); err != nil {
+		return 0, err
+	}
+	if err := roleRepository.Assign(ctx, user.ID, DefaultRole); err != nil {
+		return 0, err
+	}
+	return user.ID, nil
+}
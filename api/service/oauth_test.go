@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mstgnz/starter-kit/api/infra/oauth"
+	"github.com/mstgnz/starter-kit/api/infra/oauth/oidctest"
+	"github.com/mstgnz/starter-kit/api/infra/testdb"
+	"github.com/mstgnz/starter-kit/api/model"
+)
+
+// oauthLogin runs a login with the stub provider, the provider authenticates the user of claims
+func oauthLogin(t *testing.T, server *oidctest.Server, claims map[string]any) (*model.User, error) {
+	t.Helper()
+	ctx := context.Background()
+	authURL, token, err := NewOAuthService().Start(ctx, "stub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, state := server.Authorize(t, authURL, claims)
+	return NewOAuthService().Callback(ctx, &model.OAuthCallback{Provider: "stub", Code: code, State: state}, token)
+}
+
+func TestOAuthCallback(t *testing.T) {
+	testdb.Open(t)
+	ctx := context.Background()
+	server := oidctest.NewServer(t, "client")
+	oauth.Register(server.Provider("stub"))
+
+	t.Run("new user", func(t *testing.T) {
+		user, err := oauthLogin(t, server, map[string]any{"sub": "new", "email": "new@oauth.test", "email_verified": "true", "name": "New"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.Email != "new@oauth.test" || user.Fullname != "New" || user.EmailVerifiedAt == nil {
+			t.Fatalf("user %+v", user)
+		}
+		// the next login finds the user by the subject, the email may have changed at the provider
+		again, err := oauthLogin(t, server, map[string]any{"sub": "new", "email": "renamed@oauth.test"})
+		if err != nil || again.ID != user.ID {
+			t.Fatalf("second login %+v, %v, want user %d", again, err, user.ID)
+		}
+	})
+
+	t.Run("link verified user", func(t *testing.T) {
+		existing, err := userRepository.Create(ctx, &model.Register{Fullname: "Verified", Email: "verified@oauth.test", Password: "secret"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := userRepository.VerifyEmail(ctx, existing.ID); err != nil {
+			t.Fatal(err)
+		}
+		user, err := oauthLogin(t, server, map[string]any{"sub": "verified", "email": "verified@oauth.test", "email_verified": true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.ID != existing.ID {
+			t.Fatalf("linked user %d, want %d", user.ID, existing.ID)
+		}
+		if _, err := identityRepository.Get(ctx, "stub", "verified"); err != nil {
+			t.Fatalf("identity not linked: %v", err)
+		}
+	})
+
+	t.Run("refuse unverified user", func(t *testing.T) {
+		if _, err := userRepository.Create(ctx, &model.Register{Fullname: "Unverified", Email: "unverified@oauth.test", Password: "secret"}); err != nil {
+			t.Fatal(err)
+		}
+		_, err := oauthLogin(t, server, map[string]any{"sub": "unverified", "email": "unverified@oauth.test", "email_verified": true})
+		if !errors.Is(err, ErrOAuthAccountUnverified) {
+			t.Fatalf("error %v, want ErrOAuthAccountUnverified", err)
+		}
+		if _, err := identityRepository.Get(ctx, "stub", "unverified"); err == nil {
+			t.Fatal("the identity was linked to an unverified user")
+		}
+	})
+
+	t.Run("unverified email at the provider", func(t *testing.T) {
+		_, err := oauthLogin(t, server, map[string]any{"sub": "unverified-email", "email": "someone@oauth.test", "email_verified": "false"})
+		if !errors.Is(err, ErrOAuthEmailUnverified) {
+			t.Fatalf("error %v, want ErrOAuthEmailUnverified", err)
+		}
+	})
+
+	t.Run("locked user", func(t *testing.T) {
+		user, err := oauthLogin(t, server, map[string]any{"sub": "locked", "email": "locked@oauth.test", "email_verified": true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := lockoutRepository.Fail(ctx, user.ID, time.Now().Add(-FailedLoginWindow)); err != nil {
+			t.Fatal(err)
+		}
+		if err := lockoutRepository.Lock(ctx, user.ID, time.Now().Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+		var blocked *LoginBlockedError
+		if _, err := oauthLogin(t, server, map[string]any{"sub": "locked"}); !errors.As(err, &blocked) {
+			t.Fatalf("error %v, want a LoginBlockedError", err)
+		}
+	})
+
+	t.Run("deactivated user", func(t *testing.T) {
+		user, err := oauthLogin(t, server, map[string]any{"sub": "deactivated", "email": "deactivated@oauth.test", "email_verified": true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := NewUserService().Deactivate(ctx, user.ID, 0); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := oauthLogin(t, server, map[string]any{"sub": "deactivated"}); !errors.Is(err, ErrOAuthAccountInactive) {
+			t.Fatalf("error %v, want ErrOAuthAccountInactive", err)
+		}
+	})
+}
+
+// TestOAuthState checks that a callback is only accepted with the login token of its own start
+func TestOAuthState(t *testing.T) {
+	testdb.Open(t)
+	ctx := context.Background()
+	server := oidctest.NewServer(t, "client")
+	oauth.Register(server.Provider("stub"))
+
+	// the attacker starts a login and has the victim's browser complete it
+	attackerURL, _, err := NewOAuthService().Start(ctx, "stub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, state := server.Authorize(t, attackerURL, map[string]any{"sub": "attacker", "email": "attacker@oauth.test", "email_verified": true})
+	_, victimToken, err := NewOAuthService().Start(ctx, "stub")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, token := range map[string]string{"no login token": "", "login token of another start": victimToken, "invalid login token": "x.y.z"} {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewOAuthService().Callback(ctx, &model.OAuthCallback{Provider: "stub", Code: code, State: state}, token)
+			if !errors.Is(err, ErrOAuthState) {
+				t.Fatalf("error %v, want ErrOAuthState", err)
+			}
+		})
+	}
+
+	t.Run("other provider", func(t *testing.T) {
+		oauth.Register(server.Provider("other"))
+		authURL, token, err := NewOAuthService().Start(ctx, "stub")
+		if err != nil {
+			t.Fatal(err)
+		}
+		code, state := server.Authorize(t, authURL, map[string]any{"sub": "other"})
+		if _, err := NewOAuthService().Callback(ctx, &model.OAuthCallback{Provider: "other", Code: code, State: state}, token); !errors.Is(err, ErrOAuthState) {
+			t.Fatalf("error %v, want ErrOAuthState", err)
+		}
+	})
+
+	t.Run("denied", func(t *testing.T) {
+		authURL, token, err := NewOAuthService().Start(ctx, "stub")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, state := server.Authorize(t, authURL, nil)
+		if _, err := NewOAuthService().Callback(ctx, &model.OAuthCallback{Provider: "stub", State: state, Error: "access_denied"}, token); !errors.Is(err, ErrOAuthDenied) {
+			t.Fatalf("error %v, want ErrOAuthDenied", err)
+		}
+	})
+}
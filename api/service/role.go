@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/mstgnz/starter-kit/api/infra/config"
+	"github.com/mstgnz/starter-kit/api/model"
+	"github.com/mstgnz/starter-kit/api/repository"
+)
+
+// Audit events of role changes, the detail is the role
+const (
+	AuditRoleAssigned = "role.assigned"
+	AuditRoleRevoked  = "role.revoked"
+)
+
+var (
+	ErrRoleNotFound     = errors.New("role not found")
+	ErrUserRoleNotFound = errors.New("user does not have the role")
+)
+
+// AdminRole is the role of the admins, it is kept in sync with every permission and with users.is_admin
+var AdminRole = "admin"
+
+// AccessCacheTTL is how long the roles and permissions of a user are cached
+var AccessCacheTTL = 5 * time.Minute
+
+var roleRepository = repository.NewRoleRepository()
+
+type roleService struct {
+}
+
+func NewRoleService() *roleService {
+	return &roleService{}
+}
+
+// Access returns the roles and permissions of the user, results are cached per user
+func (s *roleService) Access(ctx context.Context, userId int) (*model.Access, error) {
+	key := accessKey(userId)
+
+	if data, err := config.App().Cache.Get(key); err == nil {
+		access := &model.Access{}
+		if err := json.Unmarshal(data, access); err == nil {
+			return access, nil
+		}
+	}
+
+	roles, err := roleRepository.Roles(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	permissions, err := roleRepository.Permissions(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	access := &model.Access{Roles: roles, Permissions: permissions}
+	if data, err := json.Marshal(access); err == nil {
+		_ = config.App().Cache.Set(key, data, AccessCacheTTL)
+	}
+	return access, nil
+}
+
+// HasPermission reports whether the user has any of the permissions, admins have every permission
+func (s *roleService) HasPermission(ctx context.Context, user *model.User, permissions ...string) (bool, error) {
+	if user.IsAdmin {
+		return true, nil
+	}
+	access, err := s.Access(ctx, user.ID)
+	if err != nil {
+		return false, err
+	}
+	for _, permission := range permissions {
+		if slices.Contains(access.Permissions, permission) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// HasRole reports whether the user has any of the roles
+func (s *roleService) HasRole(ctx context.Context, user *model.User, roles ...string) (bool, error) {
+	access, err := s.Access(ctx, user.ID)
+	if err != nil {
+		return false, err
+	}
+	for _, role := range roles {
+		if slices.Contains(access.Roles, role) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Assign gives the role to the user, the admin role also makes the user an admin. actorId is the user who assigned it.
+// It returns ErrRoleNotFound for a role that is not defined.
+func (s *roleService) Assign(ctx context.Context, userId, actorId int, role string) error {
+	err := config.App().DB.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.exists(ctx, role); err != nil {
+			return err
+		}
+		if err := roleRepository.Assign(ctx, userId, role); err != nil {
+			return err
+		}
+		if role == AdminRole {
+			return userRepository.AdminUpdate(ctx, userId, true)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.Invalidate(userId)
+	auditor.Record(ctx, &model.AuditLog{UserID: userId, ActorID: actorId, Event: AuditRoleAssigned, Detail: role})
+	return nil
+}
+
+// Revoke takes the role from the user, revoking the admin role also ends the admin rights of the user.
+// It returns ErrRoleNotFound for a role that is not defined and ErrUserRoleNotFound when the user does not have it.
+func (s *roleService) Revoke(ctx context.Context, userId, actorId int, role string) error {
+	err := config.App().DB.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.exists(ctx, role); err != nil {
+			return err
+		}
+		err := roleRepository.Revoke(ctx, userId, role)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUserRoleNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if role == AdminRole {
+			return userRepository.AdminUpdate(ctx, userId, false)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.Invalidate(userId)
+	auditor.Record(ctx, &model.AuditLog{UserID: userId, ActorID: actorId, Event: AuditRoleRevoked, Detail: role})
+	return nil
+}
+
+// exists returns ErrRoleNotFound when the role is not defined
+func (s *roleService) exists(ctx context.Context, role string) error {
+	exists, err := roleRepository.Exists(ctx, role)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrRoleNotFound
+	}
+	return nil
+}
+
+// GrantAllPermissions gives the role every permission, cached access expires within AccessCacheTTL
+func (s *roleService) GrantAllPermissions(ctx context.Context, role string) error {
+	return roleRepository.GrantAllPermissions(ctx, role)
+}
+
+// Invalidate drops the cached access of the user
+func (s *roleService) Invalidate(userId int) {
+	_ = config.App().Cache.Delete(accessKey(userId))
+}
+
+func accessKey(userId int) []byte {
+	return []byte(fmt.Sprintf("access:%d", userId))
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mstgnz/starter-kit/api/infra/config"
+	"github.com/mstgnz/starter-kit/api/infra/testdb"
+	"github.com/mstgnz/starter-kit/api/model"
+)
+
+// TestAdminRole checks that the admin role and only the admin role makes a user an admin, and that the change is audited
+func TestAdminRole(t *testing.T) {
+	testdb.Open(t)
+	ctx := context.Background()
+	actor, err := userRepository.Create(ctx, &model.Register{Fullname: "Actor", Email: "actor@role.test", Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := userRepository.Create(ctx, &model.Register{Fullname: "User", Email: "user@role.test", Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	isAdmin := func() bool {
+		t.Helper()
+		user, err := NewUserService().Profile(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return user.IsAdmin
+	}
+	audited := func(event string) int {
+		t.Helper()
+		var count int
+		err := config.App().DB.QueryRowContext(ctx, "SELECT count(*) FROM audit_logs WHERE user_id=$1 AND actor_id=$2 AND event=$3 AND detail=$4", user.ID, actor.ID, event, AdminRole).Scan(&count)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return count
+	}
+
+	if err := NewRoleService().Assign(ctx, user.ID, actor.ID, DefaultRole); err != nil {
+		t.Fatal(err)
+	}
+	if isAdmin() {
+		t.Fatal("the default role made the user an admin")
+	}
+
+	if err := NewRoleService().Assign(ctx, user.ID, actor.ID, AdminRole); err != nil {
+		t.Fatal(err)
+	}
+	if !isAdmin() || audited(AuditRoleAssigned) != 1 {
+		t.Fatalf("admin %v, audited %d, want an audited admin", isAdmin(), audited(AuditRoleAssigned))
+	}
+
+	if err := NewRoleService().Revoke(ctx, user.ID, actor.ID, AdminRole); err != nil {
+		t.Fatal(err)
+	}
+	if isAdmin() || audited(AuditRoleRevoked) != 1 {
+		t.Fatalf("admin %v, audited %d, want an audited revoke", isAdmin(), audited(AuditRoleRevoked))
+	}
+}
+
+// TestRoleNotFound checks that an unknown role and a role the user does not have are reported and not audited
+func TestRoleNotFound(t *testing.T) {
+	testdb.Open(t)
+	ctx := context.Background()
+	user, err := userRepository.Create(ctx, &model.Register{Fullname: "User", Email: "missing@role.test", Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewRoleService().Assign(ctx, user.ID, user.ID, "unknown"); !errors.Is(err, ErrRoleNotFound) {
+		t.Fatalf("assign of an unknown role %v, want ErrRoleNotFound", err)
+	}
+	if err := NewRoleService().Revoke(ctx, user.ID, user.ID, "unknown"); !errors.Is(err, ErrRoleNotFound) {
+		t.Fatalf("revoke of an unknown role %v, want ErrRoleNotFound", err)
+	}
+	if err := NewRoleService().Revoke(ctx, user.ID, user.ID, AdminRole); !errors.Is(err, ErrUserRoleNotFound) {
+		t.Fatalf("revoke of a role the user does not have %v, want ErrUserRoleNotFound", err)
+	}
+
+	// assigning a role the user already has is not an error
+	for i := 0; i < 2; i++ {
+		if err := NewRoleService().Assign(ctx, user.ID, user.ID, DefaultRole); err != nil {
+			t.Fatalf("assign %d %v", i+1, err)
+		}
+	}
+
+	var audits int
+	err = config.App().DB.QueryRowContext(ctx, "SELECT count(*) FROM audit_logs WHERE user_id=$1 AND detail<>$2", user.ID, DefaultRole).Scan(&audits)
+	if err != nil || audits != 0 {
+		t.Fatalf("%d audits of failed role changes, %v, want none", audits, err)
+	}
+}
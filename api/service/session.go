@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mstgnz/starter-kit/api/infra/auth"
+	"github.com/mstgnz/starter-kit/api/infra/config"
+	"github.com/mstgnz/starter-kit/api/model"
+	"github.com/mstgnz/starter-kit/api/repository"
+)
+
+// AuditSessionRevoked is the audit event of a session ended by its user from another session
+const AuditSessionRevoked = "session.revoked"
+
+// SessionTouchInterval is how often the last seen time of a session is written while it is used
+var SessionTouchInterval = time.Minute
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrSessionRevoked  = errors.New("session revoked")
+
+	sessionRepository = repository.NewSessionRepository()
+)
+
+type sessionService struct {
+}
+
+func NewSessionService() *sessionService {
+	return &sessionService{}
+}
+
+// Validate returns the active session of the token family, ErrSessionRevoked if it was ended or belongs to another user
+func (s *sessionService) Validate(ctx context.Context, family string, userId int) (*model.Session, error) {
+	if family == "" || config.App().Cache.Has(revokedKey(family)) {
+		return nil, ErrSessionRevoked
+	}
+
+	session, err := sessionRepository.GetWithFamily(ctx, family)
+	if errors.Is(err, sql.ErrNoRows) {
+		_ = config.App().Cache.Set(revokedKey(family), []byte{1}, auth.AccessTokenTTL)
+		return nil, ErrSessionRevoked
+	}
+	if err != nil {
+		return nil, err
+	}
+	if session.UserID != userId {
+		return nil, ErrSessionRevoked
+	}
+
+	// the last seen time is written at most once per SessionTouchInterval
+	seen := []byte("session_seen:" + family)
+	if !config.App().Cache.Has(seen) {
+		ip, _ := ctx.Value(config.CKey("requestIp")).(string)
+		if err := sessionRepository.Touch(ctx, family, ip); err != nil {
+			return nil, err
+		}
+		_ = config.App().Cache.Set(seen, []byte{1}, SessionTouchInterval)
+	}
+	return session, nil
+}
+
+// List returns the sessions of the user that can still be refreshed, the one of the current family is marked
+func (s *sessionService) List(ctx context.Context, userId int, currentFamily string) ([]*model.Session, error) {
+	sessions, err := sessionRepository.List(ctx, userId, time.Now().Add(-auth.RefreshTokenTTL))
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		session.Current = session.Family == currentFamily
+	}
+	return sessions, nil
+}
+
+// Revoke ends the session of the user, its tokens stop working immediately
+func (s *sessionService) Revoke(ctx context.Context, userId, id int) error {
+	session, err := sessionRepository.Get(ctx, id, userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrSessionNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := NewTokenService().Revoke(ctx, session.Family); err != nil {
+		return err
+	}
+	auditor.Record(ctx, &model.AuditLog{UserID: userId, Event: AuditSessionRevoked, Detail: session.Device})
+	return nil
+}
+
+// RevokeOthers ends every session of the user except the current one and returns how many were ended
+func (s *sessionService) RevokeOthers(ctx context.Context, userId int, currentFamily string) (int, error) {
+	families, err := sessionRepository.Families(ctx, userId)
+	if err != nil {
+		return 0, err
+	}
+
+	var revoked []string
+	err = config.App().DB.WithTx(ctx, func(ctx context.Context) error {
+		for _, family := range families {
+			if family == currentFamily {
+				continue
+			}
+			if err := NewTokenService().revokeFamily(ctx, family); err != nil {
+				return err
+			}
+			revoked = append(revoked, family)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	// the families are cached as revoked only once the transaction is committed
+	if err := markRevoked(revoked...); err != nil {
+		return 0, err
+	}
+	if len(revoked) > 0 {
+		auditor.Record(ctx, &model.AuditLog{UserID: userId, Event: AuditSessionRevoked, Detail: fmt.Sprintf("%d other sessions", len(revoked))})
+	}
+	return len(revoked), nil
+}
+
+// newSession returns the session of a new token family with the user agent and ip of the request
+func newSession(ctx context.Context, userId int, family string) *model.Session {
+	userAgent, _ := ctx.Value(config.CKey("userAgent")).(string)
+	ip, _ := ctx.Value(config.CKey("requestIp")).(string)
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
+	return &model.Session{UserID: userId, Family: family, Device: deviceName(userAgent), UserAgent: userAgent, IP: ip}
+}
+
+// deviceName returns a readable name of the browser and platform of the user agent such as "Chrome on Windows",
+// it is a best guess and falls back to "Unknown device"
+func deviceName(userAgent string) string {
+	browsers := []struct{ token, name string }{
+		{"Edg/", "Edge"}, {"OPR/", "Opera"}, {"Firefox/", "Firefox"}, {"Chrome/", "Chrome"},
+		{"Safari/", "Safari"}, {"curl/", "curl"}, {"PostmanRuntime/", "Postman"}, {"okhttp/", "OkHttp"},
+	}
+	platforms := []struct{ token, name string }{
+		{"iPhone", "iPhone"}, {"iPad", "iPad"}, {"Android", "Android"}, {"Windows", "Windows"},
+		{"Mac OS X", "macOS"}, {"CrOS", "ChromeOS"}, {"Linux", "Linux"},
+	}
+
+	browser, platform := "", ""
+	for _, b := range browsers {
+		if strings.Contains(userAgent, b.token) {
+			browser = b.name
+			break
+		}
+	}
+	for _, p := range platforms {
+		if strings.Contains(userAgent, p.token) {
+			platform = p.name
+			break
+		}
+	}
+
+	switch {
+	case browser != "" && platform != "":
+		return browser + " on " + platform
+	case browser != "":
+		return browser
+	case platform != "":
+		return platform
+	}
+	return "Unknown device"
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mstgnz/starter-kit/api/infra/testdb"
+	"github.com/mstgnz/starter-kit/api/model"
+)
+
+// TestRevokeOthers checks that the other sessions end and the current one stays, and that no family is cached
+// as revoked when the transaction rolls back after some of them were revoked
+func TestRevokeOthers(t *testing.T) {
+	db := testdb.Open(t)
+	ctx := context.Background()
+	user, err := userRepository.Create(ctx, &model.Register{Fullname: "Session", Email: "others@session.test", Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := NewTokenService().Issue(ctx, user.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	families, err := sessionRepository.Families(ctx, user.ID)
+	if err != nil || len(families) != 3 {
+		t.Fatalf("families %v, %v, want 3", families, err)
+	}
+	current := families[0]
+
+	// the last family fails, the one before it was revoked in the transaction
+	trigger := "CREATE TRIGGER revoke_others_test BEFORE UPDATE ON sessions WHEN NEW.family = '" + families[2] +
+		"' BEGIN SELECT RAISE(ABORT, 'revoke failed'); END"
+	if _, err := db.ExecContext(ctx, trigger); err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewSessionService().RevokeOthers(ctx, user.ID, current)
+	if _, dropErr := db.ExecContext(ctx, "DROP TRIGGER revoke_others_test"); dropErr != nil {
+		t.Fatal(dropErr)
+	}
+	if err == nil {
+		t.Fatal("revoke with a failing family succeeded")
+	}
+	for _, family := range families {
+		if _, err := NewSessionService().Validate(ctx, family, user.ID); err != nil {
+			t.Fatalf("session %s after the rollback %v", family, err)
+		}
+	}
+
+	revoked, err := NewSessionService().RevokeOthers(ctx, user.ID, current)
+	if err != nil || revoked != 2 {
+		t.Fatalf("revoked %d, %v, want 2", revoked, err)
+	}
+	if _, err := NewSessionService().Validate(ctx, current, user.ID); err != nil {
+		t.Fatalf("current session %v", err)
+	}
+	for _, family := range families[1:] {
+		if _, err := NewSessionService().Validate(ctx, family, user.ID); !errors.Is(err, ErrSessionRevoked) {
+			t.Fatalf("session %s %v, want ErrSessionRevoked", family, err)
+		}
+	}
+}
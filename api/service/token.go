@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/mstgnz/starter-kit/api/infra/auth"
+	"github.com/mstgnz/starter-kit/api/infra/config"
+	"github.com/mstgnz/starter-kit/api/model"
+	"github.com/mstgnz/starter-kit/api/repository"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected, please login again")
+
+	// errInactiveUser is returned in the refresh transaction for a deactivated or deleted user
+	errInactiveUser = errors.New("user is not active")
+
+	refreshTokenRepository = repository.NewRefreshTokenRepository()
+)
+
+type tokenService struct {
+}
+
+func NewTokenService() *tokenService {
+	return &tokenService{}
+}
+
+// Issue starts a new session with a new token family for the user and returns an access and refresh token pair,
+// the device, user agent and ip of the session are taken from the request context
+func (s *tokenService) Issue(ctx context.Context, userId int) (*model.Token, error) {
+	var token *model.Token
+	err := config.App().DB.WithTx(ctx, func(ctx context.Context) error {
+		session := newSession(ctx, userId, auth.RandomHex(16))
+		if err := sessionRepository.Create(ctx, session); err != nil {
+			return err
+		}
+		var err error
+		token, err = s.issue(ctx, userId, session.Family)
+		return err
+	})
+	return token, err
+}
+
+// Refresh rotates the refresh token. Presenting an already used token revokes the whole family,
+// since it means the token was leaked and used by someone else. The family of a deactivated user is revoked.
+func (s *tokenService) Refresh(ctx context.Context, refreshToken string) (*model.Token, error) {
+	tokenHash := auth.HashToken(refreshToken)
+
+	// the token is only spent when the new pair is issued, a failure leaves it usable for a retry
+	var token *model.Token
+	var family string
+	err := config.App().DB.WithTx(ctx, func(ctx context.Context) error {
+		userId, tokenFamily, err := refreshTokenRepository.Use(ctx, tokenHash)
+		if err != nil {
+			return err
+		}
+		family = tokenFamily
+
+		user, err := userRepository.Find(ctx, userId, false)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && !user.Active) {
+			return errInactiveUser
+		}
+		if err != nil {
+			return err
+		}
+
+		ip, _ := ctx.Value(config.CKey("requestIp")).(string)
+		if err := sessionRepository.Touch(ctx, family, ip); err != nil {
+			return err
+		}
+		token, err = s.issue(ctx, userId, family)
+		return err
+	})
+	if err == nil {
+		return token, nil
+	}
+	if errors.Is(err, errInactiveUser) {
+		if err := s.Revoke(ctx, family); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	used, err := refreshTokenRepository.GetWithHash(ctx, tokenHash)
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	if used.UsedAt != nil && used.RevokedAt == nil {
+		if err := s.Revoke(ctx, used.Family); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+	return nil, ErrInvalidRefreshToken
+}
+
+// Revoke ends the session of the family and revokes its refresh tokens, access tokens of the family are
+// rejected by sessionService.Validate. Inside another transaction use revokeFamily and markRevoked instead,
+// Revoke marks the family before that transaction is committed.
+func (s *tokenService) Revoke(ctx context.Context, family string) error {
+	err := config.App().DB.WithTx(ctx, func(ctx context.Context) error {
+		return s.revokeFamily(ctx, family)
+	})
+	if err != nil {
+		return err
+	}
+	return markRevoked(family)
+}
+
+// revokeFamily ends the session of the family and revokes its refresh tokens in the transaction of ctx,
+// the caller passes the family to markRevoked once its transaction is committed
+func (s *tokenService) revokeFamily(ctx context.Context, family string) error {
+	if err := refreshTokenRepository.RevokeFamily(ctx, family); err != nil {
+		return err
+	}
+	return sessionRepository.Revoke(ctx, family)
+}
+
+// revokeUser ends every session of the user and revokes all of its refresh tokens in the transaction of ctx.
+// It returns the ended families, the caller passes them to markRevoked once its transaction is committed.
+func (s *tokenService) revokeUser(ctx context.Context, userId int) ([]string, error) {
+	families, err := sessionRepository.Families(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if err := refreshTokenRepository.RevokeUser(ctx, userId); err != nil {
+		return nil, err
+	}
+	for _, family := range families {
+		if err := sessionRepository.Revoke(ctx, family); err != nil {
+			return nil, err
+		}
+	}
+	return families, nil
+}
+
+func (s *tokenService) issue(ctx context.Context, userId int, family string) (*model.Token, error) {
+	accessToken, expiresAt, err := auth.GenerateToken(userId, family)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken := auth.RandomHex(32)
+	refreshExpiresAt := time.Now().Add(auth.RefreshTokenTTL)
+	if err := refreshTokenRepository.Create(ctx, userId, family, auth.HashToken(refreshToken), refreshExpiresAt); err != nil {
+		return nil, err
+	}
+
+	return &model.Token{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresAt:    expiresAt,
+	}, nil
+}
+
+// markRevoked caches the revoked families for the lifetime of an access token,
+// sessionService.Validate rejects their access tokens without a query
+func markRevoked(families ...string) error {
+	for _, family := range families {
+		if err := config.App().Cache.Set(revokedKey(family), []byte{1}, auth.AccessTokenTTL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func revokedKey(family string) []byte {
+	return []byte("revoked_family:" + family)
+}
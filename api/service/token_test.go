@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mstgnz/starter-kit/api/infra/testdb"
+	"github.com/mstgnz/starter-kit/api/model"
+)
+
+func TestRefresh(t *testing.T) {
+	db := testdb.Open(t)
+	ctx := context.Background()
+
+	issue := func(t *testing.T, email string) (*model.User, *model.Token, string) {
+		t.Helper()
+		user, err := userRepository.Create(ctx, &model.Register{Fullname: "Token", Email: email, Password: "secret"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		token, err := NewTokenService().Issue(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		families, err := sessionRepository.Families(ctx, user.ID)
+		if err != nil || len(families) != 1 {
+			t.Fatalf("families %v, %v, want 1", families, err)
+		}
+		return user, token, families[0]
+	}
+	revoked := func(t *testing.T, user *model.User, family string) {
+		t.Helper()
+		if _, err := NewSessionService().Validate(ctx, family, user.ID); !errors.Is(err, ErrSessionRevoked) {
+			t.Fatalf("session %v, want ErrSessionRevoked", err)
+		}
+	}
+
+	t.Run("rotate", func(t *testing.T) {
+		user, token, family := issue(t, "rotate@token.test")
+		rotated, err := NewTokenService().Refresh(ctx, token.RefreshToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rotated.RefreshToken == token.RefreshToken || rotated.AccessToken == "" {
+			t.Fatalf("refresh token not rotated: %+v", rotated)
+		}
+		again, err := NewTokenService().Refresh(ctx, rotated.RefreshToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again.RefreshToken == rotated.RefreshToken {
+			t.Fatal("refresh token not rotated twice")
+		}
+		if _, err := NewSessionService().Validate(ctx, family, user.ID); err != nil {
+			t.Fatalf("the session of the family ended: %v", err)
+		}
+		if families, err := sessionRepository.Families(ctx, user.ID); err != nil || len(families) != 1 {
+			t.Fatalf("families %v, %v, want the same one", families, err)
+		}
+	})
+
+	t.Run("replay revokes the family", func(t *testing.T) {
+		user, token, family := issue(t, "replay@token.test")
+		rotated, err := NewTokenService().Refresh(ctx, token.RefreshToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := NewTokenService().Refresh(ctx, token.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+			t.Fatalf("replay %v, want ErrRefreshTokenReused", err)
+		}
+		// the token of the rightful owner is revoked too
+		if _, err := NewTokenService().Refresh(ctx, rotated.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+			t.Fatalf("refresh after the replay %v, want ErrInvalidRefreshToken", err)
+		}
+		revoked(t, user, family)
+	})
+
+	t.Run("unknown token", func(t *testing.T) {
+		if _, err := NewTokenService().Refresh(ctx, "unknown"); !errors.Is(err, ErrInvalidRefreshToken) {
+			t.Fatalf("refresh %v, want ErrInvalidRefreshToken", err)
+		}
+	})
+
+	t.Run("inactive user", func(t *testing.T) {
+		user, token, family := issue(t, "inactive@token.test")
+		// active is turned off behind the back of the service, which would have ended the sessions
+		if err := db.QueryExec(ctx, db.Builder().Table("users").Update(map[string]any{"active": false}).Where("id", "=", user.ID)); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := NewTokenService().Refresh(ctx, token.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+			t.Fatalf("refresh %v, want ErrInvalidRefreshToken", err)
+		}
+		revoked(t, user, family)
+	})
+}
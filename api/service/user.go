@@ -2,8 +2,37 @@ package service
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
 
+	"github.com/mstgnz/starter-kit/api/infra/auth"
+	"github.com/mstgnz/starter-kit/api/infra/config"
+	"github.com/mstgnz/starter-kit/api/infra/logger"
+	"github.com/mstgnz/starter-kit/api/infra/paginate"
 	"github.com/mstgnz/starter-kit/api/model"
+	"github.com/mstgnz/starter-kit/api/repository"
+)
+
+// DefaultRole is assigned to registered users
+var DefaultRole = "user"
+
+var (
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	ErrUserExists         = errors.New("user already exists")
+	ErrUserNotFound       = errors.New("user not found")
+	ErrWrongPassword      = errors.New("current password is wrong")
+	ErrDeactivateSelf     = errors.New("you cannot deactivate yourself")
+
+	userRepository = repository.NewUserRepository()
+
+	// dummyHash is compared with the password of a login without a usable account, so the response takes as long
+	// as a wrong password and does not tell which emails have an account
+	dummyHash = sync.OnceValue(func() string {
+		return auth.HashAndSalt(auth.RandomHex(16))
+	})
 )
 
 type userService struct {
@@ -13,6 +42,225 @@ func NewUserService() *userService {
 	return &userService{}
 }
 
+// Login checks the credentials, updates the last login time and returns the user with every column, failed logins
+// are counted and a locked or throttled account gets a *LoginBlockedError
 func (s *userService) Login(ctx context.Context, login *model.Login) (*model.User, error) {
-	return nil, nil
+	user, err := userRepository.GetWithMail(ctx, login.Email)
+	if err != nil || !user.Active {
+		auth.ComparePassword(dummyHash(), login.Password)
+		return nil, ErrInvalidCredentials
+	}
+
+	// a locked account is rejected before the password is checked so guesses tell nothing
+	lockouts := NewLockoutService()
+	if err := lockouts.Check(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
+	if !auth.ComparePassword(user.Password, login.Password) {
+		if err := lockouts.Fail(ctx, user, "wrong password"); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidCredentials
+	}
+
+	// with two factor authentication the failures are forgotten after the code, see mfaService.Login
+	enabled, err := NewMFAService().Enabled(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		if err := lockouts.Reset(ctx, user.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := userRepository.LastLoginUpdate(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
+	// the login query only selects the credentials
+	return s.Profile(ctx, user.ID)
+}
+
+// Register creates a new user with the default role if the email is not taken, mails a verification link and
+// returns the user with every column
+func (s *userService) Register(ctx context.Context, register *model.Register) (*model.User, error) {
+	var user *model.User
+	err := config.App().DB.WithTx(ctx, func(ctx context.Context) error {
+		exists, err := userRepository.Exists(ctx, register.Email)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return ErrUserExists
+		}
+
+		if user, err = userRepository.Create(ctx, register); err != nil {
+			return err
+		}
+		return roleRepository.Assign(ctx, user.ID, DefaultRole)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the user can ask for another link, a failed mail does not fail the registration
+	if err := NewAccountService().SendVerification(ctx, user.ID); err != nil {
+		logger.Warn(fmt.Sprintf("Verification mail to user %d failed: %v", user.ID, err))
+	}
+	return s.Profile(ctx, user.ID)
+}
+
+// Profile returns the user with every column
+func (s *userService) Profile(ctx context.Context, userId int) (*model.User, error) {
+	return s.find(ctx, userId, false)
+}
+
+// UpdateProfile updates the name and phone of the user
+func (s *userService) UpdateProfile(ctx context.Context, userId int, update *model.ProfileUpdate) (*model.User, error) {
+	values := map[string]any{}
+	if update.Fullname != nil {
+		values["fullname"] = *update.Fullname
+	}
+	if update.Phone != nil {
+		values["phone"] = *update.Phone
+	}
+	return s.update(ctx, userId, values)
+}
+
+// ChangePassword sets a new password after checking the current one and ends every session of the user,
+// the current one included
+func (s *userService) ChangePassword(ctx context.Context, userId int, update *model.PasswordUpdate) error {
+	user, err := userRepository.GetWithId(ctx, userId)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	if !auth.ComparePassword(user.Password, update.CurrentPassword) {
+		return ErrWrongPassword
+	}
+
+	var families []string
+	err = config.App().DB.WithTx(ctx, func(ctx context.Context) error {
+		if err := userRepository.PasswordUpdate(ctx, update.Password, userId); err != nil {
+			return err
+		}
+		families, err = NewTokenService().revokeUser(ctx, userId)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return markRevoked(families...)
+}
+
+// List returns a page of users
+func (s *userService) List(ctx context.Context, list *model.UserList) (*paginate.Page[model.User], error) {
+	return userRepository.Paginate(ctx, list)
+}
+
+// Get returns the user, deactivated users included
+func (s *userService) Get(ctx context.Context, userId int) (*model.User, error) {
+	return s.find(ctx, userId, true)
+}
+
+// Update updates the given fields of the user, the email must not be taken by another user. Setting active
+// to false ends every session of the user.
+func (s *userService) Update(ctx context.Context, update *model.UserUpdate) (*model.User, error) {
+	user, err := s.find(ctx, update.ID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]any{}
+	if update.Fullname != nil {
+		values["fullname"] = *update.Fullname
+	}
+	if update.Email != nil && *update.Email != user.Email {
+		exists, err := userRepository.Exists(ctx, *update.Email)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, ErrUserExists
+		}
+		values["email"] = *update.Email
+	}
+	if update.Phone != nil {
+		values["phone"] = *update.Phone
+	}
+	if update.Active != nil {
+		values["active"] = *update.Active
+	}
+	if update.Active == nil || *update.Active {
+		return s.update(ctx, update.ID, values)
+	}
+
+	// an inactive user cannot log in, the sessions it has are ended like on Deactivate
+	var families []string
+	err = config.App().DB.WithTx(ctx, func(ctx context.Context) error {
+		if user, err = s.update(ctx, update.ID, values); err != nil {
+			return err
+		}
+		families, err = NewTokenService().revokeUser(ctx, update.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, markRevoked(families...)
+}
+
+// Deactivate soft deletes the user and ends every session of them, an admin cannot deactivate themselves
+func (s *userService) Deactivate(ctx context.Context, userId, actorId int) error {
+	if userId == actorId {
+		return ErrDeactivateSelf
+	}
+	if _, err := s.find(ctx, userId, false); err != nil {
+		return err
+	}
+
+	var families []string
+	err := config.App().DB.WithTx(ctx, func(ctx context.Context) error {
+		if err := userRepository.Delete(ctx, userId); err != nil {
+			return err
+		}
+		var err error
+		families, err = NewTokenService().revokeUser(ctx, userId)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return markRevoked(families...)
+}
+
+// Restore reactivates a deactivated user, restoring an active user does nothing
+func (s *userService) Restore(ctx context.Context, userId int) error {
+	user, err := s.find(ctx, userId, true)
+	if err != nil {
+		return err
+	}
+	if user.DeletedAt == nil {
+		return nil
+	}
+	return userRepository.Restore(ctx, userId)
+}
+
+func (s *userService) find(ctx context.Context, userId int, trashed bool) (*model.User, error) {
+	user, err := userRepository.Find(ctx, userId, trashed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	return user, err
+}
+
+// update writes the values and updated_at to the user and returns the updated user
+func (s *userService) update(ctx context.Context, userId int, values map[string]any) (*model.User, error) {
+	values["updated_at"] = time.Now().Format("2006-01-02 15:04:05")
+	query, params := config.App().DB.Builder().Table("users").Update(values).Where("id", "=", userId).Prepare()
+	if err := userRepository.ProfileUpdate(ctx, query, params); err != nil {
+		return nil, err
+	}
+	return s.find(ctx, userId, true)
 }
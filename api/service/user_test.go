@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mstgnz/starter-kit/api/infra/testdb"
+	"github.com/mstgnz/starter-kit/api/model"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// TestEndUserSessions checks that a password change, a deactivation and setting active to false end every session
+// of the user
+func TestEndUserSessions(t *testing.T) {
+	testdb.Open(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		email string
+		end   func(userId int) error
+	}{
+		{"password@user.test", func(userId int) error {
+			return NewUserService().ChangePassword(ctx, userId, &model.PasswordUpdate{CurrentPassword: "secret", Password: "changed", RePassword: "changed"})
+		}},
+		{"deactivate@user.test", func(userId int) error {
+			return NewUserService().Deactivate(ctx, userId, 0)
+		}},
+		{"inactive@user.test", func(userId int) error {
+			active := false
+			_, err := NewUserService().Update(ctx, &model.UserUpdate{ID: userId, Active: &active})
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.email, func(t *testing.T) {
+			user, err := userRepository.Create(ctx, &model.Register{Fullname: "Sessions", Email: tt.email, Password: "secret", Phone: "+905550000000"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// two devices
+			var tokens []*model.Token
+			for i := 0; i < 2; i++ {
+				token, err := NewTokenService().Issue(ctx, user.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				tokens = append(tokens, token)
+			}
+			families, err := sessionRepository.Families(ctx, user.ID)
+			if err != nil || len(families) != 2 {
+				t.Fatalf("families %v, %v, want 2", families, err)
+			}
+
+			if err := tt.end(user.ID); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, token := range tokens {
+				if _, err := NewTokenService().Refresh(ctx, token.RefreshToken); err == nil {
+					t.Fatal("a refresh token of the user still works")
+				}
+			}
+			for _, family := range families {
+				if _, err := NewSessionService().Validate(ctx, family, user.ID); !errors.Is(err, ErrSessionRevoked) {
+					t.Fatalf("session %s: %v, want ErrSessionRevoked", family, err)
+				}
+			}
+			if families, err := sessionRepository.Families(ctx, user.ID); err != nil || len(families) != 0 {
+				t.Fatalf("families %v, %v, want none", families, err)
+			}
+		})
+	}
+}
+
+// TestLoginInactive checks that a user with active set to false cannot log in with the right password
+func TestLoginInactive(t *testing.T) {
+	testdb.Open(t)
+	ctx := context.Background()
+	user, err := userRepository.Create(ctx, &model.Register{Fullname: "Inactive", Email: "login-inactive@user.test", Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	login := &model.Login{Email: user.Email, Password: "secret"}
+	if _, err := NewUserService().Login(ctx, login); err != nil {
+		t.Fatal(err)
+	}
+
+	active := false
+	if _, err := NewUserService().Update(ctx, &model.UserUpdate{ID: user.ID, Active: &active}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewUserService().Login(ctx, login); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("login of an inactive user %v, want ErrInvalidCredentials", err)
+	}
+}
+
+// TestLoginDummyHash checks that a login without an account is rejected like a wrong password and that the
+// dummy hash it compares with costs as much as the hash of a real password
+func TestLoginDummyHash(t *testing.T) {
+	testdb.Open(t)
+	ctx := context.Background()
+	user, err := userRepository.Create(ctx, &model.Register{Fullname: "Dummy", Email: "dummy@user.test", Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewUserService().Login(ctx, &model.Login{Email: "nobody@user.test", Password: "secret"}); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("login of an unknown email %v, want ErrInvalidCredentials", err)
+	}
+
+	stored, err := userRepository.GetWithMail(ctx, user.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := bcrypt.Cost([]byte(stored.Password))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost, err := bcrypt.Cost([]byte(dummyHash())); err != nil || cost != want {
+		t.Fatalf("dummy hash cost %d, %v, want %d", cost, err, want)
+	}
+}